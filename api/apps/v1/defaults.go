@@ -30,6 +30,10 @@ func SetDefaults_XStatefulSet(obj *XStatefulSet) {
 		obj.Spec.PodManagementPolicy = appsv1.OrderedReadyPodManagement
 	}
 
+	if obj.Spec.PodManagementPolicy == BoundedParallelPodManagement && obj.Spec.MaxConcurrentPodOperations == nil {
+		obj.Spec.MaxConcurrentPodOperations = ptr.To(intstr.FromInt32(10))
+	}
+
 	if obj.Spec.UpdateStrategy.Type == "" {
 		obj.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
 
@@ -62,6 +66,21 @@ func SetDefaults_XStatefulSet(obj *XStatefulSet) {
 		obj.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled = appsv1.RetainPersistentVolumeClaimRetentionPolicyType
 	}
 
+	if obj.Spec.UnreachableNodePolicy == nil {
+		obj.Spec.UnreachableNodePolicy = &UnreachableNodePolicy{}
+	}
+	if len(obj.Spec.UnreachableNodePolicy.Type) == 0 {
+		obj.Spec.UnreachableNodePolicy.Type = WaitUnreachableNodePolicyType
+	}
+	if obj.Spec.UnreachableNodePolicy.Type == ForceDeleteUnreachableNodePolicyType &&
+		obj.Spec.UnreachableNodePolicy.ForceDeleteAfterSeconds == nil {
+		obj.Spec.UnreachableNodePolicy.ForceDeleteAfterSeconds = ptr.To[int32](300)
+	}
+
+	if obj.Spec.Suspend == nil {
+		obj.Spec.Suspend = ptr.To(false)
+	}
+
 	if obj.Spec.Replicas == nil {
 		obj.Spec.Replicas = new(int32)
 		*obj.Spec.Replicas = 1
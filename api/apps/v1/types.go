@@ -20,6 +20,7 @@ import (
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 const (
@@ -27,8 +28,16 @@ const (
 	StatefulSetRevisionLabel       = ControllerRevisionHashLabelKey
 	StatefulSetPodNameLabel        = "xstatefulset.x-k8s.io/pod-name"
 	PodIndexLabel                  = "apps.x-k8s.io/pod-index"
+	// ConfigHashAnnotation is set on the pod template of StatefulSets that roll out on config changes. Its value is
+	// the hash of the content of the ConfigMaps and Secrets referenced by the template.
+	ConfigHashAnnotation = "apps.x-k8s.io/config-hash"
 )
 
+// BoundedParallelPodManagement will create, replace and delete pods in parallel and in no
+// particular order like the Parallel policy, but limits the number of pods that are in flight
+// (pending, ready but not yet available, or terminating) to maxConcurrentPodOperations.
+const BoundedParallelPodManagement appsv1.PodManagementPolicyType = "BoundedParallel"
+
 // +genclient
 // +genclient:method=GetScale,verb=get,subresource=scale,result=k8s.io/api/autoscaling/v1.Scale
 // +genclient:method=UpdateScale,verb=update,subresource=scale,input=k8s.io/api/autoscaling/v1.Scale,result=k8s.io/api/autoscaling/v1.Scale
@@ -41,6 +50,7 @@ const (
 // +kubebuilder:printcolumn:name="DESIRED",type="integer",description="Desired number of pods",JSONPath=".spec.replicas"
 // +kubebuilder:printcolumn:name="CURRENT",type="integer",description="Current number of pods",JSONPath=".status.replicas"
 // +kubebuilder:printcolumn:name="READY",type="integer",description="Number of ready pods",JSONPath=".status.readyReplicas"
+// +kubebuilder:printcolumn:name="SUSPENDED",type="boolean",description="Whether the set is suspended",JSONPath=".spec.suspend"
 // +kubebuilder:printcolumn:name="AGE",type="date",description="Creation timestamp",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 
@@ -118,10 +128,24 @@ type XStatefulSetSpec struct {
 	// continuing. When scaling down, the pods are removed in the opposite order.
 	// The alternative policy is `Parallel` which will create pods in parallel
 	// to match the desired scale without waiting, and on scale down will delete
-	// all pods at once.
+	// all pods at once. The `BoundedParallel` policy behaves like `Parallel` but
+	// keeps at most maxConcurrentPodOperations pods in flight at any time.
+	// +kubebuilder:validation:Enum=OrderedReady;Parallel;BoundedParallel
 	// +optional
 	PodManagementPolicy appsv1.PodManagementPolicyType `json:"podManagementPolicy,omitempty" protobuf:"bytes,6,opt,name=podManagementPolicy,casttype=PodManagementPolicyType"`
 
+	// maxConcurrentPodOperations is the maximum number of pods that may be in
+	// flight at the same time when podManagementPolicy is `BoundedParallel`. A pod
+	// is in flight while it is pending, while it is ready but not yet available,
+	// and while it is terminating. Running pods that are not ready, e.g. because
+	// they crash loop, are not in flight. Value can be an absolute number (ex: 5)
+	// or a percentage of desired pods (ex: 10%); percentages are rounded down, but
+	// never below 1. Defaults to 10 for the `BoundedParallel` policy and is ignored
+	// otherwise.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxConcurrentPodOperations *intstr.IntOrString `json:"maxConcurrentPodOperations,omitempty"`
+
 	// updateStrategy indicates the StatefulSetUpdateStrategy that will be
 	// employed to update Pods in the StatefulSet when a revision is made to
 	// Template.
@@ -153,6 +177,90 @@ type XStatefulSetSpec struct {
 	// increments the index by one for each additional replica requested.
 	// +optional
 	Ordinals *appsv1.StatefulSetOrdinals `json:"ordinals,omitempty" protobuf:"bytes,11,opt,name=ordinals"`
+
+	// unreachableNodePolicy describes how the controller treats pods that are stuck
+	// terminating on nodes tainted as unreachable. By default the controller waits
+	// for such pods to be removed, which blocks OrderedReady sets until the node
+	// recovers or the pod is deleted manually.
+	// +optional
+	UnreachableNodePolicy *UnreachableNodePolicy `json:"unreachableNodePolicy,omitempty"`
+
+	// suspend specifies whether the controller should delete all pods of the
+	// StatefulSet. Unlike scaling to zero, replicas is left untouched so the same
+	// ordinals are recreated when the set is resumed, and the persistent volume
+	// claims of suspended pods are always retained, regardless of the whenScaled
+	// retention policy. Defaults to false.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// scalingSchedules is a list of cron schedules that change the desired number
+	// of replicas. Once a schedule fires, its replicas take precedence over the
+	// replicas field until another schedule fires or the fired schedule is removed
+	// from the list. Until the first schedule fires, replicas is used.
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	ScalingSchedules []ScalingSchedule `json:"scalingSchedules,omitempty"`
+
+	// rolloutOnConfigChange specifies whether pods are rolled out when the content
+	// of a ConfigMap or Secret referenced by the template changes. ConfigMaps and
+	// Secrets are tracked when they are mounted as volumes, including projected
+	// volumes, or consumed through envFrom or env valueFrom. The hash of their
+	// content is recorded in the apps.x-k8s.io/config-hash annotation of the
+	// template, so that any change produces a new revision. It requires the
+	// RolloutOnConfigChange feature gate of the controller. Defaults to false.
+	// +optional
+	RolloutOnConfigChange *bool `json:"rolloutOnConfigChange,omitempty"`
+}
+
+// ScalingSchedule changes the desired number of replicas of a StatefulSet on a cron schedule.
+type ScalingSchedule struct {
+	// name uniquely identifies the schedule within the StatefulSet.
+	Name string `json:"name"`
+
+	// schedule is the cron expression, in the standard five-field format, at which
+	// the replicas of this schedule are applied.
+	Schedule string `json:"schedule"`
+
+	// timeZone is the name of the time zone, from the tz database, in which the
+	// schedule is evaluated. Defaults to the time zone of the controller.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// replicas is the desired number of replicas once the schedule fires.
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+}
+
+// UnreachableNodePolicyType describes how pods stuck terminating on unreachable nodes are handled.
+type UnreachableNodePolicyType string
+
+const (
+	// WaitUnreachableNodePolicyType waits for pods on unreachable nodes to be removed by the
+	// kubelet or by an administrator. This is the default behavior.
+	WaitUnreachableNodePolicyType UnreachableNodePolicyType = "Wait"
+	// ForceDeleteUnreachableNodePolicyType force deletes pods that have been terminating on an
+	// unreachable node for longer than forceDeleteAfterSeconds.
+	ForceDeleteUnreachableNodePolicyType UnreachableNodePolicyType = "ForceDelete"
+)
+
+// UnreachableNodePolicy describes the policy applied to pods that are stuck terminating on
+// nodes tainted with node.kubernetes.io/unreachable.
+type UnreachableNodePolicy struct {
+	// type is the policy applied to pods on unreachable nodes. Valid values are
+	// `Wait` and `ForceDelete`. Defaults to `Wait`. `ForceDelete` requires the
+	// UnreachableNodeForceDelete feature gate of the controller, which watches the
+	// nodes of the cluster while it is enabled.
+	// +optional
+	Type UnreachableNodePolicyType `json:"type,omitempty"`
+
+	// forceDeleteAfterSeconds is the number of seconds a pod must have been
+	// terminating on an unreachable node before it is force deleted. The period
+	// starts when both the pod's deletion timestamp and the unreachable taint's
+	// timeAdded have passed. Only used by the `ForceDelete` policy. Defaults to 300.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	ForceDeleteAfterSeconds *int32 `json:"forceDeleteAfterSeconds,omitempty"`
 }
 
 // XStatefulSetStatus represents the current state of a StatefulSet.
@@ -206,6 +314,34 @@ type XStatefulSetStatus struct {
 	// This field is required for the scale subresource to work with HPA.
 	// +optional
 	Selector string `json:"selector,omitempty"`
+
+	// scalingSchedule describes the schedule that currently determines the desired number of replicas
+	// and the next scheduled change. It is only set when the StatefulSet has scaling schedules.
+	// +optional
+	ScalingSchedule *ScalingScheduleStatus `json:"scalingSchedule,omitempty"`
+}
+
+// ScalingScheduleStatus describes the last and the next scheduled replica changes of a StatefulSet.
+type ScalingScheduleStatus struct {
+	// activeSchedule is the name of the schedule that fired last.
+	// +optional
+	ActiveSchedule string `json:"activeSchedule,omitempty"`
+
+	// lastScheduleTime is the time at which activeSchedule fired.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// nextSchedule is the name of the schedule that fires next.
+	// +optional
+	NextSchedule string `json:"nextSchedule,omitempty"`
+
+	// nextScheduleTime is the time at which nextSchedule fires.
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// nextReplicas is the desired number of replicas once nextSchedule fires.
+	// +optional
+	NextReplicas *int32 `json:"nextReplicas,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
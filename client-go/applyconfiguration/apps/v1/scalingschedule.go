@@ -0,0 +1,66 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+// ScalingScheduleApplyConfiguration represents a declarative configuration of the ScalingSchedule type for use
+// with apply.
+type ScalingScheduleApplyConfiguration struct {
+	Name     *string `json:"name,omitempty"`
+	Schedule *string `json:"schedule,omitempty"`
+	TimeZone *string `json:"timeZone,omitempty"`
+	Replicas *int32  `json:"replicas,omitempty"`
+}
+
+// ScalingScheduleApplyConfiguration constructs a declarative configuration of the ScalingSchedule type for use with
+// apply.
+func ScalingSchedule() *ScalingScheduleApplyConfiguration {
+	return &ScalingScheduleApplyConfiguration{}
+}
+
+// WithName sets the Name field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Name field is set to the value of the last call.
+func (b *ScalingScheduleApplyConfiguration) WithName(value string) *ScalingScheduleApplyConfiguration {
+	b.Name = &value
+	return b
+}
+
+// WithSchedule sets the Schedule field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Schedule field is set to the value of the last call.
+func (b *ScalingScheduleApplyConfiguration) WithSchedule(value string) *ScalingScheduleApplyConfiguration {
+	b.Schedule = &value
+	return b
+}
+
+// WithTimeZone sets the TimeZone field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the TimeZone field is set to the value of the last call.
+func (b *ScalingScheduleApplyConfiguration) WithTimeZone(value string) *ScalingScheduleApplyConfiguration {
+	b.TimeZone = &value
+	return b
+}
+
+// WithReplicas sets the Replicas field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Replicas field is set to the value of the last call.
+func (b *ScalingScheduleApplyConfiguration) WithReplicas(value int32) *ScalingScheduleApplyConfiguration {
+	b.Replicas = &value
+	return b
+}
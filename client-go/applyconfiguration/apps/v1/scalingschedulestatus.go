@@ -0,0 +1,79 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ScalingScheduleStatusApplyConfiguration represents a declarative configuration of the ScalingScheduleStatus type for use
+// with apply.
+type ScalingScheduleStatusApplyConfiguration struct {
+	ActiveSchedule   *string      `json:"activeSchedule,omitempty"`
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	NextSchedule     *string      `json:"nextSchedule,omitempty"`
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+	NextReplicas     *int32       `json:"nextReplicas,omitempty"`
+}
+
+// ScalingScheduleStatusApplyConfiguration constructs a declarative configuration of the ScalingScheduleStatus type for use with
+// apply.
+func ScalingScheduleStatus() *ScalingScheduleStatusApplyConfiguration {
+	return &ScalingScheduleStatusApplyConfiguration{}
+}
+
+// WithActiveSchedule sets the ActiveSchedule field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ActiveSchedule field is set to the value of the last call.
+func (b *ScalingScheduleStatusApplyConfiguration) WithActiveSchedule(value string) *ScalingScheduleStatusApplyConfiguration {
+	b.ActiveSchedule = &value
+	return b
+}
+
+// WithLastScheduleTime sets the LastScheduleTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the LastScheduleTime field is set to the value of the last call.
+func (b *ScalingScheduleStatusApplyConfiguration) WithLastScheduleTime(value metav1.Time) *ScalingScheduleStatusApplyConfiguration {
+	b.LastScheduleTime = &value
+	return b
+}
+
+// WithNextSchedule sets the NextSchedule field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NextSchedule field is set to the value of the last call.
+func (b *ScalingScheduleStatusApplyConfiguration) WithNextSchedule(value string) *ScalingScheduleStatusApplyConfiguration {
+	b.NextSchedule = &value
+	return b
+}
+
+// WithNextScheduleTime sets the NextScheduleTime field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NextScheduleTime field is set to the value of the last call.
+func (b *ScalingScheduleStatusApplyConfiguration) WithNextScheduleTime(value metav1.Time) *ScalingScheduleStatusApplyConfiguration {
+	b.NextScheduleTime = &value
+	return b
+}
+
+// WithNextReplicas sets the NextReplicas field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the NextReplicas field is set to the value of the last call.
+func (b *ScalingScheduleStatusApplyConfiguration) WithNextReplicas(value int32) *ScalingScheduleStatusApplyConfiguration {
+	b.NextReplicas = &value
+	return b
+}
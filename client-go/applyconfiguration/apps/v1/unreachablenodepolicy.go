@@ -0,0 +1,52 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Code generated by applyconfiguration-gen. DO NOT EDIT.
+
+package v1
+
+import (
+	appsv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+)
+
+// UnreachableNodePolicyApplyConfiguration represents a declarative configuration of the UnreachableNodePolicy type for use
+// with apply.
+type UnreachableNodePolicyApplyConfiguration struct {
+	Type                    *appsv1.UnreachableNodePolicyType `json:"type,omitempty"`
+	ForceDeleteAfterSeconds *int32                            `json:"forceDeleteAfterSeconds,omitempty"`
+}
+
+// UnreachableNodePolicyApplyConfiguration constructs a declarative configuration of the UnreachableNodePolicy type for use with
+// apply.
+func UnreachableNodePolicy() *UnreachableNodePolicyApplyConfiguration {
+	return &UnreachableNodePolicyApplyConfiguration{}
+}
+
+// WithType sets the Type field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Type field is set to the value of the last call.
+func (b *UnreachableNodePolicyApplyConfiguration) WithType(value appsv1.UnreachableNodePolicyType) *UnreachableNodePolicyApplyConfiguration {
+	b.Type = &value
+	return b
+}
+
+// WithForceDeleteAfterSeconds sets the ForceDeleteAfterSeconds field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ForceDeleteAfterSeconds field is set to the value of the last call.
+func (b *UnreachableNodePolicyApplyConfiguration) WithForceDeleteAfterSeconds(value int32) *UnreachableNodePolicyApplyConfiguration {
+	b.ForceDeleteAfterSeconds = &value
+	return b
+}
@@ -20,6 +20,7 @@ package v1
 
 import (
 	appsv1 "k8s.io/api/apps/v1"
+	intstr "k8s.io/apimachinery/pkg/util/intstr"
 	applyconfigurationsappsv1 "k8s.io/client-go/applyconfigurations/apps/v1"
 	corev1 "k8s.io/client-go/applyconfigurations/core/v1"
 	metav1 "k8s.io/client-go/applyconfigurations/meta/v1"
@@ -34,11 +35,16 @@ type XStatefulSetSpecApplyConfiguration struct {
 	VolumeClaimTemplates                 []corev1.PersistentVolumeClaimApplyConfiguration                                             `json:"volumeClaimTemplates,omitempty"`
 	ServiceName                          *string                                                                                      `json:"serviceName,omitempty"`
 	PodManagementPolicy                  *appsv1.PodManagementPolicyType                                                              `json:"podManagementPolicy,omitempty"`
+	MaxConcurrentPodOperations           *intstr.IntOrString                                                                          `json:"maxConcurrentPodOperations,omitempty"`
 	UpdateStrategy                       *applyconfigurationsappsv1.StatefulSetUpdateStrategyApplyConfiguration                       `json:"updateStrategy,omitempty"`
 	RevisionHistoryLimit                 *int32                                                                                       `json:"revisionHistoryLimit,omitempty"`
 	MinReadySeconds                      *int32                                                                                       `json:"minReadySeconds,omitempty"`
 	PersistentVolumeClaimRetentionPolicy *applyconfigurationsappsv1.StatefulSetPersistentVolumeClaimRetentionPolicyApplyConfiguration `json:"persistentVolumeClaimRetentionPolicy,omitempty"`
 	Ordinals                             *applyconfigurationsappsv1.StatefulSetOrdinalsApplyConfiguration                             `json:"ordinals,omitempty"`
+	UnreachableNodePolicy                *UnreachableNodePolicyApplyConfiguration                                                     `json:"unreachableNodePolicy,omitempty"`
+	Suspend                              *bool                                                                                        `json:"suspend,omitempty"`
+	ScalingSchedules                     []ScalingScheduleApplyConfiguration                                                          `json:"scalingSchedules,omitempty"`
+	RolloutOnConfigChange                *bool                                                                                        `json:"rolloutOnConfigChange,omitempty"`
 }
 
 // XStatefulSetSpecApplyConfiguration constructs a declarative configuration of the XStatefulSetSpec type for use with
@@ -100,6 +106,14 @@ func (b *XStatefulSetSpecApplyConfiguration) WithPodManagementPolicy(value appsv
 	return b
 }
 
+// WithMaxConcurrentPodOperations sets the MaxConcurrentPodOperations field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the MaxConcurrentPodOperations field is set to the value of the last call.
+func (b *XStatefulSetSpecApplyConfiguration) WithMaxConcurrentPodOperations(value intstr.IntOrString) *XStatefulSetSpecApplyConfiguration {
+	b.MaxConcurrentPodOperations = &value
+	return b
+}
+
 // WithUpdateStrategy sets the UpdateStrategy field in the declarative configuration to the given value
 // and returns the receiver, so that objects can be built by chaining "With" function invocations.
 // If called multiple times, the UpdateStrategy field is set to the value of the last call.
@@ -139,3 +153,40 @@ func (b *XStatefulSetSpecApplyConfiguration) WithOrdinals(value *applyconfigurat
 	b.Ordinals = value
 	return b
 }
+
+// WithUnreachableNodePolicy sets the UnreachableNodePolicy field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the UnreachableNodePolicy field is set to the value of the last call.
+func (b *XStatefulSetSpecApplyConfiguration) WithUnreachableNodePolicy(value *UnreachableNodePolicyApplyConfiguration) *XStatefulSetSpecApplyConfiguration {
+	b.UnreachableNodePolicy = value
+	return b
+}
+
+// WithSuspend sets the Suspend field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the Suspend field is set to the value of the last call.
+func (b *XStatefulSetSpecApplyConfiguration) WithSuspend(value bool) *XStatefulSetSpecApplyConfiguration {
+	b.Suspend = &value
+	return b
+}
+
+// WithScalingSchedules adds the given value to the ScalingSchedules field in the declarative configuration
+// and returns the receiver, so that objects can be build by chaining "With" function invocations.
+// If called multiple times, values provided by each call will be appended to the ScalingSchedules field.
+func (b *XStatefulSetSpecApplyConfiguration) WithScalingSchedules(values ...*ScalingScheduleApplyConfiguration) *XStatefulSetSpecApplyConfiguration {
+	for i := range values {
+		if values[i] == nil {
+			panic("nil value passed to WithScalingSchedules")
+		}
+		b.ScalingSchedules = append(b.ScalingSchedules, *values[i])
+	}
+	return b
+}
+
+// WithRolloutOnConfigChange sets the RolloutOnConfigChange field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the RolloutOnConfigChange field is set to the value of the last call.
+func (b *XStatefulSetSpecApplyConfiguration) WithRolloutOnConfigChange(value bool) *XStatefulSetSpecApplyConfiguration {
+	b.RolloutOnConfigChange = &value
+	return b
+}
@@ -36,6 +36,7 @@ type XStatefulSetStatusApplyConfiguration struct {
 	Conditions         []appsv1.StatefulSetConditionApplyConfiguration `json:"conditions,omitempty"`
 	AvailableReplicas  *int32                                          `json:"availableReplicas,omitempty"`
 	Selector           *string                                         `json:"selector,omitempty"`
+	ScalingSchedule    *ScalingScheduleStatusApplyConfiguration        `json:"scalingSchedule,omitempty"`
 }
 
 // XStatefulSetStatusApplyConfiguration constructs a declarative configuration of the XStatefulSetStatus type for use with
@@ -136,3 +137,11 @@ func (b *XStatefulSetStatusApplyConfiguration) WithSelector(value string) *XStat
 	b.Selector = &value
 	return b
 }
+
+// WithScalingSchedule sets the ScalingSchedule field in the declarative configuration to the given value
+// and returns the receiver, so that objects can be built by chaining "With" function invocations.
+// If called multiple times, the ScalingSchedule field is set to the value of the last call.
+func (b *XStatefulSetStatusApplyConfiguration) WithScalingSchedule(value *ScalingScheduleStatusApplyConfiguration) *XStatefulSetStatusApplyConfiguration {
+	b.ScalingSchedule = value
+	return b
+}
@@ -32,6 +32,12 @@ import (
 func ForKind(kind schema.GroupVersionKind) interface{} {
 	switch kind {
 	// Group=apps.x-k8s.io, Version=v1
+	case v1.SchemeGroupVersion.WithKind("ScalingSchedule"):
+		return &appsv1.ScalingScheduleApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("ScalingScheduleStatus"):
+		return &appsv1.ScalingScheduleStatusApplyConfiguration{}
+	case v1.SchemeGroupVersion.WithKind("UnreachableNodePolicy"):
+		return &appsv1.UnreachableNodePolicyApplyConfiguration{}
 	case v1.SchemeGroupVersion.WithKind("XStatefulSet"):
 		return &appsv1.XStatefulSetApplyConfiguration{}
 	case v1.SchemeGroupVersion.WithKind("XStatefulSetSpec"):
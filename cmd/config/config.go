@@ -16,22 +16,131 @@ limitations under the License.
 
 package config
 
-// Config is the main context object for the controller.
-type Config struct {
-	EnableLeaderElection bool
-	Workers              int
-	Kubeconfig           string
-	MasterURL            string
-	KubeAPIQPS           float32
-	KubeAPIBurst         int
+import (
+	"time"
+
+	componentbaseconfig "k8s.io/component-base/config"
+)
+
+// ControllerManagerConfiguration is the configuration the controller manager runs with. It is loaded from the
+// versioned configuration file, see v1alpha1.ControllerManagerConfiguration, and overridden by flags.
+type ControllerManagerConfiguration struct {
+	// ClientConnection configures the connection to the Kubernetes API server.
+	ClientConnection componentbaseconfig.ClientConnectionConfiguration
+	// MasterURL is the address of the Kubernetes API server. It overrides any value in the kubeconfig and can only
+	// be set with a flag.
+	MasterURL string
+	// LeaderElection configures the election of the replica that runs the controller. The webhook is served by
+	// every replica.
+	LeaderElection LeaderElectionConfiguration
+	Controller     ControllerConfiguration
+	Watch          WatchConfiguration
+	Sharding       ShardingConfiguration
+	Webhook        WebhookConfiguration
+	Metrics        MetricsConfiguration
+	// HealthProbeBindAddress is the address /healthz and /readyz are served on. "0" disables the probes.
+	HealthProbeBindAddress string
+	Tracing                TracingConfiguration
+	// FeatureGates enables or disables alpha and beta features.
+	FeatureGates map[string]bool
+}
+
+// LeaderElectionConfiguration configures the election of the replica that runs the controller.
+type LeaderElectionConfiguration struct {
+	componentbaseconfig.LeaderElectionConfiguration
+	// ReleaseOnCancel releases the lease when the controller manager shuts down.
+	ReleaseOnCancel bool
+}
+
+// ControllerConfiguration configures the xstatefulset controller.
+type ControllerConfiguration struct {
+	Workers int32
+	// WorkerStallTimeout is how long the workers may not take a key from a non-empty queue before the controller
+	// is reported unhealthy.
+	WorkerStallTimeout time.Duration
+	RateLimiter        RateLimiterConfiguration
+	// FairQueuing syncs the queued XStatefulSets of the namespaces in turn.
+	FairQueuing        bool
+	PodOperationLimits PodOperationLimitsConfiguration
+	// DryRun logs the writes that each sync would make instead of making them.
+	DryRun bool
+	// AuditLogPath is the file the writes of the controller are audited to, "-" is stdout. Writes are not audited if
+	// it is empty.
+	AuditLogPath string
+}
+
+// PodOperationLimitsConfiguration limits the rate of the operations of each XStatefulSet. A limit of 0 is unlimited.
+type PodOperationLimitsConfiguration struct {
+	PodCreationsPerMinute   int32
+	PodDeletionsPerMinute   int32
+	ClaimCreationsPerMinute int32
+	// Burst is the number of operations of each kind that may be issued at once.
+	Burst int32
+}
+
+// RateLimiterConfiguration limits how fast XStatefulSets that failed to sync are retried.
+type RateLimiterConfiguration struct {
+	// BaseDelay is the first delay of the per XStatefulSet exponential backoff, MaxDelay its largest.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst limit the overall rate of retries.
+	QPS   float32
+	Burst int32
+}
+
+// WatchConfiguration restricts the objects the controller watches.
+type WatchConfiguration struct {
+	// Namespaces are the namespaces the controller watches. All namespaces are watched if it is empty.
+	Namespaces []string
+	// NamespaceSelector is a label selector that selects the namespaces the controller watches.
+	NamespaceSelector string
+	// ObjectSelector is a label selector that selects the XStatefulSets the controller watches.
+	ObjectSelector string
+}
+
+// ShardingConfiguration splits the XStatefulSets between the replicas of the controller manager.
+type ShardingConfiguration struct {
+	Enabled bool
+	// Name prefixes the names of the Leases of the shards and replicas.
+	Name   string
+	Shards int32
+	// Identity is the unique name of the replica. The hostname is used if it is empty.
+	Identity string
+	// LeaseNamespace is the namespace of the Leases. The namespace of the controller manager is used if it is empty.
+	LeaseNamespace string
+	LeaseDuration  time.Duration
+	RenewDeadline  time.Duration
+	RetryPeriod    time.Duration
 }
 
+// WebhookConfiguration configures the mutating and validating admission webhooks.
 type WebhookConfiguration struct {
-	Port                             int
-	CertDir                          string
-	CertSecretName                   string
-	ServiceName                      string
-	TlsPrivateKey                    string
-	TlsCert                          string
-	MutatingWebhookConfigurationName string
+	Enabled                            bool
+	Port                               int32
+	CertDir                            string
+	CertSecretName                     string
+	ServiceName                        string
+	TLSPrivateKeyFile                  string
+	TLSCertFile                        string
+	MutatingWebhookConfigurationName   string
+	ValidatingWebhookConfigurationName string
+}
+
+// MetricsConfiguration configures the server that exposes the controller manager metrics.
+type MetricsConfiguration struct {
+	// BindAddress is the address the metrics server binds to. "0" disables the metrics server.
+	BindAddress string
+	// SecureServing serves metrics over HTTPS and requires requests to be authenticated and authorized.
+	SecureServing bool
+	// CertDir contains the tls.crt and tls.key used when SecureServing is enabled. A self-signed certificate is
+	// generated if it is empty or the files do not exist.
+	CertDir string
+}
+
+// TracingConfiguration configures the OpenTelemetry tracing of the controller.
+type TracingConfiguration struct {
+	// Endpoint is the OTLP gRPC endpoint of the collector spans are exported to. Tracing is disabled if it is empty.
+	Endpoint string
+	// SamplingRatePerMillion is the number of syncs out of a million that are traced.
+	SamplingRatePerMillion int32
 }
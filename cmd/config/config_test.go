@@ -0,0 +1,182 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestLoadFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		args    []string
+		check   func(t *testing.T, cfg *ControllerManagerConfiguration)
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1alpha1
+kind: ControllerManagerConfiguration
+`,
+			check: func(t *testing.T, cfg *ControllerManagerConfiguration) {
+				if cfg.Controller.Workers != 5 || cfg.Controller.WorkerStallTimeout != 5*time.Minute || cfg.Controller.FairQueuing || cfg.Controller.DryRun {
+					t.Errorf("unexpected controller defaults %+v", cfg.Controller)
+				}
+				if want := (RateLimiterConfiguration{BaseDelay: 5 * time.Millisecond, MaxDelay: 1000 * time.Second, QPS: 10, Burst: 100}); cfg.Controller.RateLimiter != want {
+					t.Errorf("expected rate limiter defaults %+v, got %+v", want, cfg.Controller.RateLimiter)
+				}
+				if cfg.LeaderElection.LeaderElect || cfg.LeaderElection.ResourceName != "lease.xstatefulset.controller-manager" {
+					t.Errorf("unexpected leader election defaults %+v", cfg.LeaderElection)
+				}
+				if !cfg.Webhook.Enabled || cfg.Webhook.Port != 8443 || cfg.Metrics.BindAddress != ":8080" {
+					t.Errorf("unexpected server defaults %+v %+v", cfg.Webhook, cfg.Metrics)
+				}
+			},
+		},
+		{
+			name: "flags override the file",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1alpha1
+kind: ControllerManagerConfiguration
+clientConnection:
+  qps: 50
+  burst: 100
+controller:
+  workers: 10
+  fairQueuing: true
+  rateLimiter:
+    maxDelay: 5m
+leaderElection:
+  leaderElect: true
+  leaseDuration: 30s
+  releaseOnCancel: true
+watch:
+  namespaces: [tenant-a, tenant-b]
+  objectSelector: shard=a
+featureGates:
+  MaxUnavailableStatefulSet: false
+`,
+			args: []string{"--workers=20", "--dry-run", "--audit-log-path=-", "--rate-limiter-qps=2.5", "--leader-elect-renew-deadline=20s", "--feature-gates=StatefulSetSemanticRevisionComparison=false"},
+			check: func(t *testing.T, cfg *ControllerManagerConfiguration) {
+				if cfg.ClientConnection.QPS != 50 || cfg.ClientConnection.Burst != 100 {
+					t.Errorf("unexpected client connection %+v", cfg.ClientConnection)
+				}
+				if cfg.Controller.Workers != 20 {
+					t.Errorf("expected --workers to override the file, got %d workers", cfg.Controller.Workers)
+				}
+				if !cfg.Controller.DryRun || cfg.Controller.AuditLogPath != "-" {
+					t.Errorf("expected --dry-run and --audit-log-path to configure the controller, got %+v", cfg.Controller)
+				}
+				if rl := cfg.Controller.RateLimiter; !cfg.Controller.FairQueuing || rl.MaxDelay != 5*time.Minute || rl.QPS != 2.5 || rl.Burst != 100 {
+					t.Errorf("unexpected queue configuration %+v", cfg.Controller)
+				}
+				le := cfg.LeaderElection
+				if !le.LeaderElect || le.LeaseDuration.Duration != 30*time.Second || le.RenewDeadline.Duration != 20*time.Second || le.RetryPeriod.Duration != 2*time.Second || !le.ReleaseOnCancel {
+					t.Errorf("unexpected leader election %+v", le)
+				}
+				if !slices.Equal(cfg.Watch.Namespaces, []string{"tenant-a", "tenant-b"}) || cfg.Watch.ObjectSelector != "shard=a" {
+					t.Errorf("unexpected watch %+v", cfg.Watch)
+				}
+				// --feature-gates adds to the gates of the file
+				if want := map[string]bool{"MaxUnavailableStatefulSet": false, "StatefulSetSemanticRevisionComparison": false}; !maps.Equal(cfg.FeatureGates, want) {
+					t.Errorf("expected feature gates %v, got %v", want, cfg.FeatureGates)
+				}
+			},
+		},
+		{
+			name: "unknown field",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1alpha1
+kind: ControllerManagerConfiguration
+controller:
+  worker: 10
+`,
+			wantErr: `unknown field "controller.worker"`,
+		},
+		{
+			name: "unknown version",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1
+kind: ControllerManagerConfiguration
+`,
+			wantErr: "no kind",
+		},
+		{
+			name: "invalid",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1alpha1
+kind: ControllerManagerConfiguration
+controller:
+  workers: 0
+leaderElection:
+  leaderElect: true
+  leaseDuration: 5s
+watch:
+  namespaceSelector: "tenant in a"
+`,
+			wantErr: "watch.namespaceSelector: Invalid value",
+		},
+		{
+			name: "sharding with leader election",
+			file: `apiVersion: xstatefulset.config.x-k8s.io/v1alpha1
+kind: ControllerManagerConfiguration
+sharding:
+  enabled: true
+`,
+			args:    []string{"--leader-elect"},
+			wantErr: "sharding.enabled: Forbidden: sharding cannot be combined with leader election",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadFile(path)
+			if err == nil {
+				fs := pflag.NewFlagSet("test", pflag.ContinueOnError)
+				AddFlags(fs, cfg)
+				if err := fs.Parse(tt.args); err != nil {
+					t.Fatalf("Parse() error = %v", err)
+				}
+				err = Validate(cfg)
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestDefaultConfigurationIsValid(t *testing.T) {
+	if err := Validate(NewDefaultConfiguration()); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
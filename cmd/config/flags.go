@@ -0,0 +1,128 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/xsts-sh/xstatefulset/pkg/feature"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	cliflag "k8s.io/component-base/cli/flag"
+	componentbaseoptions "k8s.io/component-base/config/options"
+)
+
+// AddFlags binds the flags of the controller manager to the fields of cfg. The current values of cfg are the
+// defaults of the flags, so flags only override the fields they are given for.
+func AddFlags(fs *pflag.FlagSet, cfg *ControllerManagerConfiguration) {
+	// Client flags
+	fs.StringVar(&cfg.ClientConnection.Kubeconfig, "kubeconfig", cfg.ClientConnection.Kubeconfig, "kubeconfig file path")
+	fs.StringVar(&cfg.MasterURL, "master", cfg.MasterURL, "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
+	fs.Float32Var(&cfg.ClientConnection.QPS, "kube-api-qps", cfg.ClientConnection.QPS, "QPS to use while talking with kubernetes apiserver. If 0, use default value.")
+	fs.Int32Var(&cfg.ClientConnection.Burst, "kube-api-burst", cfg.ClientConnection.Burst, "Burst to use while talking with kubernetes apiserver. If 0, use default value.")
+
+	// Controller flags
+	fs.Int32Var(&cfg.Controller.Workers, "workers", cfg.Controller.Workers, "number of workers to run.")
+	fs.DurationVar(&cfg.Controller.WorkerStallTimeout, "worker-stall-timeout", cfg.Controller.WorkerStallTimeout, "How long the workers may not take a StatefulSet from a non-empty queue before /healthz fails.")
+	fs.DurationVar(&cfg.Controller.RateLimiter.BaseDelay, "rate-limiter-base-delay", cfg.Controller.RateLimiter.BaseDelay, "The delay before a StatefulSet that failed to sync is retried. It doubles with every consecutive failure.")
+	fs.DurationVar(&cfg.Controller.RateLimiter.MaxDelay, "rate-limiter-max-delay", cfg.Controller.RateLimiter.MaxDelay, "The largest delay before a StatefulSet that failed to sync is retried.")
+	fs.Float32Var(&cfg.Controller.RateLimiter.QPS, "rate-limiter-qps", cfg.Controller.RateLimiter.QPS, "The overall rate at which StatefulSets that failed to sync are retried.")
+	fs.Int32Var(&cfg.Controller.RateLimiter.Burst, "rate-limiter-burst", cfg.Controller.RateLimiter.Burst, "The number of retries that may exceed --rate-limiter-qps.")
+	fs.BoolVar(&cfg.Controller.FairQueuing, "fair-queuing", cfg.Controller.FairQueuing, "Sync the queued StatefulSets of the namespaces in turn, instead of in the order they were queued.")
+	fs.BoolVar(&cfg.Controller.DryRun, "dry-run", cfg.Controller.DryRun, "Log the Pod, PersistentVolumeClaim, ControllerRevision and status writes that each sync would make instead of making them.")
+	fs.StringVar(&cfg.Controller.AuditLogPath, "audit-log-path", cfg.Controller.AuditLogPath, "The file a JSON line is appended to for every write of the controller, - for stdout. Writes are not audited if empty.")
+	limits := &cfg.Controller.PodOperationLimits
+	fs.Int32Var(&limits.PodCreationsPerMinute, "pod-creations-per-minute", limits.PodCreationsPerMinute, "The number of Pods of a StatefulSet that may be created per minute. 0 is unlimited.")
+	fs.Int32Var(&limits.PodDeletionsPerMinute, "pod-deletions-per-minute", limits.PodDeletionsPerMinute, "The number of Pods of a StatefulSet that may be deleted per minute. 0 is unlimited.")
+	fs.Int32Var(&limits.ClaimCreationsPerMinute, "claim-creations-per-minute", limits.ClaimCreationsPerMinute, "The number of PersistentVolumeClaims of a StatefulSet that may be created per minute. 0 is unlimited.")
+	fs.Int32Var(&limits.Burst, "pod-operation-burst", limits.Burst, "The number of rate limited operations of each kind that may be issued at once.")
+	fs.StringSliceVar(&cfg.Watch.Namespaces, "namespaces", cfg.Watch.Namespaces, "Only sync the XStatefulSets in these namespaces. All namespaces are watched if empty.")
+	fs.StringVar(&cfg.Watch.NamespaceSelector, "namespace-selector", cfg.Watch.NamespaceSelector, "Only sync the XStatefulSets in namespaces whose labels match this selector, e.g. tenant=a.")
+	fs.StringVar(&cfg.Watch.ObjectSelector, "object-selector", cfg.Watch.ObjectSelector, "Only sync the XStatefulSets whose labels match this selector, e.g. shard=a.")
+	// Sharding flags
+	fs.BoolVar(&cfg.Sharding.Enabled, "sharding", cfg.Sharding.Enabled, "Run the controller in every replica, each syncing the XStatefulSets of the shards it owns. Cannot be combined with --leader-elect.")
+	fs.StringVar(&cfg.Sharding.Name, "sharding-name", cfg.Sharding.Name, "The prefix of the names of the Leases of the shards and replicas.")
+	fs.Int32Var(&cfg.Sharding.Shards, "shards", cfg.Sharding.Shards, "The number of shards the XStatefulSets are hashed to. It must be the same for all replicas.")
+	fs.StringVar(&cfg.Sharding.Identity, "shard-identity", cfg.Sharding.Identity, "The unique name of this replica. Defaults to the hostname.")
+	fs.StringVar(&cfg.Sharding.LeaseNamespace, "shard-lease-namespace", cfg.Sharding.LeaseNamespace, "The namespace of the Leases of the shards. Defaults to the namespace of the controller manager.")
+	fs.DurationVar(&cfg.Sharding.LeaseDuration, "shard-lease-duration", cfg.Sharding.LeaseDuration, "How long a replica owns a shard after it last renewed the Lease of the shard.")
+	fs.DurationVar(&cfg.Sharding.RenewDeadline, "shard-renew-deadline", cfg.Sharding.RenewDeadline, "How long a replica keeps syncing a shard whose Lease it fails to renew.")
+	fs.DurationVar(&cfg.Sharding.RetryPeriod, "shard-retry-period", cfg.Sharding.RetryPeriod, "How often the Leases are renewed and the shards rebalanced.")
+
+	fs.StringVar(&cfg.HealthProbeBindAddress, "health-probe-bind-address", cfg.HealthProbeBindAddress, "The address the /healthz and /readyz endpoints bind to. Set to 0 to disable them.")
+
+	var knownFeatures []string
+	for _, f := range feature.Features() {
+		spec := utilfeature.DefaultMutableFeatureGate.GetAll()[f]
+		knownFeatures = append(knownFeatures, fmt.Sprintf("%s=true|false (%s - default=%t)", f, spec.PreRelease, spec.Default))
+	}
+	fs.Var(&featureGatesValue{gates: &cfg.FeatureGates}, "feature-gates", "A set of key=value pairs that describe feature gates for alpha/experimental features. "+
+		"Gates given on the command line override the gates of the configuration file. Options are:\n"+strings.Join(knownFeatures, "\n"))
+
+	// Leader election flags, only the controller is leader-gated
+	componentbaseoptions.BindLeaderElectionFlags(&cfg.LeaderElection.LeaderElectionConfiguration, fs)
+	fs.BoolVar(&cfg.LeaderElection.ReleaseOnCancel, "leader-elect-release-on-cancel", cfg.LeaderElection.ReleaseOnCancel, "Release the lease when the controller manager shuts down, "+
+		"so that another replica takes over without waiting for the lease to expire. The process must exit right after the manager stops.")
+
+	// Metrics flags
+	fs.StringVar(&cfg.Metrics.BindAddress, "metrics-bind-address", cfg.Metrics.BindAddress, "The address the metrics endpoint binds to. Set to 0 to disable the metrics endpoint.")
+	fs.BoolVar(&cfg.Metrics.SecureServing, "metrics-secure", cfg.Metrics.SecureServing, "Serve metrics over HTTPS, and authenticate and authorize requests against the Kubernetes API server.")
+	fs.StringVar(&cfg.Metrics.CertDir, "metrics-cert-dir", cfg.Metrics.CertDir, "Directory containing tls.crt and tls.key to serve metrics with. A self-signed certificate is generated if empty.")
+
+	// Tracing flags
+	fs.StringVar(&cfg.Tracing.Endpoint, "tracing-endpoint", cfg.Tracing.Endpoint, "The OTLP gRPC endpoint, e.g. localhost:4317, to export traces to. Tracing is disabled if empty.")
+	fs.Int32Var(&cfg.Tracing.SamplingRatePerMillion, "tracing-sampling-rate-per-million", cfg.Tracing.SamplingRatePerMillion, "The number of syncs out of a million that are traced.")
+
+	// Webhook flags
+	fs.BoolVar(&cfg.Webhook.Enabled, "enable-webhook", cfg.Webhook.Enabled, "Enable the mutating and validating admission webhooks of XStatefulSets.")
+	fs.Int32Var(&cfg.Webhook.Port, "webhook-port", cfg.Webhook.Port, "Port that the webhook server listens on")
+	fs.StringVar(&cfg.Webhook.ServiceName, "service-name", cfg.Webhook.ServiceName, "Service name for the webhook server")
+	fs.StringVar(&cfg.Webhook.CertDir, "webhook-cert-dir", cfg.Webhook.CertDir, "Directory containing webhook TLS certificates")
+	fs.StringVar(&cfg.Webhook.TLSCertFile, "tls-cert-file", cfg.Webhook.TLSCertFile, "File containing the x509 Certificate for HTTPS")
+	fs.StringVar(&cfg.Webhook.TLSPrivateKeyFile, "tls-private-key-file", cfg.Webhook.TLSPrivateKeyFile, "File containing the x509 private key to --tls-cert-file")
+	fs.StringVar(&cfg.Webhook.MutatingWebhookConfigurationName, "mutating-webhook-name", cfg.Webhook.MutatingWebhookConfigurationName, "Name of the mutating webhook configuration")
+	fs.StringVar(&cfg.Webhook.ValidatingWebhookConfigurationName, "validating-webhook-name", cfg.Webhook.ValidatingWebhookConfigurationName, "Name of the validating webhook configuration")
+	fs.StringVar(&cfg.Webhook.CertSecretName, "webhook-cert-secret", cfg.Webhook.CertSecretName, "Name of the secret containing webhook certificates")
+}
+
+// featureGatesValue is the value of the --feature-gates flag. Unlike cliflag.MapStringBool, it adds the gates it is
+// set to to the gates of the configuration file instead of replacing them.
+type featureGatesValue struct {
+	gates *map[string]bool
+}
+
+func (v *featureGatesValue) String() string {
+	return cliflag.NewMapStringBool(v.gates).String()
+}
+
+func (v *featureGatesValue) Set(value string) error {
+	gates := map[string]bool{}
+	if err := cliflag.NewMapStringBool(&gates).Set(value); err != nil {
+		return err
+	}
+	if *v.gates == nil {
+		*v.gates = map[string]bool{}
+	}
+	maps.Copy(*v.gates, gates)
+	return nil
+}
+
+func (v *featureGatesValue) Type() string {
+	return "mapStringBool"
+}
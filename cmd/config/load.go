@@ -0,0 +1,141 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/xsts-sh/xstatefulset/cmd/config/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	componentbaseconfigv1alpha1 "k8s.io/component-base/config/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+var (
+	scheme = runtime.NewScheme()
+	// codecs rejects unknown and duplicate fields, so that typos in the configuration file are not ignored.
+	codecs = serializer.NewCodecFactory(scheme, serializer.EnableStrict)
+)
+
+func init() {
+	utilruntime.Must(v1alpha1.AddToScheme(scheme))
+}
+
+// NewDefaultConfiguration returns the configuration the controller manager runs with if neither a configuration
+// file nor flags are given.
+func NewDefaultConfiguration() *ControllerManagerConfiguration {
+	versioned := &v1alpha1.ControllerManagerConfiguration{}
+	scheme.Default(versioned)
+	cfg, err := convertFromV1alpha1(versioned)
+	utilruntime.Must(err)
+	return cfg
+}
+
+// LoadFile reads the configuration file at path and fills in the defaults of the fields it does not set.
+func LoadFile(path string) (*ControllerManagerConfiguration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read configuration file: %w", err)
+	}
+	obj, gvk, err := codecs.UniversalDecoder(v1alpha1.SchemeGroupVersion).Decode(data, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decode configuration file %s: %w", path, err)
+	}
+	versioned, ok := obj.(*v1alpha1.ControllerManagerConfiguration)
+	if !ok {
+		return nil, fmt.Errorf("configuration file %s has unsupported kind %s", path, gvk)
+	}
+	return convertFromV1alpha1(versioned)
+}
+
+// convertFromV1alpha1 converts the defaulted versioned configuration in to the configuration the controller
+// manager runs with.
+func convertFromV1alpha1(in *v1alpha1.ControllerManagerConfiguration) (*ControllerManagerConfiguration, error) {
+	out := &ControllerManagerConfiguration{}
+	if err := componentbaseconfigv1alpha1.Convert_v1alpha1_ClientConnectionConfiguration_To_config_ClientConnectionConfiguration(&in.ClientConnection, &out.ClientConnection, nil); err != nil {
+		return nil, err
+	}
+	if err := componentbaseconfigv1alpha1.Convert_v1alpha1_LeaderElectionConfiguration_To_config_LeaderElectionConfiguration(&in.LeaderElection.LeaderElectionConfiguration, &out.LeaderElection.LeaderElectionConfiguration, nil); err != nil {
+		return nil, err
+	}
+	out.LeaderElection.ReleaseOnCancel = ptr.Deref(in.LeaderElection.ReleaseOnCancel, false)
+	out.Controller = ControllerConfiguration{
+		Workers: ptr.Deref(in.Controller.Workers, 0),
+		RateLimiter: RateLimiterConfiguration{
+			BaseDelay: ptr.Deref(in.Controller.RateLimiter.BaseDelay, metav1.Duration{}).Duration,
+			MaxDelay:  ptr.Deref(in.Controller.RateLimiter.MaxDelay, metav1.Duration{}).Duration,
+			QPS:       ptr.Deref(in.Controller.RateLimiter.QPS, 0),
+			Burst:     ptr.Deref(in.Controller.RateLimiter.Burst, 0),
+		},
+		FairQueuing: ptr.Deref(in.Controller.FairQueuing, false),
+		PodOperationLimits: PodOperationLimitsConfiguration{
+			PodCreationsPerMinute:   ptr.Deref(in.Controller.PodOperationLimits.PodCreationsPerMinute, 0),
+			PodDeletionsPerMinute:   ptr.Deref(in.Controller.PodOperationLimits.PodDeletionsPerMinute, 0),
+			ClaimCreationsPerMinute: ptr.Deref(in.Controller.PodOperationLimits.ClaimCreationsPerMinute, 0),
+			Burst:                   ptr.Deref(in.Controller.PodOperationLimits.Burst, 0),
+		},
+		DryRun:       ptr.Deref(in.Controller.DryRun, false),
+		AuditLogPath: in.Controller.AuditLogPath,
+	}
+	if in.Controller.WorkerStallTimeout != nil {
+		out.Controller.WorkerStallTimeout = in.Controller.WorkerStallTimeout.Duration
+	}
+	out.Watch = WatchConfiguration{
+		Namespaces:        slices.Clone(in.Watch.Namespaces),
+		NamespaceSelector: in.Watch.NamespaceSelector,
+		ObjectSelector:    in.Watch.ObjectSelector,
+	}
+	out.Sharding = ShardingConfiguration{
+		Enabled:        ptr.Deref(in.Sharding.Enabled, false),
+		Name:           in.Sharding.Name,
+		Shards:         ptr.Deref(in.Sharding.Shards, 0),
+		Identity:       in.Sharding.Identity,
+		LeaseNamespace: in.Sharding.LeaseNamespace,
+		LeaseDuration:  ptr.Deref(in.Sharding.LeaseDuration, metav1.Duration{}).Duration,
+		RenewDeadline:  ptr.Deref(in.Sharding.RenewDeadline, metav1.Duration{}).Duration,
+		RetryPeriod:    ptr.Deref(in.Sharding.RetryPeriod, metav1.Duration{}).Duration,
+	}
+	out.Webhook = WebhookConfiguration{
+		Enabled:                            ptr.Deref(in.Webhook.Enabled, false),
+		Port:                               ptr.Deref(in.Webhook.Port, 0),
+		CertDir:                            in.Webhook.CertDir,
+		CertSecretName:                     in.Webhook.CertSecretName,
+		ServiceName:                        in.Webhook.ServiceName,
+		TLSPrivateKeyFile:                  in.Webhook.TLSPrivateKeyFile,
+		TLSCertFile:                        in.Webhook.TLSCertFile,
+		MutatingWebhookConfigurationName:   in.Webhook.MutatingWebhookConfigurationName,
+		ValidatingWebhookConfigurationName: in.Webhook.ValidatingWebhookConfigurationName,
+	}
+	out.Metrics = MetricsConfiguration{
+		BindAddress:   in.Metrics.BindAddress,
+		SecureServing: ptr.Deref(in.Metrics.SecureServing, false),
+		CertDir:       in.Metrics.CertDir,
+	}
+	out.HealthProbeBindAddress = in.HealthProbeBindAddress
+	out.Tracing = TracingConfiguration{
+		Endpoint:               in.Tracing.Endpoint,
+		SamplingRatePerMillion: ptr.Deref(in.Tracing.SamplingRatePerMillion, 0),
+	}
+	out.FeatureGates = maps.Clone(in.FeatureGates)
+	return out, nil
+}
@@ -0,0 +1,165 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	componentbaseconfigv1alpha1 "k8s.io/component-base/config/v1alpha1"
+	"k8s.io/utils/ptr"
+)
+
+// DefaultLeaseName is the lease the controller has always been elected with, so that replicas of older and newer
+// versions never run the controller at the same time during an upgrade.
+const DefaultLeaseName = "lease.xstatefulset.controller-manager"
+
+func addDefaultingFuncs(scheme *runtime.Scheme) error {
+	return RegisterDefaults(scheme)
+}
+
+func SetDefaults_ControllerManagerConfiguration(obj *ControllerManagerConfiguration) {
+	// unlike the recommended defaults, a single replica does not need to be elected
+	if obj.LeaderElection.LeaderElect == nil {
+		obj.LeaderElection.LeaderElect = ptr.To(false)
+	}
+	if obj.LeaderElection.ResourceLock == "" {
+		obj.LeaderElection.ResourceLock = resourcelock.LeasesResourceLock
+	}
+	if obj.LeaderElection.ResourceName == "" {
+		obj.LeaderElection.ResourceName = DefaultLeaseName
+	}
+	componentbaseconfigv1alpha1.RecommendedDefaultLeaderElectionConfiguration(&obj.LeaderElection.LeaderElectionConfiguration)
+	if obj.LeaderElection.ReleaseOnCancel == nil {
+		obj.LeaderElection.ReleaseOnCancel = ptr.To(false)
+	}
+
+	if obj.HealthProbeBindAddress == "" {
+		obj.HealthProbeBindAddress = ":9443"
+	}
+	if obj.Tracing.SamplingRatePerMillion == nil {
+		obj.Tracing.SamplingRatePerMillion = ptr.To[int32](10000)
+	}
+}
+
+func SetDefaults_ControllerConfiguration(obj *ControllerConfiguration) {
+	if obj.Workers == nil {
+		obj.Workers = ptr.To[int32](5)
+	}
+	if obj.WorkerStallTimeout == nil {
+		obj.WorkerStallTimeout = &metav1.Duration{Duration: 5 * time.Minute}
+	}
+	if obj.FairQueuing == nil {
+		obj.FairQueuing = ptr.To(false)
+	}
+	if obj.DryRun == nil {
+		obj.DryRun = ptr.To(false)
+	}
+}
+
+func SetDefaults_PodOperationLimitsConfiguration(obj *PodOperationLimitsConfiguration) {
+	if obj.PodCreationsPerMinute == nil {
+		obj.PodCreationsPerMinute = ptr.To[int32](0)
+	}
+	if obj.PodDeletionsPerMinute == nil {
+		obj.PodDeletionsPerMinute = ptr.To[int32](0)
+	}
+	if obj.ClaimCreationsPerMinute == nil {
+		obj.ClaimCreationsPerMinute = ptr.To[int32](0)
+	}
+	if obj.Burst == nil {
+		obj.Burst = ptr.To[int32](1)
+	}
+}
+
+// SetDefaults_RateLimiterConfiguration defaults to the rate limiter of workqueue.DefaultTypedControllerRateLimiter.
+func SetDefaults_RateLimiterConfiguration(obj *RateLimiterConfiguration) {
+	if obj.BaseDelay == nil {
+		obj.BaseDelay = &metav1.Duration{Duration: 5 * time.Millisecond}
+	}
+	if obj.MaxDelay == nil {
+		obj.MaxDelay = &metav1.Duration{Duration: 1000 * time.Second}
+	}
+	if obj.QPS == nil {
+		obj.QPS = ptr.To[float32](10)
+	}
+	if obj.Burst == nil {
+		obj.Burst = ptr.To[int32](100)
+	}
+}
+
+func SetDefaults_ShardingConfiguration(obj *ShardingConfiguration) {
+	if obj.Enabled == nil {
+		obj.Enabled = ptr.To(false)
+	}
+	if obj.Name == "" {
+		obj.Name = "xstatefulset"
+	}
+	if obj.Shards == nil {
+		obj.Shards = ptr.To[int32](32)
+	}
+	if obj.LeaseDuration == nil {
+		obj.LeaseDuration = &metav1.Duration{Duration: 15 * time.Second}
+	}
+	if obj.RenewDeadline == nil {
+		obj.RenewDeadline = &metav1.Duration{Duration: 10 * time.Second}
+	}
+	if obj.RetryPeriod == nil {
+		obj.RetryPeriod = &metav1.Duration{Duration: 2 * time.Second}
+	}
+}
+
+func SetDefaults_WebhookConfiguration(obj *WebhookConfiguration) {
+	if obj.Enabled == nil {
+		obj.Enabled = ptr.To(true)
+	}
+	if obj.Port == nil {
+		obj.Port = ptr.To[int32](8443)
+	}
+	if obj.CertDir == "" {
+		obj.CertDir = "/etc/tls"
+	}
+	if obj.TLSCertFile == "" {
+		obj.TLSCertFile = "/etc/tls/tls.crt"
+	}
+	if obj.TLSPrivateKeyFile == "" {
+		obj.TLSPrivateKeyFile = "/etc/tls/tls.key"
+	}
+	if obj.CertSecretName == "" {
+		obj.CertSecretName = "xstatefulset-webhook-server-cert"
+	}
+	if obj.ServiceName == "" {
+		obj.ServiceName = "xstatefulset-controller-manager-webhook"
+	}
+	if obj.MutatingWebhookConfigurationName == "" {
+		obj.MutatingWebhookConfigurationName = "xstatefulset-mutating-webhook"
+	}
+	if obj.ValidatingWebhookConfigurationName == "" {
+		obj.ValidatingWebhookConfigurationName = "xstatefulset-validating-webhook"
+	}
+}
+
+func SetDefaults_MetricsConfiguration(obj *MetricsConfiguration) {
+	if obj.BindAddress == "" {
+		obj.BindAddress = ":8080"
+	}
+	if obj.SecureServing == nil {
+		obj.SecureServing = ptr.To(false)
+	}
+}
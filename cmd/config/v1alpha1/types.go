@@ -0,0 +1,221 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	componentbaseconfigv1alpha1 "k8s.io/component-base/config/v1alpha1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ControllerManagerConfiguration is the configuration file of the xstatefulset controller manager. Flags given on
+// the command line override the values of the file.
+type ControllerManagerConfiguration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// clientConnection configures the connection to the Kubernetes API server. A qps or burst of 0 uses the
+	// defaults of client-go.
+	ClientConnection componentbaseconfigv1alpha1.ClientConnectionConfiguration `json:"clientConnection"`
+	// leaderElection configures the election of the replica that runs the controller. The webhook is served by
+	// every replica. Leader election is disabled by default.
+	LeaderElection LeaderElectionConfiguration `json:"leaderElection"`
+	// controller configures the xstatefulset controller.
+	Controller ControllerConfiguration `json:"controller"`
+	// watch restricts the objects the controller watches.
+	// +optional
+	Watch WatchConfiguration `json:"watch,omitempty"`
+	// sharding splits the XStatefulSets between the replicas of the controller manager.
+	// +optional
+	Sharding ShardingConfiguration `json:"sharding,omitempty"`
+	// webhook configures the admission webhooks that default and validate XStatefulSets.
+	Webhook WebhookConfiguration `json:"webhook"`
+	// metrics configures the server that exposes the controller manager metrics.
+	Metrics MetricsConfiguration `json:"metrics"`
+	// healthProbeBindAddress is the address /healthz and /readyz are served on. "0" disables the probes.
+	HealthProbeBindAddress string `json:"healthProbeBindAddress"`
+	// tracing configures the OpenTelemetry tracing of the controller.
+	// +optional
+	Tracing TracingConfiguration `json:"tracing,omitempty"`
+	// featureGates is a map of feature names to bools that enable or disable alpha and beta features.
+	// +optional
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// LeaderElectionConfiguration configures the election of the replica that runs the controller.
+type LeaderElectionConfiguration struct {
+	componentbaseconfigv1alpha1.LeaderElectionConfiguration `json:",inline"`
+	// releaseOnCancel releases the lease when the controller manager shuts down, so that another replica takes over
+	// without waiting for the lease to expire.
+	// +optional
+	ReleaseOnCancel *bool `json:"releaseOnCancel,omitempty"`
+}
+
+// ControllerConfiguration configures the xstatefulset controller.
+type ControllerConfiguration struct {
+	// workers is the number of XStatefulSets that are synced concurrently.
+	Workers *int32 `json:"workers,omitempty"`
+	// workerStallTimeout is how long the workers may not take a key from a non-empty queue before the controller
+	// is reported unhealthy.
+	WorkerStallTimeout *metav1.Duration `json:"workerStallTimeout,omitempty"`
+	// rateLimiter limits how fast XStatefulSets that failed to sync are retried.
+	// +optional
+	RateLimiter RateLimiterConfiguration `json:"rateLimiter,omitempty"`
+	// fairQueuing syncs the queued XStatefulSets of the namespaces in turn, so that many XStatefulSets of one
+	// namespace that are synced repeatedly do not delay the XStatefulSets of all other namespaces.
+	// +optional
+	FairQueuing *bool `json:"fairQueuing,omitempty"`
+	// podOperationLimits limits the rate of the pod and PersistentVolumeClaim operations of each XStatefulSet.
+	// +optional
+	PodOperationLimits PodOperationLimitsConfiguration `json:"podOperationLimits,omitempty"`
+	// dryRun logs the pod, PersistentVolumeClaim, ControllerRevision and status writes that each sync would make
+	// instead of making them, e.g. to check that the controller deletes no adopted pods before enabling it.
+	// +optional
+	DryRun *bool `json:"dryRun,omitempty"`
+	// auditLogPath is the file a JSON line is appended to for every pod, PersistentVolumeClaim, ControllerRevision
+	// and status write of the controller, "-" writes them to stdout. Writes are not audited if it is empty.
+	// +optional
+	AuditLogPath string `json:"auditLogPath,omitempty"`
+}
+
+// PodOperationLimitsConfiguration limits the rate of the operations of each XStatefulSet, so that large XStatefulSets
+// do not overwhelm the components that act on them, e.g. a CSI provisioner. An XStatefulSet whose operations are
+// throttled is synced again once they are allowed. A limit of 0 is unlimited.
+type PodOperationLimitsConfiguration struct {
+	// podCreationsPerMinute is the number of pods of an XStatefulSet that may be created per minute.
+	// +optional
+	PodCreationsPerMinute *int32 `json:"podCreationsPerMinute,omitempty"`
+	// podDeletionsPerMinute is the number of pods of an XStatefulSet that may be deleted per minute. Pods on
+	// unreachable nodes are force deleted regardless of the limit.
+	// +optional
+	PodDeletionsPerMinute *int32 `json:"podDeletionsPerMinute,omitempty"`
+	// claimCreationsPerMinute is the number of PersistentVolumeClaims of an XStatefulSet that may be created per
+	// minute.
+	// +optional
+	ClaimCreationsPerMinute *int32 `json:"claimCreationsPerMinute,omitempty"`
+	// burst is the number of operations of each kind that may be issued at once. It defaults to 1, which spreads
+	// the operations evenly over the minute.
+	// +optional
+	Burst *int32 `json:"burst,omitempty"`
+}
+
+// RateLimiterConfiguration configures the rate limiter of the work queue of the controller. An XStatefulSet is
+// retried after the larger of its own backoff and the delay of the overall limit.
+type RateLimiterConfiguration struct {
+	// baseDelay is the delay before an XStatefulSet that failed to sync is retried. It doubles with every
+	// consecutive failure, up to maxDelay.
+	// +optional
+	BaseDelay *metav1.Duration `json:"baseDelay,omitempty"`
+	// maxDelay is the largest delay before an XStatefulSet that failed to sync is retried.
+	// +optional
+	MaxDelay *metav1.Duration `json:"maxDelay,omitempty"`
+	// qps is the overall rate at which XStatefulSets that failed to sync are retried.
+	// +optional
+	QPS *float32 `json:"qps,omitempty"`
+	// burst is the number of retries that may exceed qps.
+	// +optional
+	Burst *int32 `json:"burst,omitempty"`
+}
+
+// WatchConfiguration restricts the objects the controller watches, so that several controllers can share a cluster
+// without syncing the same XStatefulSets. The restrictions are combined.
+type WatchConfiguration struct {
+	// namespaces are the namespaces whose XStatefulSets are synced. All namespaces are watched if it is empty. A
+	// single namespace is watched with namespaced informers and namespaced RBAC suffices.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// namespaceSelector is a label selector, e.g. "tenant=a", that selects the namespaces whose XStatefulSets are
+	// synced. The controller needs to list and watch namespaces to use it.
+	// +optional
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// objectSelector is a label selector, e.g. "shard in (a,b)", that selects the XStatefulSets that are synced.
+	// +optional
+	ObjectSelector string `json:"objectSelector,omitempty"`
+}
+
+// ShardingConfiguration splits the XStatefulSets between the replicas of the controller manager. Every XStatefulSet
+// is hashed to a shard, and every shard is synced by the replica that holds its Lease. Shards are rebalanced when
+// replicas join or leave.
+type ShardingConfiguration struct {
+	// enabled runs the controller in every replica. It cannot be combined with leader election.
+	Enabled *bool `json:"enabled,omitempty"`
+	// name prefixes the names of the Leases of the shards and replicas. Deployments that shard different
+	// XStatefulSets, see watch, need different names.
+	Name string `json:"name,omitempty"`
+	// shards is the number of shards. It must be the same for all replicas, and several times the number of
+	// replicas for the XStatefulSets to be spread evenly.
+	Shards *int32 `json:"shards,omitempty"`
+	// identity is the unique name of the replica. It defaults to the hostname.
+	// +optional
+	Identity string `json:"identity,omitempty"`
+	// leaseNamespace is the namespace of the Leases. It defaults to the namespace the controller manager runs in.
+	// +optional
+	LeaseNamespace string `json:"leaseNamespace,omitempty"`
+	// leaseDuration is how long a replica owns a shard after it last renewed the Lease of the shard.
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+	// renewDeadline is how long a replica keeps syncing a shard whose Lease it fails to renew. It must be less
+	// than leaseDuration.
+	RenewDeadline *metav1.Duration `json:"renewDeadline,omitempty"`
+	// retryPeriod is how often the Leases are renewed and the shards rebalanced.
+	RetryPeriod *metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// WebhookConfiguration configures the mutating and validating admission webhooks.
+type WebhookConfiguration struct {
+	// enabled serves the webhook.
+	Enabled *bool `json:"enabled,omitempty"`
+	// port is the port the webhook server listens on.
+	Port *int32 `json:"port,omitempty"`
+	// certDir is the directory containing the serving certificate of the webhook.
+	CertDir string `json:"certDir,omitempty"`
+	// tlsCertFile is the file containing the x509 certificate of the webhook.
+	TLSCertFile string `json:"tlsCertFile,omitempty"`
+	// tlsPrivateKeyFile is the file containing the x509 private key of tlsCertFile.
+	TLSPrivateKeyFile string `json:"tlsPrivateKeyFile,omitempty"`
+	// certSecretName is the name of the secret a certificate is generated into if the certificate files do not
+	// exist.
+	CertSecretName string `json:"certSecretName,omitempty"`
+	// serviceName is the name of the service the webhook is reached through.
+	ServiceName string `json:"serviceName,omitempty"`
+	// mutatingWebhookConfigurationName is the name of the MutatingWebhookConfiguration whose CA bundle is kept up
+	// to date.
+	MutatingWebhookConfigurationName string `json:"mutatingWebhookConfigurationName,omitempty"`
+	// validatingWebhookConfigurationName is the name of the ValidatingWebhookConfiguration whose CA bundle is kept
+	// up to date.
+	ValidatingWebhookConfigurationName string `json:"validatingWebhookConfigurationName,omitempty"`
+}
+
+// MetricsConfiguration configures the server that exposes the controller manager metrics.
+type MetricsConfiguration struct {
+	// bindAddress is the address the metrics server binds to. "0" disables the metrics server.
+	BindAddress string `json:"bindAddress,omitempty"`
+	// secureServing serves metrics over HTTPS and requires requests to be authenticated and authorized.
+	SecureServing *bool `json:"secureServing,omitempty"`
+	// certDir contains the tls.crt and tls.key used when secureServing is enabled. A self-signed certificate is
+	// generated if it is empty or the files do not exist.
+	// +optional
+	CertDir string `json:"certDir,omitempty"`
+}
+
+// TracingConfiguration configures the OpenTelemetry tracing of the controller.
+type TracingConfiguration struct {
+	// endpoint is the OTLP gRPC endpoint of the collector spans are exported to. Tracing is disabled if it is empty.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// samplingRatePerMillion is the number of syncs out of a million that are traced.
+	SamplingRatePerMillion *int32 `json:"samplingRatePerMillion,omitempty"`
+}
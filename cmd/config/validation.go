@@ -0,0 +1,132 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate returns an error if cfg cannot be run with.
+func Validate(cfg *ControllerManagerConfiguration) error {
+	var allErrs field.ErrorList
+
+	if cfg.ClientConnection.QPS < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("clientConnection", "qps"), cfg.ClientConnection.QPS, "must be non-negative"))
+	}
+	if cfg.ClientConnection.Burst < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("clientConnection", "burst"), cfg.ClientConnection.Burst, "must be non-negative"))
+	}
+
+	// the lease namespace is not required, the manager falls back to the namespace it runs in
+	if le, fldPath := cfg.LeaderElection, field.NewPath("leaderElection"); le.LeaderElect {
+		if le.LeaseDuration.Duration <= le.RenewDeadline.Duration {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("leaseDuration"), le.LeaseDuration, "must be greater than renewDeadline"))
+		}
+		if le.RenewDeadline.Duration <= le.RetryPeriod.Duration {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("renewDeadline"), le.RenewDeadline, "must be greater than retryPeriod"))
+		}
+		if le.RetryPeriod.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("retryPeriod"), le.RetryPeriod, "must be greater than zero"))
+		}
+		if le.ResourceLock == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("resourceLock"), ""))
+		}
+		if le.ResourceName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("resourceName"), ""))
+		}
+	}
+
+	if cfg.Controller.Workers <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("controller", "workers"), cfg.Controller.Workers, "must be greater than zero"))
+	}
+	if cfg.Controller.WorkerStallTimeout <= 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("controller", "workerStallTimeout"), cfg.Controller.WorkerStallTimeout.String(), "must be greater than zero"))
+	}
+	rl, fldPath := cfg.Controller.RateLimiter, field.NewPath("controller", "rateLimiter")
+	if rl.BaseDelay <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("baseDelay"), rl.BaseDelay.String(), "must be greater than zero"))
+	}
+	if rl.MaxDelay < rl.BaseDelay {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxDelay"), rl.MaxDelay.String(), "must not be less than baseDelay"))
+	}
+	if rl.QPS <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("qps"), rl.QPS, "must be greater than zero"))
+	}
+	if rl.Burst <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("burst"), rl.Burst, "must be greater than zero"))
+	}
+	limits, fldPath := cfg.Controller.PodOperationLimits, field.NewPath("controller", "podOperationLimits")
+	if limits.PodCreationsPerMinute < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("podCreationsPerMinute"), limits.PodCreationsPerMinute, "must not be negative"))
+	}
+	if limits.PodDeletionsPerMinute < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("podDeletionsPerMinute"), limits.PodDeletionsPerMinute, "must not be negative"))
+	}
+	if limits.ClaimCreationsPerMinute < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("claimCreationsPerMinute"), limits.ClaimCreationsPerMinute, "must not be negative"))
+	}
+	if limits.Burst <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("burst"), limits.Burst, "must be greater than zero"))
+	}
+
+	for i, ns := range cfg.Watch.Namespaces {
+		for _, msg := range validation.IsDNS1123Label(ns) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("watch", "namespaces").Index(i), ns, msg))
+		}
+	}
+	if _, err := labels.Parse(cfg.Watch.NamespaceSelector); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("watch", "namespaceSelector"), cfg.Watch.NamespaceSelector, err.Error()))
+	}
+	if _, err := labels.Parse(cfg.Watch.ObjectSelector); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("watch", "objectSelector"), cfg.Watch.ObjectSelector, err.Error()))
+	}
+
+	if sh, fldPath := cfg.Sharding, field.NewPath("sharding"); sh.Enabled {
+		if cfg.LeaderElection.LeaderElect {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("enabled"), "sharding cannot be combined with leader election"))
+		}
+		if sh.Shards <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("shards"), sh.Shards, "must be greater than zero"))
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(sh.Name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("name"), sh.Name, msg))
+		}
+		if sh.LeaseDuration <= sh.RenewDeadline {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("leaseDuration"), sh.LeaseDuration.String(), "must be greater than renewDeadline"))
+		}
+		if sh.RenewDeadline <= sh.RetryPeriod {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("renewDeadline"), sh.RenewDeadline.String(), "must be greater than retryPeriod"))
+		}
+		if sh.RetryPeriod <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("retryPeriod"), sh.RetryPeriod.String(), "must be greater than zero"))
+		}
+	}
+
+	if cfg.Webhook.Enabled {
+		for _, msg := range validation.IsValidPortNum(int(cfg.Webhook.Port)) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("webhook", "port"), cfg.Webhook.Port, msg))
+		}
+	}
+
+	if rate := cfg.Tracing.SamplingRatePerMillion; rate < 0 || rate > 1000000 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("tracing", "samplingRatePerMillion"), rate, "must be between 0 and 1000000"))
+	}
+
+	return allErrs.ToAggregate()
+}
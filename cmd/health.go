@@ -0,0 +1,166 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/xsts-sh/xstatefulset/cmd/config"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
+	"github.com/xsts-sh/xstatefulset/pkg/webhook/cert"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/utils/clock"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// leaderElectionHealthTimeout is how long the lease may go without being renewed by the leader, beyond the lease
+// duration, before the leader is reported unhealthy.
+const leaderElectionHealthTimeout = 20 * time.Second
+
+// healthChecks are the liveness and readiness checks of the controller manager.
+type healthChecks struct {
+	cfg *config.ControllerManagerConfiguration
+	// controller is set once the controller has been started. Instances that are not the leader never start it.
+	controller atomic.Pointer[xstatefulset.StatefulSetController]
+	// leaderElection records the renewals of the lease, elected is closed once this instance is the leader. They are
+	// nil if leader election is disabled.
+	leaderElection *renewalRecordingLock
+	elected        <-chan struct{}
+
+	healthz map[string]healthz.Checker
+	readyz  map[string]healthz.Checker
+}
+
+func newHealthChecks(cfg *config.ControllerManagerConfiguration) *healthChecks {
+	hc := &healthChecks{
+		cfg:     cfg,
+		healthz: map[string]healthz.Checker{"ping": healthz.Ping},
+		readyz:  map[string]healthz.Checker{"ping": healthz.Ping},
+	}
+	hc.healthz["workers"] = hc.checkWorkers
+	hc.readyz["informers"] = hc.checkInformers
+	return hc
+}
+
+// addWebhookChecks makes the controller manager ready only once the webhook server has been started with a
+// certificate that has not expired. options are the options the webhook server was created with.
+func (hc *healthChecks) addWebhookChecks(options ctrlwebhook.Options, startedChecker healthz.Checker) {
+	certFile := filepath.Join(options.CertDir, options.CertName)
+	hc.readyz["webhook-certificate"] = func(_ *http.Request) error {
+		return cert.CheckCertificateFile(certFile, time.Now())
+	}
+	hc.readyz["webhook"] = startedChecker
+}
+
+// addLeaderElectionCheck makes the leader unhealthy once it fails to renew the lease of lock, while elected is closed.
+func (hc *healthChecks) addLeaderElectionCheck(lock *renewalRecordingLock, elected <-chan struct{}) {
+	hc.leaderElection, hc.elected = lock, elected
+	hc.healthz["leader-election"] = hc.checkLeaderElection
+}
+
+// checkLeaderElection fails if this instance is the leader and has not renewed its lease for longer than the lease
+// duration and leaderElectionHealthTimeout, like leaderelection.HealthzAdaptor does, so that a leader whose renewals
+// are stuck is restarted. Instances that are not the leader are healthy.
+func (hc *healthChecks) checkLeaderElection(_ *http.Request) error {
+	select {
+	case <-hc.elected:
+	default:
+		return nil
+	}
+	last := hc.leaderElection.lastRenewal.Load()
+	if last == nil {
+		return nil
+	}
+	if since := hc.leaderElection.clock.Since(*last); since > hc.cfg.LeaderElection.LeaseDuration.Duration+leaderElectionHealthTimeout {
+		return fmt.Errorf("the leader election lease was last renewed %v ago", since.Round(time.Second))
+	}
+	return nil
+}
+
+// checkWorkers fails if the workers of the controller stopped making progress.
+func (hc *healthChecks) checkWorkers(_ *http.Request) error {
+	if ssc := hc.controller.Load(); ssc != nil {
+		return ssc.CheckHealth(hc.cfg.Controller.WorkerStallTimeout)
+	}
+	return nil
+}
+
+// checkInformers fails until the informers of the controller have synced. Instances that are waiting to become the
+// leader are ready, so that they can serve the webhook.
+func (hc *healthChecks) checkInformers(_ *http.Request) error {
+	if ssc := hc.controller.Load(); ssc != nil {
+		return ssc.CheckReady()
+	}
+	if hc.cfg.LeaderElection.LeaderElect {
+		return nil
+	}
+	return fmt.Errorf("controller has not been started")
+}
+
+// install adds the checks to the /healthz and /readyz endpoints served by mgr.
+func (hc *healthChecks) install(mgr ctrl.Manager) error {
+	for name, check := range hc.healthz {
+		if err := mgr.AddHealthzCheck(name, check); err != nil {
+			return fmt.Errorf("unable to set up health check %s: %w", name, err)
+		}
+	}
+	for name, check := range hc.readyz {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			return fmt.Errorf("unable to set up ready check %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// renewalRecordingLock records when this instance last wrote the lease of the leader election with the embedded lock.
+// The manager does not expose its leader elector to a leaderelection.HealthzAdaptor, so the renewals are observed
+// through the lock instead.
+type renewalRecordingLock struct {
+	resourcelock.Interface
+	clock       clock.PassiveClock
+	lastRenewal atomic.Pointer[time.Time]
+}
+
+func newRenewalRecordingLock(lock resourcelock.Interface, clock clock.PassiveClock) *renewalRecordingLock {
+	return &renewalRecordingLock{Interface: lock, clock: clock}
+}
+
+func (l *renewalRecordingLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Create(ctx, ler)
+	l.recordRenewal(err)
+	return err
+}
+
+func (l *renewalRecordingLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	err := l.Interface.Update(ctx, ler)
+	l.recordRenewal(err)
+	return err
+}
+
+func (l *renewalRecordingLock) recordRenewal(err error) {
+	if err == nil {
+		now := l.clock.Now()
+		l.lastRenewal.Store(&now)
+	}
+}
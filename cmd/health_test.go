@@ -0,0 +1,90 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xsts-sh/xstatefulset/cmd/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// fakeLock fails its writes with err.
+type fakeLock struct {
+	resourcelock.Interface
+	err error
+}
+
+func (l *fakeLock) Create(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	return l.err
+}
+
+func (l *fakeLock) Update(ctx context.Context, ler resourcelock.LeaderElectionRecord) error {
+	return l.err
+}
+
+func TestLeaderElectionCheck(t *testing.T) {
+	ctx := context.Background()
+	cfg := &config.ControllerManagerConfiguration{}
+	cfg.LeaderElection.LeaderElect = true
+	cfg.LeaderElection.LeaseDuration = metav1.Duration{Duration: 15 * time.Second}
+	clock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	fake := &fakeLock{}
+	lock := newRenewalRecordingLock(fake, clock)
+	elected := make(chan struct{})
+	hc := newHealthChecks(cfg)
+	hc.addLeaderElectionCheck(lock, elected)
+	check := hc.healthz["leader-election"]
+
+	// an instance that is not the leader is healthy, whether or not it writes the lease
+	clock.Step(time.Hour)
+	if err := check(nil); err != nil {
+		t.Fatalf("expected a candidate to be healthy, got %v", err)
+	}
+
+	if err := lock.Create(ctx, resourcelock.LeaderElectionRecord{}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	close(elected)
+	clock.Step(30 * time.Second)
+	if err := check(nil); err != nil {
+		t.Errorf("expected a leader that renewed its lease recently to be healthy, got %v", err)
+	}
+
+	// failed renewals do not count
+	fake.err = errors.New("timeout")
+	if err := lock.Update(ctx, resourcelock.LeaderElectionRecord{}); err == nil {
+		t.Fatalf("expected the update to fail")
+	}
+	clock.Step(10 * time.Second)
+	if err := check(nil); err == nil {
+		t.Errorf("expected a leader that has not renewed its lease for 40s to be unhealthy")
+	}
+
+	fake.err = nil
+	if err := lock.Update(ctx, resourcelock.LeaderElectionRecord{}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if err := check(nil); err != nil {
+		t.Errorf("expected the leader to be healthy once it renewed its lease, got %v", err)
+	}
+}
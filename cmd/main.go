@@ -20,9 +20,9 @@ import (
 	"context"
 	"flag"
 	"fmt"
-
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -32,24 +32,36 @@ import (
 	xstatefulsetclientset "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned"
 	"github.com/xsts-sh/xstatefulset/cmd/config"
 	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/sharding"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
-	"github.com/xsts-sh/xstatefulset/pkg/utils"
+	"github.com/xsts-sh/xstatefulset/pkg/feature"
+	"github.com/xsts-sh/xstatefulset/pkg/metrics"
 	"github.com/xsts-sh/xstatefulset/pkg/webhook"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 
-	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/tools/leaderelection"
-	"k8s.io/client-go/tools/leaderelection/resourcelock"
+	"k8s.io/client-go/tools/record"
+	featuremetrics "k8s.io/component-base/metrics/prometheus/feature"
+	"k8s.io/component-base/tracing"
+	tracingapi "k8s.io/component-base/tracing/api/v1"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/leaderelection"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -57,14 +69,6 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 )
 
-const (
-	defaultLeaseDuration = 15 * time.Second
-	defaultRenewDeadline = 10 * time.Second
-	defaultRetryPeriod   = 2 * time.Second
-	leaderElectionId     = "xstatefulset.controller-manager"
-	leaseName            = "lease.xstatefulset.controller-manager"
-)
-
 var (
 	scheme = runtime.NewScheme()
 )
@@ -75,41 +79,38 @@ func init() {
 }
 
 func main() {
-	var enableWebhook bool
-	var cc config.Config
-	var wc config.WebhookConfiguration
-
 	// Initialize klog flags first
 	klog.InitFlags(nil)
 
-	// Add controller flags
-	pflag.StringVar(&cc.Kubeconfig, "kubeconfig", "", "kubeconfig file path")
-	pflag.StringVar(&cc.MasterURL, "master", "", "The address of the Kubernetes API server. Overrides any value in kubeconfig. Only required if out-of-cluster.")
-	pflag.BoolVar(&cc.EnableLeaderElection, "leader-elect", false, "Enable leader election for controller. "+
-		"Enabling this will ensure there is only one active controller. Default is false.")
-	pflag.IntVar(&cc.Workers, "workers", 5, "number of workers to run. Default is 5")
-	pflag.Float32Var(&cc.KubeAPIQPS, "kube-api-qps", 0, "QPS to use while talking with kubernetes apiserver. If 0, use default value.")
-	pflag.IntVar(&cc.KubeAPIBurst, "kube-api-burst", 0, "Burst to use while talking with kubernetes apiserver. If 0, use default value.")
-
-	// Webhook flags
-	pflag.BoolVar(&enableWebhook, "enable-webhook", true, "Enable mutating admission webhook for defaulting. Default is true.")
-	pflag.IntVar(&wc.Port, "webhook-port", 8443, "Port that the webhook server listens on")
-	pflag.StringVar(&wc.ServiceName, "service-name", "xstatefulset-controller-manager-webhook", "Service name for the webhook server")
-	pflag.StringVar(&wc.CertDir, "webhook-cert-dir", "/etc/tls", "Directory containing webhook TLS certificates")
-	pflag.StringVar(&wc.TlsCert, "tls-cert-file", "/etc/tls/tls.crt", "File containing the x509 Certificate for HTTPS")
-	pflag.StringVar(&wc.TlsPrivateKey, "tls-private-key-file", "/etc/tls/tls.key", "File containing the x509 private key to --tls-cert-file")
-	pflag.StringVar(&wc.MutatingWebhookConfigurationName, "mutating-webhook-name", "xstatefulset-mutating-webhook", "Name of the mutating webhook configuration")
-	pflag.StringVar(&wc.CertSecretName, "webhook-cert-secret", "xstatefulset-webhook-server-cert", "Name of the secret containing webhook certificates")
-
-	// Add go flags (klog) to pflag, but skip flags that are already defined
-	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
-
+	cfg := config.NewDefaultConfiguration()
+	var configFile string
+	fs := pflag.CommandLine
+	bindFlags(fs, cfg, &configFile)
 	pflag.Parse()
 
-	pflag.CommandLine.VisitAll(func(f *pflag.Flag) {
+	if configFile != "" {
+		var err error
+		if cfg, err = config.LoadFile(configFile); err != nil {
+			klog.Fatalf("load configuration: %v", err)
+		}
+		// Parse the command line again on top of the file, flags override the values of the file
+		fs = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+		bindFlags(fs, cfg, &configFile)
+		_ = fs.Parse(os.Args[1:])
+		klog.Infof("Loaded configuration file %s", configFile)
+	}
+
+	fs.VisitAll(func(f *pflag.Flag) {
 		klog.Infof("Flag: %s, Value: %s", f.Name, f.Value.String())
 	})
 
+	if err := config.Validate(cfg); err != nil {
+		klog.Fatalf("invalid configuration: %v", err)
+	}
+	if err := setFeatureGates(cfg.FeatureGates); err != nil {
+		klog.Fatalf("set feature gates: %v", err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	ch := make(chan os.Signal, 1)
@@ -121,67 +122,231 @@ func main() {
 	}()
 
 	// Build kubeconfig
-	cfg, err := clientcmd.BuildConfigFromFlags(cc.MasterURL, cc.Kubeconfig)
+	restConfig, err := clientcmd.BuildConfigFromFlags(cfg.MasterURL, cfg.ClientConnection.Kubeconfig)
 	if err != nil {
 		klog.Fatalf("build client config: %v", err)
 	}
 
-	// Set QPS and Burst if provided
-	if cc.KubeAPIQPS > 0 {
-		cfg.QPS = cc.KubeAPIQPS
+	// Set QPS, Burst and content types if provided
+	if cfg.ClientConnection.QPS > 0 {
+		restConfig.QPS = cfg.ClientConnection.QPS
+	}
+	if cfg.ClientConnection.Burst > 0 {
+		restConfig.Burst = int(cfg.ClientConnection.Burst)
 	}
-	if cc.KubeAPIBurst > 0 {
-		cfg.Burst = cc.KubeAPIBurst
+	if cfg.ClientConnection.AcceptContentTypes != "" {
+		restConfig.AcceptContentTypes = cfg.ClientConnection.AcceptContentTypes
+	}
+	if cfg.ClientConnection.ContentType != "" {
+		restConfig.ContentType = cfg.ClientConnection.ContentType
+	}
+
+	shutdownTracing, err := setupTracing(ctx, restConfig, cfg.Tracing)
+	if err != nil {
+		klog.Fatalf("set up tracing: %v", err)
 	}
 
-	g, ctx := errgroup.WithContext(ctx)
+	klog.Info("Starting xstatefulset controller manager")
+	err = runManager(ctx, restConfig, cfg)
+	klog.Info("Shutting down xstatefulset controller manager")
 
-	// Setup controller-runtime manager if webhook is enabled
-	if enableWebhook {
-		g.Go(func() error {
-			klog.Info("Starting with webhook enabled using controller-runtime")
-			return setupWebhookWithManager(ctx, cfg, cc, wc)
-		})
+	// flush the spans of the last syncs
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		klog.Errorf("Error shutting down tracing: %v", err)
+	}
+	if err != nil {
+		klog.Fatalf("Error running controller manager: %v", err)
 	}
+}
 
-	g.Go(func() error {
-		klog.Info("Starting xstatefulset controller manager")
-		defer klog.Info("Shutting down xstatefulset controller manager")
-		return setupController(ctx, cc)
-	})
+// bindFlags binds the flags of the controller manager, the --config flag and the klog flags to fs.
+func bindFlags(fs *pflag.FlagSet, cfg *config.ControllerManagerConfiguration, configFile *string) {
+	fs.StringVar(configFile, "config", *configFile, "The path to the configuration file. Flags override the values of the file.")
+	config.AddFlags(fs, cfg)
+	// Add go flags (klog) to pflag
+	fs.AddGoFlagSet(flag.CommandLine)
+}
 
-	if err := g.Wait(); err != nil {
-		klog.Fatalf("Error running components: %v", err)
+// setFeatureGates overrides the defaults of the feature gates with gates, logs the gates of the controller and
+// records them in the kubernetes_feature_enabled metric.
+func setFeatureGates(gates map[string]bool) error {
+	if err := utilfeature.DefaultMutableFeatureGate.SetFromMap(gates); err != nil {
+		return err
 	}
+	specs := utilfeature.DefaultMutableFeatureGate.GetAll()
+	for _, f := range feature.Features() {
+		enabled := utilfeature.DefaultFeatureGate.Enabled(f)
+		klog.InfoS("Feature gate", "feature", f, "stage", specs[f].PreRelease, "enabled", enabled)
+		featuremetrics.RecordFeatureInfo(context.Background(), string(f), string(specs[f].PreRelease), enabled)
+	}
+	return nil
 }
 
-func setupController(ctx context.Context, cc config.Config) error {
-	cfg, err := clientcmd.BuildConfigFromFlags(cc.MasterURL, cc.Kubeconfig)
+// setupTracing installs a TracerProvider that exports the spans of the controller to the OTLP endpoint of tc, and
+// makes the clients built from cfg propagate the trace context to the API server. The returned function flushes and
+// shuts down the TracerProvider.
+func setupTracing(ctx context.Context, cfg *rest.Config, tc config.TracingConfiguration) (func(context.Context) error, error) {
+	if tc.Endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	tp, err := tracing.NewProvider(ctx, &tracingapi.TracingConfiguration{
+		Endpoint:               ptr.To(tc.Endpoint),
+		SamplingRatePerMillion: ptr.To(tc.SamplingRatePerMillion),
+	}, nil, []resource.Option{resource.WithAttributes(semconv.ServiceNameKey.String("xstatefulset-controller-manager"))})
+	if err != nil {
+		return nil, fmt.Errorf("create tracer provider: %w", err)
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(tracing.Propagators())
+	cfg.Wrap(tracing.WrapperFor(tp))
+	klog.Infof("Exporting traces to %s", tc.Endpoint)
+	return tp.Shutdown, nil
+}
+
+// runManager runs the metrics endpoint, the health probes, the webhook and the controller under a single
+// controller-runtime manager until ctx is done. Every replica serves the webhook, only the elected leader runs the
+// controller.
+func runManager(ctx context.Context, restConfig *rest.Config, cfg *config.ControllerManagerConfiguration) error {
+	// Set up controller-runtime logger to use klog
+	ctrl.SetLogger(klog.NewKlogr())
+	metrics.InstallLegacyRegistry()
+
+	metricsOptions := metricsserver.Options{
+		BindAddress:   cfg.Metrics.BindAddress,
+		SecureServing: cfg.Metrics.SecureServing,
+		CertDir:       cfg.Metrics.CertDir,
+	}
+	if cfg.Metrics.SecureServing {
+		metricsOptions.FilterProvider = filters.WithAuthenticationAndAuthorization
+	}
+	options := ctrl.Options{
+		Scheme:                        scheme,
+		Metrics:                       metricsOptions,
+		HealthProbeBindAddress:        cfg.HealthProbeBindAddress,
+		LeaderElection:                cfg.LeaderElection.LeaderElect,
+		LeaderElectionID:              cfg.LeaderElection.ResourceName,
+		LeaderElectionNamespace:       cfg.LeaderElection.ResourceNamespace,
+		LeaderElectionResourceLock:    cfg.LeaderElection.ResourceLock,
+		LeaderElectionReleaseOnCancel: cfg.LeaderElection.ReleaseOnCancel,
+		LeaseDuration:                 &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:                 &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:                   &cfg.LeaderElection.RetryPeriod.Duration,
+	}
+	var leaderElectionLock *renewalRecordingLock
+	if cfg.LeaderElection.LeaderElect {
+		lock, err := newLeaderElectionLock(restConfig, cfg)
+		if err != nil {
+			return err
+		}
+		leaderElectionLock = lock
+		options.LeaderElectionResourceLockInterface = lock
+	}
+	webhookOptions := webhookServerOptions(cfg.Webhook)
+	if cfg.Webhook.Enabled {
+		options.WebhookServer = ctrlwebhook.NewServer(webhookOptions)
+	}
+	mgr, err := ctrl.NewManager(restConfig, options)
 	if err != nil {
-		return fmt.Errorf("build client config: %v", err)
+		return fmt.Errorf("unable to create manager: %w", err)
+	}
+
+	hc := newHealthChecks(cfg)
+	if leaderElectionLock != nil {
+		hc.addLeaderElectionCheck(leaderElectionLock, mgr.Elected())
+	}
+	if cfg.Webhook.Enabled {
+		klog.Info("Setting up webhook")
+		if err := setupWebhook(ctx, restConfig, mgr, cfg.Webhook); err != nil {
+			return err
+		}
+		hc.addWebhookChecks(webhookOptions, mgr.GetWebhookServer().StartedChecker())
+	}
+	if err := hc.install(mgr); err != nil {
+		return err
+	}
+	if err := addController(mgr, restConfig, cfg, hc); err != nil {
+		return err
 	}
-	// Set QPS and Burst if provided
-	if cc.KubeAPIQPS > 0 {
-		cfg.QPS = cc.KubeAPIQPS
+
+	// Start the manager (this blocks)
+	if err := mgr.Start(ctx); err != nil {
+		return fmt.Errorf("manager error: %w", err)
 	}
-	if cc.KubeAPIBurst > 0 {
-		cfg.Burst = cc.KubeAPIBurst
+	return nil
+}
+
+// addController adds the xstatefulset controller to mgr. The controller is only started once this replica has been
+// elected leader, if leader election is enabled.
+func addController(mgr ctrl.Manager, restConfig *rest.Config, cfg *config.ControllerManagerConfiguration, hc *healthChecks) error {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	xStatefulSetClient, err := xstatefulsetclientset.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create xstatefulset client: %w", err)
 	}
-	kubeClient := kubernetes.NewForConfigOrDie(cfg)
 
-	xStatefulSetClient := xstatefulsetclientset.NewForConfigOrDie(cfg)
+	// the selectors have been validated with the configuration
+	namespaceSelector, err := labels.Parse(cfg.Watch.NamespaceSelector)
+	if err != nil {
+		return err
+	}
+	objectSelector, err := labels.Parse(cfg.Watch.ObjectSelector)
+	if err != nil {
+		return err
+	}
+	watchOptions := controller.WatchOptions{
+		Namespaces:        cfg.Watch.Namespaces,
+		NamespaceSelector: namespaceSelector,
+		ObjectSelector:    objectSelector,
+	}
 
-	startControllers := func(ctx context.Context) {
-		controllerContext := controller.NewControllerContext(ctx, kubeClient, xStatefulSetClient)
+	sharder, err := newSharder(kubeClient, cfg.Sharding)
+	if err != nil {
+		return err
+	}
+	auditLog, err := newAuditLog(cfg.Controller.AuditLogPath)
+	if err != nil {
+		return err
+	}
 
-		ssc := xstatefulset.NewStatefulSetController(
+	run := manager.RunnableFunc(func(ctx context.Context) error {
+		controllerContext := controller.NewControllerContext(ctx, kubeClient, xStatefulSetClient, watchOptions)
+
+		options := []xstatefulset.Option{
+			xstatefulset.WithNamespaceFilter(controllerContext.NamespaceFilter),
+			xstatefulset.WithSharder(sharder),
+			xstatefulset.WithQueueOptions(controller.QueueOptions{
+				BaseDelay:   cfg.Controller.RateLimiter.BaseDelay,
+				MaxDelay:    cfg.Controller.RateLimiter.MaxDelay,
+				QPS:         float64(cfg.Controller.RateLimiter.QPS),
+				Burst:       int(cfg.Controller.RateLimiter.Burst),
+				FairQueuing: cfg.Controller.FairQueuing,
+			}),
+			xstatefulset.WithPodOperationLimits(xstatefulset.PodOperationLimits{
+				PodCreationsPerMinute:   cfg.Controller.PodOperationLimits.PodCreationsPerMinute,
+				PodDeletionsPerMinute:   cfg.Controller.PodOperationLimits.PodDeletionsPerMinute,
+				ClaimCreationsPerMinute: cfg.Controller.PodOperationLimits.ClaimCreationsPerMinute,
+				Burst:                   cfg.Controller.PodOperationLimits.Burst,
+			}),
+		}
+		if auditLog != nil {
+			options = append(options, xstatefulset.WithAuditLog(auditLog))
+		}
+		if cfg.Controller.DryRun {
+			klog.Info("Running the controller in dry-run mode, syncs are logged instead of made")
+			options = append(options, xstatefulset.WithDryRun())
+		}
+		ssc := xstatefulset.NewController(
 			ctx,
-			controllerContext.KubeInformerFactory.Core().V1().Pods(),
-			controllerContext.XStatefulsetInformerFactory.Apps().V1().XStatefulSets(),
-			controllerContext.KubeInformerFactory.Core().V1().PersistentVolumeClaims(),
-			controllerContext.KubeInformerFactory.Apps().V1().ControllerRevisions(),
 			kubeClient,
-			xStatefulSetClient)
+			xStatefulSetClient,
+			controllerContext.KubeInformerFactory,
+			controllerContext.XStatefulsetInformerFactory,
+			options...)
 
 		// Start the informers
 		stopCh := ctx.Done()
@@ -189,87 +354,126 @@ func setupController(ctx context.Context, cc config.Config) error {
 		controllerContext.XStatefulsetInformerFactory.Start(stopCh)
 		close(controllerContext.InformersStarted)
 
-		go ssc.Run(ctx, cc.Workers)
-		klog.Info("XStatefulSet controller started")
-	}
-
-	if cc.EnableLeaderElection {
-		startedLeading := func(ctx context.Context) {
-			startControllers(ctx)
-			klog.Info("Start as leader")
+		// the shards are released once the workers have stopped
+		var sharderDone chan struct{}
+		if sharder != nil {
+			sharderDone = make(chan struct{})
+			go func() {
+				defer close(sharderDone)
+				sharder.Run(ctx)
+			}()
 		}
-		leaderElector, err := initLeaderElector(kubeClient, startedLeading)
-		if err != nil {
-			return fmt.Errorf("init leader elector: %w", err)
+
+		hc.controller.Store(ssc)
+		klog.Info("XStatefulSet controller started")
+		ssc.Run(ctx, int(cfg.Controller.Workers))
+		if sharderDone != nil {
+			<-sharderDone
 		}
-		leaderElector.Run(ctx)
-	} else {
-		startControllers(ctx)
-		klog.Info("Started controllers without leader election")
+		return nil
+	})
+	if sharder != nil {
+		// every replica runs the controller for the shards it owns
+		return mgr.Add(unelectedRunnable{run})
 	}
-	<-ctx.Done()
-	return nil
+	// Runnables that do not implement LeaderElectionRunnable need leader election.
+	return mgr.Add(run)
+}
+
+// unelectedRunnable is a Runnable that runs in every replica, whether or not it has been elected leader.
+type unelectedRunnable struct {
+	manager.RunnableFunc
+}
+
+func (unelectedRunnable) NeedLeaderElection() bool {
+	return false
 }
 
-// initLeaderElector inits a leader elector for leader election
-func initLeaderElector(kubeClient kubernetes.Interface, startedLeading func(ctx context.Context)) (*leaderelection.LeaderElector, error) {
-	resourceLock, err := newResourceLock(kubeClient)
+// newLeaderElectionLock creates the lock of the leader election as the manager would, and records its renewals for
+// the leader election health check.
+func newLeaderElectionLock(restConfig *rest.Config, cfg *config.ControllerManagerConfiguration) (*renewalRecordingLock, error) {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
-		return nil, err
-	}
-	leaderElector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
-		Lock:          resourceLock,
-		LeaseDuration: defaultLeaseDuration,
-		RenewDeadline: defaultRenewDeadline,
-		RetryPeriod:   defaultRetryPeriod,
-		Callbacks: leaderelection.LeaderCallbacks{
-			OnStartedLeading: startedLeading,
-			OnStoppedLeading: func() {
-				klog.Error("leader election lost")
-			},
-		},
-		ReleaseOnCancel: false,
-		Name:            leaderElectionId,
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: kubeClient.CoreV1().Events("")})
+	lock, err := leaderelection.NewResourceLock(rest.CopyConfig(restConfig), eventRecorderProvider{broadcaster}, leaderelection.Options{
+		LeaderElection:             true,
+		LeaderElectionResourceLock: cfg.LeaderElection.ResourceLock,
+		LeaderElectionID:           cfg.LeaderElection.ResourceName,
+		LeaderElectionNamespace:    cfg.LeaderElection.ResourceNamespace,
+		RenewDeadline:              cfg.LeaderElection.RenewDeadline.Duration,
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to create leader election lock: %w", err)
 	}
-	return leaderElector, nil
+	return newRenewalRecordingLock(lock, clock.RealClock{}), nil
 }
 
-// newResourceLock returns a lease lock which is used to elect leader
-func newResourceLock(client kubernetes.Interface) (*resourcelock.LeaseLock, error) {
-	namespace, err := utils.GetInClusterNameSpace()
-	if err != nil {
-		return nil, err
-	}
-	// Leader id, should be unique
-	id, err := os.Hostname()
-	if err != nil {
-		return nil, err
-	}
-	id = id + "_" + string(uuid.NewUUID())
-	return &resourcelock.LeaseLock{
-		LeaseMeta: metav1.ObjectMeta{
-			Name:      leaseName,
-			Namespace: namespace,
-		},
-		Client: client.CoordinationV1(),
-		LockConfig: resourcelock.ResourceLockConfig{
-			Identity: id,
-		},
-	}, nil
+// eventRecorderProvider records the events of the leader election lock with broadcaster.
+type eventRecorderProvider struct {
+	broadcaster record.EventBroadcaster
 }
 
-// setupWebhookWithManager sets up the webhook using controller-runtime manager
-func setupWebhookWithManager(ctx context.Context, cfg *rest.Config, cc config.Config, wc config.WebhookConfiguration) error {
-	klog.Info("Setting up controller-runtime manager with webhook")
+func (p eventRecorderProvider) GetEventRecorderFor(name string) record.EventRecorder {
+	return p.broadcaster.NewRecorder(scheme, corev1.EventSource{Component: name})
+}
 
-	// Set up controller-runtime logger to use klog
-	ctrl.SetLogger(klog.NewKlogr())
+// newSharder returns the Sharder that splits the XStatefulSets between the replicas, or nil if sharding is disabled.
+func newSharder(kubeClient kubernetes.Interface, sc config.ShardingConfiguration) (*sharding.Sharder, error) {
+	if !sc.Enabled {
+		return nil, nil
+	}
+	identity := sc.Identity
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("unable to get hostname for the shard identity: %w", err)
+		}
+		identity = hostname
+	}
+	namespace := sc.LeaseNamespace
+	if namespace == "" {
+		namespace = getNamespace()
+	}
+	if namespace == "" {
+		return nil, fmt.Errorf("the namespace of the shard leases is unknown, set POD_NAMESPACE or the lease namespace")
+	}
+	klog.Infof("Sharding the controller as %s with %d shards in namespace %s", identity, sc.Shards, namespace)
+	return sharding.NewSharder(kubeClient.CoordinationV1(), sharding.Options{
+		Name:          sc.Name,
+		Namespace:     namespace,
+		Identity:      identity,
+		Shards:        int(sc.Shards),
+		LeaseDuration: sc.LeaseDuration,
+		RenewDeadline: sc.RenewDeadline,
+		RetryPeriod:   sc.RetryPeriod,
+	})
+}
 
+// newAuditLog returns the AuditLog that appends to the file path, or to stdout if path is "-". It returns nil if path
+// is empty. The file stays open for the lifetime of the process.
+func newAuditLog(path string) (*xstatefulset.AuditLog, error) {
+	switch path {
+	case "":
+		return nil, nil
+	case "-":
+		klog.Info("Auditing the writes of the controller to stdout")
+		return xstatefulset.NewAuditLog(os.Stdout), nil
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	klog.Infof("Auditing the writes of the controller to %s", path)
+	return xstatefulset.NewAuditLog(file), nil
+}
+
+// setupWebhook provisions the serving certificate of the webhook and registers the webhook with mgr.
+func setupWebhook(ctx context.Context, restConfig *rest.Config, mgr ctrl.Manager, wc config.WebhookConfiguration) error {
 	// Create Kubernetes client for certificate management
-	kubeClient, err := kubernetes.NewForConfig(cfg)
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
@@ -286,7 +490,7 @@ func setupWebhookWithManager(ctx context.Context, cfg *rest.Config, cc config.Co
 	}
 
 	if caBundle == nil {
-		if !fileExists(wc.TlsPrivateKey) || !fileExists(wc.TlsCert) {
+		if !fileExists(wc.TLSPrivateKeyFile) || !fileExists(wc.TLSCertFile) {
 			bytes, err := ensureWebhookCertificate(ctx, kubeClient, wc)
 			if err != nil {
 				return fmt.Errorf("error ensuring webhook certificate: %w", err)
@@ -299,52 +503,24 @@ func setupWebhookWithManager(ctx context.Context, cfg *rest.Config, cc config.Co
 		if cert.UpdateMutatingWebhookCABundle(ctx, kubeClient, wc.MutatingWebhookConfigurationName, caBundle) != nil {
 			return fmt.Errorf("Error updating mutating webhook certificate: %v", err)
 		}
+		if err := cert.UpdateValidatingWebhookCABundle(ctx, kubeClient, wc.ValidatingWebhookConfigurationName, caBundle); err != nil {
+			return fmt.Errorf("error updating validating webhook certificate: %w", err)
+		}
 	}
 
 	// Wait for both cert and key files to exist (in case they are mounted by Kubernetes)
-	ok := waitForCertsReady(wc.TlsPrivateKey, wc.TlsCert)
+	ok := waitForCertsReady(wc.TLSPrivateKeyFile, wc.TLSCertFile)
 	if !ok {
 		return fmt.Errorf("TLS cert/key files not found, webhook server cannot start")
 	}
 
-	// Create manager with webhook server
-	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
-		Scheme: scheme,
-		Metrics: metricsserver.Options{
-			BindAddress: "0", // Disable metrics server as we're not using it yet
-		},
-		WebhookServer: ctrlwebhook.NewServer(ctrlwebhook.Options{
-			Port:    wc.Port,
-			CertDir: wc.CertDir,
-		}),
-		HealthProbeBindAddress: ":9443",
-		LeaderElection:         cc.EnableLeaderElection,
-		LeaderElectionID:       leaderElectionId,
-	})
-	if err != nil {
-		return fmt.Errorf("unable to create manager: %w", err)
-	}
-
 	// Setup webhook
 	if err := (&webhook.XStatefulSetDefaulter{}).SetupWebhookWithManager(mgr); err != nil {
 		return fmt.Errorf("unable to setup webhook: %w", err)
 	}
-
-	// Add health check endpoints
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		return fmt.Errorf("unable to set up health check: %w", err)
-	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		return fmt.Errorf("unable to set up ready check: %w", err)
+	if err := (&webhook.XStatefulSetValidator{}).SetupWebhookWithManager(mgr); err != nil {
+		return fmt.Errorf("unable to setup validating webhook: %w", err)
 	}
-
-	klog.Info("Webhook setup complete, starting manager")
-
-	// Start the manager (this blocks)
-	if err := mgr.Start(ctx); err != nil {
-		return fmt.Errorf("manager error: %w", err)
-	}
-
 	return nil
 }
 
@@ -363,6 +539,24 @@ func fileExists(path string) bool {
 }
 
 // ensureWebhookCertificate generates a certificate into the secret and returns the CA bundle.
+// webhookServerOptions returns the options of the webhook server. It serves the certificate and key files of wc from
+// the certificate directory of wc.
+func webhookServerOptions(wc config.WebhookConfiguration) ctrlwebhook.Options {
+	options := ctrlwebhook.Options{
+		Port:     int(wc.Port),
+		CertDir:  wc.CertDir,
+		CertName: "tls.crt",
+		KeyName:  "tls.key",
+	}
+	if wc.TLSCertFile != "" {
+		options.CertName = filepath.Base(wc.TLSCertFile)
+	}
+	if wc.TLSPrivateKeyFile != "" {
+		options.KeyName = filepath.Base(wc.TLSPrivateKeyFile)
+	}
+	return options
+}
+
 func ensureWebhookCertificate(ctx context.Context, kubeClient kubernetes.Interface, wc config.WebhookConfiguration) ([]byte, error) {
 	namespace := getNamespace()
 	dnsNames := []string{
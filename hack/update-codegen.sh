@@ -33,6 +33,9 @@ THIS_PKG="github.com/xsts-sh/xstatefulset"
 kube::codegen::gen_helpers \
     --boilerplate "${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
     "${SCRIPT_ROOT}/api"
+kube::codegen::gen_helpers \
+    --boilerplate "${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
+    "${SCRIPT_ROOT}/cmd/config"
 
 # Generate defaulter code explicitly
 # This ensures SetObjectDefaults_* wrapper functions are generated
@@ -41,7 +44,7 @@ GOPROXY=off go install k8s.io/code-generator/cmd/defaulter-gen
 # The result file of defaulter generation
 DEFAULTER_OUTPUT_FILE="zz_generated.defaults.go"
 # Find all directories that request defaulter generation
-DEFAULTER_DIRS=$(find "${SCRIPT_ROOT}/api" -name "doc.go" -exec grep -l "+k8s:defaulter-gen=" {} \; | xargs -n1 dirname | sort -u)
+DEFAULTER_DIRS=$(find "${SCRIPT_ROOT}/api" "${SCRIPT_ROOT}/cmd/config" -name "doc.go" -exec grep -l "+k8s:defaulter-gen=" {} \; | xargs -n1 dirname | sort -u)
 
 if [ -n "${DEFAULTER_DIRS}" ]; then
     DEFAULTER_PKGS=""
@@ -52,7 +55,7 @@ if [ -n "${DEFAULTER_DIRS}" ]; then
     done
     echo "Running defaulter-gen for: ${DEFAULTER_PKGS}"
     # Remove old generated defaulter files
-    find "${SCRIPT_ROOT}/api" -name "${DEFAULTER_OUTPUT_FILE}" -delete
+    find "${SCRIPT_ROOT}/api" "${SCRIPT_ROOT}/cmd/config" -name "${DEFAULTER_OUTPUT_FILE}" -delete
     # Run defaulter-gen
     defaulter-gen \
         --go-header-file "${SCRIPT_ROOT}/hack/boilerplate.go.txt" \
@@ -24,6 +24,7 @@ import (
 	clientset "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned"
 	xStatefulSetInformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 )
@@ -39,13 +40,17 @@ type ControllerContext struct {
 	// for an individual controller to start the shared informers. Before it is closed, they should not.
 	InformersStarted chan struct{}
 
+	// NamespaceFilter decides which namespaces the controllers watch. It is nil if all namespaces are watched.
+	NamespaceFilter *NamespaceFilter
+
 	// ResyncPeriod generates a duration each time it is invoked; this is so that
 	// multiple controllers don't get into lock-step and all hammer the apiserver
 	// with list requests simultaneously.
 	ResyncPeriod func() time.Duration
 }
 
-func NewControllerContext(ctx context.Context, versionedClient kubernetes.Interface, xStatefulSetClient clientset.Interface) *ControllerContext {
+// NewControllerContext returns the informer factories of the controllers, restricted to the objects opts selects.
+func NewControllerContext(ctx context.Context, versionedClient kubernetes.Interface, xStatefulSetClient clientset.Interface, opts WatchOptions) *ControllerContext {
 	// Informer transform to trim ManagedFields for memory efficiency.
 	trim := func(obj interface{}) (interface{}, error) {
 		if accessor, err := meta.Accessor(obj); err == nil {
@@ -55,11 +60,25 @@ func NewControllerContext(ctx context.Context, versionedClient kubernetes.Interf
 		}
 		return obj, nil
 	}
-	kubeSharedInformers := informers.NewSharedInformerFactoryWithOptions(versionedClient, 0, informers.WithTransform(trim))
-	xStafulsetInformer := xStatefulSetInformers.NewSharedInformerFactoryWithOptions(xStatefulSetClient, 0, xStatefulSetInformers.WithTransform(trim))
+	kubeOptions := []informers.SharedInformerOption{informers.WithTransform(trim)}
+	xStatefulSetOptions := []xStatefulSetInformers.SharedInformerOption{xStatefulSetInformers.WithTransform(trim)}
+	if len(opts.Namespaces) == 1 {
+		kubeOptions = append(kubeOptions, informers.WithNamespace(opts.Namespaces[0]))
+		xStatefulSetOptions = append(xStatefulSetOptions, xStatefulSetInformers.WithNamespace(opts.Namespaces[0]))
+	}
+	if opts.ObjectSelector != nil && !opts.ObjectSelector.Empty() {
+		// sets whose labels stop matching are deleted from the cache
+		selector := opts.ObjectSelector.String()
+		xStatefulSetOptions = append(xStatefulSetOptions, xStatefulSetInformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = selector
+		}))
+	}
+	kubeSharedInformers := informers.NewSharedInformerFactoryWithOptions(versionedClient, 0, kubeOptions...)
+	xStafulsetInformer := xStatefulSetInformers.NewSharedInformerFactoryWithOptions(xStatefulSetClient, 0, xStatefulSetOptions...)
 	return &ControllerContext{
 		KubeInformerFactory:         kubeSharedInformers,
 		XStatefulsetInformerFactory: xStafulsetInformer,
+		NamespaceFilter:             newNamespaceFilter(opts, kubeSharedInformers),
 		InformersStarted:            make(chan struct{}),
 	}
 }
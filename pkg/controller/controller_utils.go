@@ -178,6 +178,8 @@ type ControllerExpectationsInterface interface {
 // ControllerExpectations is a cache mapping controllers to what they expect to see before being woken up for a sync.
 type ControllerExpectations struct {
 	cache.Store
+	// clock times when expectations are set and expire.
+	clock clock.PassiveClock
 }
 
 // GetExpectations returns the ControlleeExpectations of the given controller.
@@ -207,7 +209,7 @@ func (r *ControllerExpectations) SatisfiedExpectations(logger klog.Logger, contr
 		if exp.Fulfilled() {
 			logger.V(4).Info("Controller expectations fulfilled", "expectations", exp)
 			return true
-		} else if exp.isExpired() {
+		} else if exp.isExpired(r.clock) {
 			logger.V(4).Info("Controller expectations expired", "expectations", exp)
 			return true
 		} else {
@@ -231,14 +233,13 @@ func (r *ControllerExpectations) SatisfiedExpectations(logger klog.Logger, contr
 
 // TODO: Extend ExpirationCache to support explicit expiration.
 // TODO: Make this possible to disable in tests.
-// TODO: Support injection of clock.
-func (exp *ControlleeExpectations) isExpired() bool {
-	return clock.RealClock{}.Since(exp.timestamp) > ExpectationsTimeout
+func (exp *ControlleeExpectations) isExpired(clock clock.PassiveClock) bool {
+	return clock.Since(exp.timestamp) > ExpectationsTimeout
 }
 
 // SetExpectations registers new expectations for the given controller. Forgets existing expectations.
 func (r *ControllerExpectations) SetExpectations(logger klog.Logger, controllerKey string, add, del int) error {
-	exp := &ControlleeExpectations{add: int64(add), del: int64(del), key: controllerKey, timestamp: clock.RealClock{}.Now()}
+	exp := &ControlleeExpectations{add: int64(add), del: int64(del), key: controllerKey, timestamp: r.clock.Now()}
 	logger.V(4).Info("Setting expectations", "expectations", exp)
 	return r.Add(exp)
 }
@@ -322,7 +323,12 @@ func (e *ControlleeExpectations) MarshalLog() interface{} {
 
 // NewControllerExpectations returns a store for ControllerExpectations.
 func NewControllerExpectations() *ControllerExpectations {
-	return &ControllerExpectations{cache.NewStore(ExpKeyFunc)}
+	return NewControllerExpectationsWithClock(clock.RealClock{})
+}
+
+// NewControllerExpectationsWithClock returns a store for ControllerExpectations that expire as timed by clock.
+func NewControllerExpectationsWithClock(clock clock.PassiveClock) *ControllerExpectations {
+	return &ControllerExpectations{Store: cache.NewStore(ExpKeyFunc), clock: clock}
 }
 
 // UIDSetKeyFunc to parse out the key from a UIDSet.
@@ -386,6 +392,32 @@ func (u *UIDTrackingControllerExpectations) ExpectDeletions(logger klog.Logger,
 	return u.ControllerExpectationsInterface.ExpectDeletions(logger, rcKey, expectedUIDs.Len())
 }
 
+// RaiseDeletions adds the given deleteKeys to the deletions the given controller expects. Unlike ExpectDeletions, it
+// keeps the deletions the controller is already waiting on, so it can be used while deletions are issued one at a
+// time. Keys that are already expected are not counted twice.
+func (u *UIDTrackingControllerExpectations) RaiseDeletions(logger klog.Logger, rcKey string, deletedKeys []string) error {
+	u.uidStoreLock.Lock()
+	defer u.uidStoreLock.Unlock()
+
+	uids := u.GetUIDs(rcKey)
+	if uids == nil {
+		uids = sets.NewString()
+		if err := u.uidStore.Add(&UIDSet{uids, rcKey}); err != nil {
+			return err
+		}
+	}
+	raised := 0
+	for _, k := range deletedKeys {
+		if !uids.Has(k) {
+			uids.Insert(k)
+			raised++
+		}
+	}
+	logger.V(4).Info("Controller waiting on deletions", "controller", rcKey, "keys", deletedKeys)
+	u.ControllerExpectationsInterface.RaiseExpectations(logger, rcKey, 0, raised)
+	return nil
+}
+
 // DeletionObserved records the given deleteKey as a deletion, for the given rc.
 func (u *UIDTrackingControllerExpectations) DeletionObserved(logger klog.Logger, rcKey, deleteKey string) {
 	u.uidStoreLock.Lock()
@@ -179,10 +179,10 @@ type Interface interface {
 	// collision occurs, collisionCount (incremented each time collision occurs except for the first time) is
 	// added to the hash of the revision and it is renamed using ControllerRevisionName. Implementations may
 	// cease to attempt to retry creation after some number of attempts and return an error. If the returned
-	// error is not nil, creation failed. If the returned error is nil, the returned ControllerRevision has been
-	// created.
+	// error is not nil, creation failed. If the returned error is nil, the returned ControllerRevision is valid.
+	// created is false if an equal ControllerRevision already existed under the name, and was returned instead.
 	// Callers must make sure that collisionCount is not nil. An error is returned if it is.
-	CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, error)
+	CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (rev *apps.ControllerRevision, created bool, err error)
 	// DeleteControllerRevision attempts to delete revision. If the returned error is not nil, deletion has failed.
 	DeleteControllerRevision(revision *apps.ControllerRevision) error
 	// UpdateControllerRevision updates revision such that its Revision is equal to newRevision. Implementations
@@ -234,9 +234,9 @@ func (rh *realHistory) ListControllerRevisions(parent metav1.Object, selector la
 	return owned, err
 }
 
-func (rh *realHistory) CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, error) {
+func (rh *realHistory) CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, bool, error) {
 	if collisionCount == nil {
-		return nil, fmt.Errorf("collisionCount should not be nil")
+		return nil, false, fmt.Errorf("collisionCount should not be nil")
 	}
 
 	// Clone the input
@@ -252,15 +252,15 @@ func (rh *realHistory) CreateControllerRevision(parent metav1.Object, revision *
 		if errors.IsAlreadyExists(err) {
 			exists, err := rh.client.AppsV1().ControllerRevisions(ns).Get(context.TODO(), clone.Name, metav1.GetOptions{})
 			if err != nil {
-				return nil, err
+				return nil, false, err
 			}
 			if bytes.Equal(exists.Data.Raw, clone.Data.Raw) {
-				return exists, nil
+				return exists, false, nil
 			}
 			*collisionCount++
 			continue
 		}
-		return created, err
+		return created, err == nil, err
 	}
 }
 
@@ -392,9 +392,9 @@ func (fh *fakeHistory) addRevision(revision *apps.ControllerRevision) (*apps.Con
 	return revision, fh.indexer.Update(revision)
 }
 
-func (fh *fakeHistory) CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, error) {
+func (fh *fakeHistory) CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, bool, error) {
 	if collisionCount == nil {
-		return nil, fmt.Errorf("collisionCount should not be nil")
+		return nil, false, fmt.Errorf("collisionCount should not be nil")
 	}
 
 	// Clone the input
@@ -411,7 +411,7 @@ func (fh *fakeHistory) CreateControllerRevision(parent metav1.Object, revision *
 			*collisionCount++
 			continue
 		}
-		return created, err
+		return created, err == nil, err
 	}
 }
 
@@ -0,0 +1,131 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+)
+
+// QueueOptions configures the work queue of a controller.
+type QueueOptions struct {
+	// BaseDelay is the delay before an object that failed to sync is retried. It doubles with every consecutive
+	// failure of the object, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// QPS and Burst limit how fast all failed objects are retried together.
+	QPS   float64
+	Burst int
+	// RateLimiter overrides the rate limiter built from BaseDelay, MaxDelay, QPS and Burst.
+	RateLimiter workqueue.TypedRateLimiter[string]
+	// FairQueuing takes keys from the namespaces with queued keys in turn, instead of in the order they were added,
+	// so that the objects of one namespace cannot delay the objects of all others.
+	FairQueuing bool
+	// Clock times the delays of the queue, it defaults to the real clock. The token bucket of QPS and Burst always
+	// uses the real clock, set RateLimiter to retry failed objects as timed by Clock only.
+	Clock clock.WithTicker
+}
+
+// DefaultQueueOptions returns the options of the queue of workqueue.DefaultTypedControllerRateLimiter.
+func DefaultQueueOptions() QueueOptions {
+	return QueueOptions{
+		BaseDelay: 5 * time.Millisecond,
+		MaxDelay:  1000 * time.Second,
+		QPS:       10,
+		Burst:     100,
+	}
+}
+
+// NewRateLimitingQueue returns a work queue of namespace/name keys configured by opts.
+func NewRateLimitingQueue(name string, opts QueueOptions) workqueue.TypedRateLimitingInterface[string] {
+	rateLimiter := opts.RateLimiter
+	if rateLimiter == nil {
+		rateLimiter = workqueue.NewTypedMaxOfRateLimiter(
+			workqueue.NewTypedItemExponentialFailureRateLimiter[string](opts.BaseDelay, opts.MaxDelay),
+			&workqueue.TypedBucketRateLimiter[string]{Limiter: rate.NewLimiter(rate.Limit(opts.QPS), opts.Burst)},
+		)
+	}
+	config := workqueue.TypedRateLimitingQueueConfig[string]{Name: name, Clock: opts.Clock}
+	if opts.FairQueuing {
+		config.DelayingQueue = workqueue.NewTypedDelayingQueueWithConfig(workqueue.TypedDelayingQueueConfig[string]{
+			Name:  name,
+			Clock: opts.Clock,
+			Queue: workqueue.NewTypedWithConfig(workqueue.TypedQueueConfig[string]{
+				Name:  name,
+				Queue: newFairQueue(),
+			}),
+		})
+	}
+	return workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter, config)
+}
+
+// fairQueue orders the keys of a work queue by taking one key of each namespace in turn. The work queue
+// deduplicates keys and serializes the calls, so fairQueue only orders them.
+type fairQueue struct {
+	// keys are the queued keys of each namespace, in the order they were added.
+	keys map[string][]string
+	// namespaces are the namespaces with queued keys, in the order they are taken from.
+	namespaces []string
+	len        int
+}
+
+var _ workqueue.Queue[string] = &fairQueue{}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{keys: map[string][]string{}}
+}
+
+// Touch does not reorder a key that is added again.
+func (q *fairQueue) Touch(key string) {}
+
+// Push queues key after the other keys of its namespace.
+func (q *fairQueue) Push(key string) {
+	// keys of cluster scoped objects or that are malformed share the "" namespace
+	namespace, _, _ := cache.SplitMetaNamespaceKey(key)
+	if len(q.keys[namespace]) == 0 {
+		q.namespaces = append(q.namespaces, namespace)
+	}
+	q.keys[namespace] = append(q.keys[namespace], key)
+	q.len++
+}
+
+func (q *fairQueue) Len() int {
+	return q.len
+}
+
+// Pop returns the first key of the next namespace. The namespace is taken from again after all other namespaces
+// with queued keys.
+func (q *fairQueue) Pop() string {
+	namespace := q.namespaces[0]
+	q.namespaces[0] = ""
+	q.namespaces = q.namespaces[1:]
+	keys := q.keys[namespace]
+	key := keys[0]
+	keys[0] = ""
+	if keys = keys[1:]; len(keys) > 0 {
+		q.keys[namespace] = keys
+		q.namespaces = append(q.namespaces, namespace)
+	} else {
+		delete(q.keys, namespace)
+	}
+	q.len--
+	return key
+}
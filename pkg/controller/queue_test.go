@@ -0,0 +1,66 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRateLimitingQueue(t *testing.T) {
+	keys := []string{"a/1", "a/2", "a/3", "b/1", "a/4", "c/1", "b/2"}
+
+	tests := []struct {
+		name        string
+		fairQueuing bool
+		expected    []string
+	}{
+		{
+			name:     "fifo",
+			expected: keys,
+		},
+		{
+			name:        "fair queuing",
+			fairQueuing: true,
+			expected:    []string{"a/1", "b/1", "c/1", "a/2", "b/2", "a/3", "a/4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultQueueOptions()
+			opts.FairQueuing = tt.fairQueuing
+			queue := NewRateLimitingQueue("", opts)
+			defer queue.ShutDown()
+			for _, key := range keys {
+				queue.Add(key)
+			}
+			// keys that are already queued are not added twice
+			queue.Add("a/1")
+
+			var got []string
+			for queue.Len() > 0 {
+				key, _ := queue.Get()
+				got = append(got, key)
+				queue.Done(key)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("expected keys %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
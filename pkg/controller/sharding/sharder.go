@@ -0,0 +1,442 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sharding splits the XStatefulSets of a cluster between the replicas of the controller. Every key is hashed
+// to one of a fixed number of shards, and every shard is owned by the replica that holds its Lease. Shards are
+// assigned to the live replicas by rendezvous hashing, so a replica joining or leaving only moves the shards it gains
+// or loses.
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// nameLabel is the label of the Leases of a Sharder that holds its Name.
+	nameLabel = "xstatefulset.x-k8s.io/sharding"
+	// roleLabel is the label that tells the member Leases of replicas from the Leases of shards.
+	roleLabel = "xstatefulset.x-k8s.io/sharding-role"
+
+	roleMember = "member"
+	roleShard  = "shard"
+)
+
+// Options configures a Sharder.
+type Options struct {
+	// Name prefixes the names of the Leases of the Sharder. Replicas with the same Name share the shards.
+	Name string
+	// Namespace is the namespace of the Leases.
+	Namespace string
+	// Identity is the unique name of this replica.
+	Identity string
+	// Shards is the number of shards the keys are hashed to. It must be the same for all replicas.
+	Shards int
+	// LeaseDuration is how long a replica owns a shard, or is a member, after it last renewed the Lease.
+	LeaseDuration time.Duration
+	// RenewDeadline is how long a replica keeps syncing the keys of a shard whose Lease it failed to renew.
+	RenewDeadline time.Duration
+	// RetryPeriod is how often the Leases are renewed and the shards are rebalanced.
+	RetryPeriod time.Duration
+}
+
+// shard is the state of a shard in this replica.
+type shard struct {
+	// mu is held for reading while a key of the shard is synced, and for writing once the shard is disowned, so
+	// that its Lease is only handed over once its syncs have finished.
+	mu    sync.RWMutex
+	owned atomic.Bool
+	// releasing is set from when the shard is disowned until its syncs in flight have finished. The shard is not
+	// acquired again in the meantime.
+	releasing atomic.Bool
+	// renewTime is when the Lease of the shard was last acquired or renewed by this replica.
+	renewTime time.Time
+}
+
+// observation is when this replica observed a version of a Lease.
+type observation struct {
+	resourceVersion string
+	time            time.Time
+}
+
+// Sharder decides which keys this replica syncs. A nil Sharder owns all keys.
+type Sharder struct {
+	client   coordinationv1client.LeasesGetter
+	opts     Options
+	shards   []*shard
+	handlers []func(shard int, owned bool)
+	now      func() time.Time
+	// observed holds when each Lease was last seen to change. The Leases of other replicas expire a lease duration
+	// after they were last observed to change by the clock of this replica, as their renew times are written by
+	// clocks that may be skewed.
+	observed map[string]observation
+	// releases tracks the shards whose syncs in flight are waited for before their Leases are handed over.
+	releases sync.WaitGroup
+}
+
+// NewSharder returns a Sharder that coordinates with the other replicas through Leases.
+func NewSharder(client coordinationv1client.LeasesGetter, opts Options) (*Sharder, error) {
+	if opts.Shards <= 0 {
+		return nil, fmt.Errorf("number of shards must be positive, got %d", opts.Shards)
+	}
+	if opts.Identity == "" {
+		return nil, fmt.Errorf("identity must not be empty")
+	}
+	if opts.RenewDeadline >= opts.LeaseDuration {
+		return nil, fmt.Errorf("renew deadline %v must be less than the lease duration %v", opts.RenewDeadline, opts.LeaseDuration)
+	}
+	s := &Sharder{client: client, opts: opts, now: time.Now, observed: map[string]observation{}}
+	for range opts.Shards {
+		s.shards = append(s.shards, &shard{})
+	}
+	return s, nil
+}
+
+// ShardFor returns the shard key is hashed to.
+func (s *Sharder) ShardFor(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(s.shards)))
+}
+
+// Begin reports whether this replica owns key. If it does, the shard of key is not released until done is called.
+func (s *Sharder) Begin(key string) (done func(), owned bool) {
+	if s == nil {
+		return func() {}, true
+	}
+	sh := s.shards[s.ShardFor(key)]
+	// mu is only locked for writing once the shard is disowned, a sync does not wait for the syncs in flight
+	if !sh.mu.TryRLock() {
+		return nil, false
+	}
+	if !sh.owned.Load() {
+		sh.mu.RUnlock()
+		return nil, false
+	}
+	return sh.mu.RUnlock, true
+}
+
+// OnChange calls handler whenever this replica starts or stops owning a shard. It must be called before Run.
+func (s *Sharder) OnChange(handler func(shard int, owned bool)) {
+	if s == nil {
+		return
+	}
+	s.handlers = append(s.handlers, handler)
+}
+
+// Run renews the Leases of this replica and rebalances the shards until ctx is done. It then releases the shards
+// and the membership of this replica, so that the other replicas take them over without waiting for the Leases to
+// expire.
+func (s *Sharder) Run(ctx context.Context) {
+	logger := klog.FromContext(ctx).WithValues("identity", s.opts.Identity)
+	logger.Info("Starting sharder", "shards", len(s.shards))
+	wait.UntilWithContext(ctx, s.reconcile, s.opts.RetryPeriod)
+
+	logger.Info("Releasing shards")
+	releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.opts.RenewDeadline)
+	defer cancel()
+	leases, err := s.listLeases(releaseCtx)
+	if err != nil {
+		logger.Error(err, "Unable to list leases")
+	}
+	for i, sh := range s.shards {
+		if sh.owned.Load() {
+			s.release(releaseCtx, i, leases[s.shardLeaseName(i)])
+		}
+	}
+	s.releases.Wait()
+	err = s.client.Leases(s.opts.Namespace).Delete(releaseCtx, s.memberLeaseName(), metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Unable to delete member lease")
+	}
+}
+
+// reconcile renews the membership of this replica, acquires or renews the shards rendezvous hashing assigns to it
+// and releases the others.
+func (s *Sharder) reconcile(ctx context.Context) {
+	logger := klog.FromContext(ctx)
+	if err := s.renewMembership(ctx); err != nil {
+		logger.Error(err, "Unable to renew member lease")
+	}
+	leases, err := s.listLeases(ctx)
+	if err != nil {
+		logger.Error(err, "Unable to list leases")
+		s.expireShards(ctx)
+		return
+	}
+
+	now := s.now()
+	s.observe(leases, now)
+	members := []string{s.opts.Identity}
+	for _, lease := range leases {
+		if lease.Labels[roleLabel] == roleMember && !s.expired(lease, now) {
+			if identity := ptr.Deref(lease.Spec.HolderIdentity, ""); identity != s.opts.Identity {
+				members = append(members, identity)
+			}
+		}
+	}
+
+	for i := range s.shards {
+		lease := leases[s.shardLeaseName(i)]
+		if owner(i, members) != s.opts.Identity {
+			if s.shards[i].owned.Load() {
+				s.release(ctx, i, lease)
+			}
+			continue
+		}
+		if s.shards[i].releasing.Load() {
+			// the Lease is handed over once the syncs of the shard have finished
+			continue
+		}
+		if lease == nil || s.holder(lease) == s.opts.Identity || s.holder(lease) == "" || s.expired(lease, now) {
+			if err := s.acquire(ctx, i, lease); err != nil {
+				logger.V(2).Info("Unable to acquire or renew shard", "shard", i, "err", err)
+			}
+		}
+	}
+	s.expireShards(ctx)
+}
+
+// owner returns the member that rendezvous hashing assigns the shard to.
+func owner(shard int, members []string) string {
+	var best string
+	var bestScore uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(shard)))
+		if score := mix(h.Sum64()); best == "" || score > bestScore || (score == bestScore && member < best) {
+			best, bestScore = member, score
+		}
+	}
+	return best
+}
+
+// mix spreads the bits of an FNV hash, whose high bits barely depend on the last bytes of short inputs. It is the
+// finalizer of MurmurHash3.
+func mix(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func (s *Sharder) renewMembership(ctx context.Context) error {
+	leases := s.client.Leases(s.opts.Namespace)
+	now := metav1.NewMicroTime(s.now())
+	lease, err := leases.Get(ctx, s.memberLeaseName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		lease = s.newLease(s.memberLeaseName(), roleMember)
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = ptr.To(s.opts.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(s.opts.LeaseDuration / time.Second))
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// acquire acquires or renews the Lease of a shard. lease is nil if the shard has no Lease yet.
+func (s *Sharder) acquire(ctx context.Context, i int, lease *coordinationv1.Lease) error {
+	leases := s.client.Leases(s.opts.Namespace)
+	now := metav1.NewMicroTime(s.now())
+	var err error
+	if lease == nil {
+		lease = s.newLease(s.shardLeaseName(i), roleShard)
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+	} else {
+		lease = lease.DeepCopy()
+		if s.holder(lease) != s.opts.Identity {
+			lease.Spec.HolderIdentity = ptr.To(s.opts.Identity)
+			lease.Spec.AcquireTime = &now
+			lease.Spec.LeaseTransitions = ptr.To(ptr.Deref(lease.Spec.LeaseTransitions, 0) + 1)
+		}
+		lease.Spec.LeaseDurationSeconds = ptr.To(int32(s.opts.LeaseDuration / time.Second))
+		lease.Spec.RenewTime = &now
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	}
+	if err != nil {
+		return err
+	}
+	sh := s.shards[i]
+	sh.renewTime = now.Time
+	if sh.owned.Swap(true) {
+		return nil
+	}
+	klog.FromContext(ctx).V(2).Info("Acquired shard", "shard", i)
+	s.notify(i, true)
+	return nil
+}
+
+// release stops syncing the keys of a shard and, once the syncs in flight have finished, hands the Lease of the
+// shard over to the other replicas. It does not wait for the syncs, so that the Leases of the other shards are still
+// renewed meanwhile.
+func (s *Sharder) release(ctx context.Context, i int, lease *coordinationv1.Lease) {
+	if lease == nil || s.holder(lease) != s.opts.Identity {
+		s.disown(ctx, i, nil)
+		return
+	}
+	lease = lease.DeepCopy()
+	lease.Spec.HolderIdentity = nil
+	lease.Spec.AcquireTime = nil
+	lease.Spec.RenewTime = nil
+	s.disown(ctx, i, func() {
+		// the syncs may outlast ctx, the Lease is still handed over within the renew deadline
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.opts.RenewDeadline)
+		defer cancel()
+		if _, err := s.client.Leases(s.opts.Namespace).Update(ctx, lease, metav1.UpdateOptions{}); err != nil {
+			// the other replicas take the shard over once the lease expires
+			klog.FromContext(ctx).V(2).Info("Unable to release shard", "shard", i, "err", err)
+		}
+	})
+}
+
+// expireShards stops syncing the keys of the owned shards whose Lease could not be renewed within the renew
+// deadline, so that they are not synced by two replicas once the Lease expires.
+func (s *Sharder) expireShards(ctx context.Context) {
+	now := s.now()
+	for i, sh := range s.shards {
+		if sh.owned.Load() && now.Sub(sh.renewTime) > s.opts.RenewDeadline {
+			klog.FromContext(ctx).Info("Lost shard, its lease has not been renewed in time", "shard", i)
+			s.disown(ctx, i, nil)
+		}
+	}
+}
+
+// disown stops syncing the keys of a shard. No sync of the shard begins once it returns, the syncs in flight are
+// waited for in the background before handOver, if it is not nil, is called.
+func (s *Sharder) disown(ctx context.Context, i int, handOver func()) {
+	sh := s.shards[i]
+	if !sh.owned.Swap(false) {
+		return
+	}
+	sh.releasing.Store(true)
+	klog.FromContext(ctx).V(2).Info("Released shard", "shard", i)
+	s.notify(i, false)
+	s.releases.Add(1)
+	go func() {
+		defer s.releases.Done()
+		// the syncs that began before the shard was disowned hold mu for reading
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		if handOver != nil {
+			handOver()
+		}
+		sh.releasing.Store(false)
+	}()
+}
+
+func (s *Sharder) notify(shard int, owned bool) {
+	for _, handler := range s.handlers {
+		handler(shard, owned)
+	}
+}
+
+// listLeases returns the Leases of the Sharder by name.
+func (s *Sharder) listLeases(ctx context.Context) (map[string]*coordinationv1.Lease, error) {
+	list, err := s.client.Leases(s.opts.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{nameLabel: s.opts.Name}).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	leases := make(map[string]*coordinationv1.Lease, len(list.Items))
+	for i := range list.Items {
+		leases[list.Items[i].Name] = &list.Items[i]
+	}
+	return leases, nil
+}
+
+func (s *Sharder) newLease(name, role string) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.opts.Namespace,
+			Labels:    map[string]string{nameLabel: s.opts.Name, roleLabel: role},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(s.opts.Identity),
+			LeaseDurationSeconds: ptr.To(int32(s.opts.LeaseDuration / time.Second)),
+		},
+	}
+}
+
+func (s *Sharder) holder(lease *coordinationv1.Lease) string {
+	return ptr.Deref(lease.Spec.HolderIdentity, "")
+}
+
+// observe records when each of leases was first seen in its current version, and forgets the Leases that no longer
+// exist.
+func (s *Sharder) observe(leases map[string]*coordinationv1.Lease, now time.Time) {
+	for name, lease := range leases {
+		if s.observed[name].resourceVersion != lease.ResourceVersion {
+			s.observed[name] = observation{resourceVersion: lease.ResourceVersion, time: now}
+		}
+	}
+	for name := range s.observed {
+		if _, ok := leases[name]; !ok {
+			delete(s.observed, name)
+		}
+	}
+}
+
+// expired returns true if the holder of lease released it, or did not renew it within its duration. Like the leader
+// election of client-go, a renewal is the change of the Lease observed by this replica, not the renew time written by
+// the holder, whose clock may be skewed.
+func (s *Sharder) expired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil {
+		return true
+	}
+	duration := time.Duration(ptr.Deref(lease.Spec.LeaseDurationSeconds, 0)) * time.Second
+	observed, ok := s.observed[lease.Name]
+	if !ok || observed.resourceVersion != lease.ResourceVersion {
+		return false
+	}
+	return observed.time.Add(duration).Before(now)
+}
+
+func (s *Sharder) memberLeaseName() string {
+	return fmt.Sprintf("%s-member-%s", s.opts.Name, s.opts.Identity)
+}
+
+func (s *Sharder) shardLeaseName(i int) string {
+	return fmt.Sprintf("%s-shard-%d", s.opts.Name, i)
+}
@@ -0,0 +1,257 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestSharder(t *testing.T, client *fake.Clientset, identity string) *Sharder {
+	s, err := NewSharder(client.CoordinationV1(), Options{
+		Name:          "xstatefulset",
+		Namespace:     "kube-system",
+		Identity:      identity,
+		Shards:        16,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("NewSharder() error = %v", err)
+	}
+	return s
+}
+
+// versionLeases makes client bump the resourceVersion of the Leases it writes, like the API server.
+func versionLeases(client *fake.Clientset) {
+	version := 0
+	client.PrependReactor("*", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action, ok := action.(k8stesting.CreateAction); ok {
+			version++
+			accessor, err := meta.Accessor(action.GetObject())
+			if err != nil {
+				return true, nil, err
+			}
+			accessor.SetResourceVersion(fmt.Sprint(version))
+		}
+		return false, nil, nil
+	})
+}
+
+// owned returns the shards each sharder owns, and fails if a shard is not owned by exactly one of them.
+func owned(t *testing.T, sharders ...*Sharder) map[string]int {
+	t.Helper()
+	counts := map[string]int{}
+	for i := range sharders[0].shards {
+		var owners []string
+		for _, s := range sharders {
+			if s.shards[i].owned.Load() {
+				owners = append(owners, s.opts.Identity)
+				counts[s.opts.Identity]++
+			}
+		}
+		if len(owners) != 1 {
+			t.Errorf("expected shard %d to be owned by one replica, got %v", i, owners)
+		}
+	}
+	return counts
+}
+
+func TestSharderRebalances(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	a := newTestSharder(t, client, "a")
+	changes := map[int]bool{}
+	a.OnChange(func(shard int, owned bool) { changes[shard] = owned })
+
+	a.reconcile(ctx)
+	if counts := owned(t, a); counts["a"] != 16 {
+		t.Fatalf("expected a single replica to own all shards, got %v", counts)
+	}
+	if len(changes) != 16 {
+		t.Errorf("expected a change for every shard, got %v", changes)
+	}
+
+	// b joins: a releases the shards rendezvous hashing assigns to b, then b acquires them
+	b := newTestSharder(t, client, "b")
+	for range 2 {
+		b.reconcile(ctx)
+		a.reconcile(ctx)
+		a.releases.Wait()
+	}
+	b.reconcile(ctx)
+	counts := owned(t, a, b)
+	if counts["a"] == 0 || counts["b"] == 0 {
+		t.Fatalf("expected both replicas to own shards, got %v", counts)
+	}
+	for i, sh := range a.shards {
+		if changes[i] != sh.owned.Load() {
+			t.Errorf("expected the last change of shard %d to be %v", i, sh.owned.Load())
+		}
+	}
+
+	key := "default/web"
+	done, ownedByA := a.Begin(key)
+	_, ownedByB := b.Begin(key)
+	if ownedByA == ownedByB {
+		t.Fatalf("expected %s to be owned by exactly one replica", key)
+	}
+	if ownedByA {
+		done()
+	}
+
+	// b leaves: its shards are released right away and a takes them over
+	bCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	b.Run(bCtx)
+	a.reconcile(ctx)
+	if counts := owned(t, a, b); counts["a"] != 16 {
+		t.Fatalf("expected a to own all shards after b left, got %v", counts)
+	}
+}
+
+func TestSharderExpiresShards(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	s := newTestSharder(t, client, "a")
+	s.reconcile(ctx)
+
+	// the leases cannot be renewed any more, the shards are given up after the renew deadline
+	now := time.Now()
+	s.now = func() time.Time { return now.Add(11 * time.Second) }
+	client.PrependReactor("*", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	s.reconcile(ctx)
+	for i, sh := range s.shards {
+		if sh.owned.Load() {
+			t.Errorf("expected shard %d to be given up", i)
+		}
+	}
+}
+
+func TestSharderToleratesClockSkew(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	versionLeases(client)
+	now := time.Now()
+	// the clock of a is an hour behind, the renew times it writes look expired to b
+	a := newTestSharder(t, client, "a")
+	a.now = func() time.Time { return now.Add(-time.Hour) }
+	a.reconcile(ctx)
+
+	b := newTestSharder(t, client, "b")
+	b.now = func() time.Time { return now }
+	for range 2 {
+		b.reconcile(ctx)
+		a.reconcile(ctx)
+		a.releases.Wait()
+	}
+	b.reconcile(ctx)
+	counts := owned(t, a, b)
+	if counts["a"] == 0 || counts["b"] == 0 {
+		t.Fatalf("expected both replicas to own shards, got %v", counts)
+	}
+
+	// a stops renewing its leases, b takes its shards over once it has not seen them change for a lease duration
+	b.now = func() time.Time { return now.Add(10 * time.Second) }
+	b.reconcile(ctx)
+	if counts := owned(t, a, b); counts["b"] == 16 {
+		t.Fatalf("expected b not to take the shards of a over before their leases expired")
+	}
+	b.now = func() time.Time { return now.Add(20 * time.Second) }
+	b.reconcile(ctx)
+	if counts := owned(t, b); counts["b"] != 16 {
+		t.Fatalf("expected b to own all shards once the leases of a expired, got %v", counts)
+	}
+}
+
+func TestSharderReleasesWithoutBlocking(t *testing.T) {
+	ctx := context.Background()
+	client := fake.NewClientset()
+	a := newTestSharder(t, client, "a")
+	a.reconcile(ctx)
+
+	// a sync of a shard that moves to b is in flight
+	key := ""
+	for i := range 64 {
+		if k := fmt.Sprintf("default/web-%d", i); owner(a.ShardFor(k), []string{"a", "b"}) == "b" {
+			key = k
+			break
+		}
+	}
+	shard := a.ShardFor(key)
+	done, ok := a.Begin(key)
+	if !ok {
+		t.Fatalf("expected a to own %s", key)
+	}
+
+	b := newTestSharder(t, client, "b")
+	b.reconcile(ctx)
+	reconciled := make(chan struct{})
+	go func() {
+		a.reconcile(ctx)
+		close(reconciled)
+	}()
+	select {
+	case <-reconciled:
+	case <-time.After(wait.ForeverTestTimeout):
+		t.Fatalf("expected the reconcile of a not to wait for the sync in flight")
+	}
+	if _, ok := a.Begin(key); ok {
+		t.Errorf("expected no new sync of %s to begin once its shard is released", key)
+	}
+	b.reconcile(ctx)
+	if b.shards[shard].owned.Load() {
+		t.Fatalf("expected b not to acquire shard %d before the sync in flight finished", shard)
+	}
+
+	done()
+	a.releases.Wait()
+	b.reconcile(ctx)
+	if !b.shards[shard].owned.Load() {
+		t.Errorf("expected b to acquire shard %d once the sync in flight finished", shard)
+	}
+	owned(t, a, b)
+}
+
+func TestOwnerIsStable(t *testing.T) {
+	// adding a member only moves shards to the new member
+	moved := 0
+	for i := range 64 {
+		before := owner(i, []string{"a", "b", "c"})
+		after := owner(i, []string{"a", "b", "c", "d"})
+		if before != after {
+			if after != "d" {
+				t.Errorf("shard %d moved from %s to %s", i, before, after)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved == 64 {
+		t.Errorf("expected some but not all shards to move to the new member, got %d", moved)
+	}
+}
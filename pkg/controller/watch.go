@@ -0,0 +1,113 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// WatchOptions restricts the objects the controllers watch, so that several controllers can share a cluster without
+// syncing the same XStatefulSets.
+type WatchOptions struct {
+	// Namespaces are the namespaces the controllers watch. All namespaces are watched if it is empty. A single
+	// namespace is watched with namespaced informers, several namespaces are filtered from cluster wide informers.
+	Namespaces []string
+	// NamespaceSelector selects the namespaces the controllers watch by their labels. It requires the controllers
+	// to watch namespaces.
+	NamespaceSelector labels.Selector
+	// ObjectSelector selects the XStatefulSets the controllers watch by their labels.
+	ObjectSelector labels.Selector
+}
+
+// NamespaceFilter decides which namespaces the controllers watch. A nil NamespaceFilter watches all namespaces.
+type NamespaceFilter struct {
+	namespaces sets.Set[string]
+	selector   labels.Selector
+	informer   cache.SharedIndexInformer
+	lister     corelisters.NamespaceLister
+}
+
+// newNamespaceFilter returns the NamespaceFilter of opts, or nil if opts does not need one.
+func newNamespaceFilter(opts WatchOptions, factory informers.SharedInformerFactory) *NamespaceFilter {
+	f := &NamespaceFilter{}
+	if len(opts.Namespaces) > 1 {
+		f.namespaces = sets.New(opts.Namespaces...)
+	}
+	if opts.NamespaceSelector != nil && !opts.NamespaceSelector.Empty() {
+		f.selector = opts.NamespaceSelector
+		namespaceInformer := factory.Core().V1().Namespaces()
+		f.informer = namespaceInformer.Informer()
+		f.lister = namespaceInformer.Lister()
+	}
+	if f.namespaces == nil && f.selector == nil {
+		return nil
+	}
+	return f
+}
+
+// Watches returns true if the controllers watch the objects in namespace.
+func (f *NamespaceFilter) Watches(namespace string) bool {
+	if f == nil {
+		return true
+	}
+	if f.selector == nil {
+		return f.namespaces.Has(namespace)
+	}
+	ns, err := f.lister.Get(namespace)
+	if err != nil {
+		return false
+	}
+	return f.matches(ns)
+}
+
+func (f *NamespaceFilter) matches(ns *v1.Namespace) bool {
+	if f.namespaces != nil && !f.namespaces.Has(ns.Name) {
+		return false
+	}
+	return f.selector == nil || f.selector.Matches(labels.Set(ns.Labels))
+}
+
+// HasSynced returns true once the namespaces the selector is matched against have synced.
+func (f *NamespaceFilter) HasSynced() bool {
+	return f == nil || f.informer == nil || f.informer.HasSynced()
+}
+
+// OnChange calls handler with the name of a namespace whenever the controllers start watching it, because it has
+// been created or its labels now match the selector, or stop watching it because its labels no longer match.
+func (f *NamespaceFilter) OnChange(handler func(namespace string, watched bool)) {
+	if f == nil || f.informer == nil {
+		return
+	}
+	f.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if ns := obj.(*v1.Namespace); f.matches(ns) {
+				handler(ns.Name, true)
+			}
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			oldNS, curNS := old.(*v1.Namespace), cur.(*v1.Namespace)
+			if watched := f.matches(curNS); watched != f.matches(oldNS) {
+				handler(curNS.Name, watched)
+			}
+		},
+	})
+}
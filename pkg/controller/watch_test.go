@@ -0,0 +1,86 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestNamespaceFilter(t *testing.T) {
+	namespaces := []*v1.Namespace{
+		{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"tenant": "x"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "b", Labels: map[string]string{"tenant": "y"}}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "c", Labels: map[string]string{"tenant": "x"}}},
+	}
+	tests := []struct {
+		name    string
+		opts    WatchOptions
+		watched []string
+	}{
+		{
+			name:    "all namespaces",
+			watched: []string{"a", "b", "c", "unknown"},
+		},
+		{
+			name:    "single namespace is watched by the informers",
+			opts:    WatchOptions{Namespaces: []string{"a"}},
+			watched: []string{"a", "b", "c", "unknown"},
+		},
+		{
+			name:    "namespaces",
+			opts:    WatchOptions{Namespaces: []string{"a", "b"}},
+			watched: []string{"a", "b"},
+		},
+		{
+			name:    "namespace selector",
+			opts:    WatchOptions{NamespaceSelector: labels.SelectorFromSet(labels.Set{"tenant": "x"})},
+			watched: []string{"a", "c"},
+		},
+		{
+			name:    "namespaces and namespace selector",
+			opts:    WatchOptions{Namespaces: []string{"b", "c"}, NamespaceSelector: labels.SelectorFromSet(labels.Set{"tenant": "x"})},
+			watched: []string{"c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newNamespaceFilter(tt.opts, informers.NewSharedInformerFactory(fake.NewClientset(), 0))
+			if f != nil && f.informer != nil {
+				for _, ns := range namespaces {
+					if err := f.informer.GetIndexer().Add(ns); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			watched := map[string]bool{}
+			for _, ns := range tt.watched {
+				watched[ns] = true
+			}
+			for _, ns := range []string{"a", "b", "c", "unknown"} {
+				if got := f.Watches(ns); got != watched[ns] {
+					t.Errorf("Watches(%q) = %v, want %v", ns, got, watched[ns])
+				}
+			}
+		})
+	}
+}
@@ -37,13 +37,22 @@ import (
 
 	"k8s.io/component-base/metrics"
 	"k8s.io/component-base/metrics/legacyregistry"
+	// controller-runtime installs the client-go workqueue metrics provider, which exports the depth, latency and
+	// retries of the StatefulSet queue on the metrics endpoint.
+	_ "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
 const StatefulSetControllerSubsystem = "statefulset_controller"
 
+// Values of the result label.
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
 var (
-	// MaxUnavailable tracks the current .spec.updateStrategy.rollingUpdate.maxUnavailable value with the
-	// MaxUnavailableStatefulSet feature enabled. This gauge reflects the configured maximum number of pods
+	// MaxUnavailable tracks the current .spec.updateStrategy.rollingUpdate.maxUnavailable value, which is always 1
+	// unless the MaxUnavailableStatefulSet feature is enabled. This gauge reflects the configured maximum number of pods
 	// that can be unavailable during rolling updates, providing visibility into the availability constraints.
 	// The metric is set to 1 by default.
 	//
@@ -78,13 +87,201 @@ var (
 			StabilityLevel: metrics.ALPHA,
 		}, []string{"statefulset_namespace", "statefulset_name", "pod_management_policy"},
 	)
+
+	// Replicas tracks the desired number of replicas of a StatefulSet, after scaling schedules are applied.
+	Replicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_replicas",
+			Help:           "Desired number of replicas of the StatefulSet",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusReplicas tracks the number of pods created by the controller for a StatefulSet.
+	StatusReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_replicas",
+			Help:           "Number of pods created for the StatefulSet",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusCurrentReplicas tracks the number of pods of a StatefulSet at the current revision.
+	StatusCurrentReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_current_replicas",
+			Help:           "Number of pods of the StatefulSet at the current revision",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusReadyReplicas tracks the number of pods of a StatefulSet with a Ready condition.
+	StatusReadyReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_ready_replicas",
+			Help:           "Number of ready pods of the StatefulSet",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusAvailableReplicas tracks the number of pods of a StatefulSet that have been ready for at least
+	// .spec.minReadySeconds.
+	StatusAvailableReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_available_replicas",
+			Help:           "Number of available pods of the StatefulSet",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusUpdatedReplicas tracks the number of pods of a StatefulSet at the update revision.
+	StatusUpdatedReplicas = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_updated_replicas",
+			Help:           "Number of pods of the StatefulSet at the update revision",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusCurrentRevision is an info metric that is 1 for the current revision of a StatefulSet. A rollout is
+	// complete when it matches StatusUpdateRevision.
+	//
+	// Sample monitoring queries:
+	// - StatefulSets in the middle of a rollout:
+	//   statefulset_status_current_revision unless on (statefulset_namespace, statefulset_name, revision) statefulset_status_update_revision
+	StatusCurrentRevision = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_current_revision",
+			Help:           "Current revision of the StatefulSet, the value is always 1",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name", "revision"},
+	)
+
+	// StatusUpdateRevision is an info metric that is 1 for the update revision of a StatefulSet.
+	StatusUpdateRevision = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_update_revision",
+			Help:           "Update revision of the StatefulSet, the value is always 1",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name", "revision"},
+	)
+
+	// ObservedGenerationLag tracks the number of generations of a StatefulSet that have not been observed by the
+	// controller yet. It is set from the StatefulSets seen by the informer, so a lag that does not go back to 0
+	// means the controller is not keeping up with changes to the StatefulSet.
+	ObservedGenerationLag = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_observed_generation_lag",
+			Help:           "Difference between the generation of the StatefulSet and the generation observed in its status",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name"},
+	)
+
+	// StatusCondition tracks the conditions of a StatefulSet. For each condition type, the series with the
+	// current status of the condition is 1 and the others are 0.
+	StatusCondition = metrics.NewGaugeVec(
+		&metrics.GaugeOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "statefulset_status_condition",
+			Help:           "Status of the conditions of the StatefulSet",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name", "condition", "status"},
+	)
+
+	// SyncDuration tracks how long it takes to sync a StatefulSet, by whether the sync returned an error.
+	SyncDuration = metrics.NewHistogramVec(
+		&metrics.HistogramOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "sync_duration_seconds",
+			Help:           "Duration of StatefulSet syncs in seconds",
+			Buckets:        metrics.ExponentialBuckets(0.001, 2, 15),
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"result"},
+	)
+
+	// PodOperations counts the create, update and delete requests for the pods of StatefulSets, by whether they
+	// succeeded.
+	//
+	// Sample monitoring queries:
+	// - Failed pod operations: sum by (operation) (rate(statefulset_controller_pod_operations_total{result="error"}[5m]))
+	PodOperations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "pod_operations_total",
+			Help:           "Number of pod create, update and delete requests made by the StatefulSet controller",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"operation", "result"},
+	)
+
+	// ClaimOperations counts the create and update requests for the PersistentVolumeClaims of StatefulSets, by
+	// whether they succeeded.
+	ClaimOperations = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "pvc_operations_total",
+			Help:           "Number of PersistentVolumeClaim requests made by the StatefulSet controller",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"operation", "result"},
+	)
+
+	// SyncBlocked counts the syncs of a StatefulSet that stopped making progress, by the reason they had to wait.
+	// Together with the workqueue metrics it tells a controller that is busy or rate limited apart from a
+	// StatefulSet that is waiting on its pods.
+	//
+	// Sample monitoring queries:
+	// - Why a StatefulSet does not progress: sum by (reason) (rate(statefulset_controller_sync_blocked_total{statefulset_name="web"}[5m]))
+	SyncBlocked = metrics.NewCounterVec(
+		&metrics.CounterOpts{
+			Subsystem:      StatefulSetControllerSubsystem,
+			Name:           "sync_blocked_total",
+			Help:           "Number of StatefulSet syncs that waited before making further progress, by reason",
+			StabilityLevel: metrics.ALPHA,
+		}, []string{"statefulset_namespace", "statefulset_name", "reason"},
+	)
 )
 
 var registerMetrics sync.Once
 
+// collectors are the metrics of the controller.
+func collectors() []metrics.Registerable {
+	return []metrics.Registerable{
+		MaxUnavailable,
+		UnavailableReplicas,
+		Replicas,
+		StatusReplicas,
+		StatusCurrentReplicas,
+		StatusReadyReplicas,
+		StatusAvailableReplicas,
+		StatusUpdatedReplicas,
+		StatusCurrentRevision,
+		StatusUpdateRevision,
+		ObservedGenerationLag,
+		StatusCondition,
+		SyncDuration,
+		PodOperations,
+		ClaimOperations,
+		SyncBlocked,
+	}
+}
+
+// Register registers the metrics of the controller with the legacy registry.
 func Register() {
 	registerMetrics.Do(func() {
-		legacyregistry.MustRegister(MaxUnavailable)
-		legacyregistry.MustRegister(UnavailableReplicas)
+		legacyregistry.MustRegister(collectors()...)
 	})
 }
+
+// RegisterTo registers the metrics of the controller with registry, for binaries that embed the controller and do
+// not expose the legacy registry.
+func RegisterTo(registry metrics.KubeRegistry) {
+	registry.MustRegister(collectors()...)
+}
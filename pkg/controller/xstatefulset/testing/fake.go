@@ -0,0 +1,315 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/clock"
+)
+
+// ObjectManager is an in-memory StatefulPodControlObjectManager. It stores the objects the way the API server would,
+// assigning UIDs, resource versions and creation timestamps, and gracefully deleting Pods. It is safe for concurrent
+// use.
+type ObjectManager struct {
+	clock clock.PassiveClock
+
+	mu         sync.Mutex
+	pods       map[string]*v1.Pod
+	claims     map[string]*v1.PersistentVolumeClaim
+	nodes      map[string]*v1.Node
+	configMaps map[string]*v1.ConfigMap
+	secrets    map[string]*v1.Secret
+	// version is the last assigned resource version, it also makes UIDs unique.
+	version int
+}
+
+var _ xstatefulset.StatefulPodControlObjectManager = &ObjectManager{}
+
+// NewObjectManager returns an empty ObjectManager that timestamps objects with clock.
+func NewObjectManager(clock clock.PassiveClock) *ObjectManager {
+	return &ObjectManager{
+		clock:      clock,
+		pods:       map[string]*v1.Pod{},
+		claims:     map[string]*v1.PersistentVolumeClaim{},
+		nodes:      map[string]*v1.Node{},
+		configMaps: map[string]*v1.ConfigMap{},
+		secrets:    map[string]*v1.Secret{},
+	}
+}
+
+func objectKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// create initializes the metadata the API server sets on a new object. om.mu must be held.
+func (om *ObjectManager) create(meta *metav1.ObjectMeta) {
+	om.version++
+	meta.UID = types.UID(fmt.Sprintf("%s-%d", meta.Name, om.version))
+	meta.ResourceVersion = fmt.Sprint(om.version)
+	meta.CreationTimestamp = metav1.NewTime(om.clock.Now())
+}
+
+// update bumps the resource version of an updated object. om.mu must be held.
+func (om *ObjectManager) update(meta *metav1.ObjectMeta) {
+	om.version++
+	meta.ResourceVersion = fmt.Sprint(om.version)
+}
+
+// CreatePod stores pod in the Pending phase.
+func (om *ObjectManager) CreatePod(ctx context.Context, pod *v1.Pod) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(pod.Namespace, pod.Name)
+	if _, ok := om.pods[key]; ok {
+		return apierrors.NewAlreadyExists(v1.Resource("pods"), pod.Name)
+	}
+	pod = pod.DeepCopy()
+	om.create(&pod.ObjectMeta)
+	pod.Status = v1.PodStatus{Phase: v1.PodPending}
+	om.pods[key] = pod
+	return nil
+}
+
+func (om *ObjectManager) GetPod(namespace, podName string) (*v1.Pod, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	pod, ok := om.pods[objectKey(namespace, podName)]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("pods"), podName)
+	}
+	return pod.DeepCopy(), nil
+}
+
+// UpdatePod updates the metadata and spec of pod. Like an update through the API server, it leaves the status and
+// deletion timestamp of pod unchanged.
+func (om *ObjectManager) UpdatePod(ctx context.Context, pod *v1.Pod) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(pod.Namespace, pod.Name)
+	stored, ok := om.pods[key]
+	if !ok {
+		return apierrors.NewNotFound(v1.Resource("pods"), pod.Name)
+	}
+	pod = pod.DeepCopy()
+	pod.UID, pod.CreationTimestamp, pod.DeletionTimestamp = stored.UID, stored.CreationTimestamp, stored.DeletionTimestamp
+	pod.Status = stored.Status
+	om.update(&pod.ObjectMeta)
+	om.pods[key] = pod
+	return nil
+}
+
+// DeletePod marks pod as terminating. It is removed once a PodSimulator terminates it, or by ForceDeletePod.
+func (om *ObjectManager) DeletePod(ctx context.Context, pod *v1.Pod) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	stored, ok := om.pods[objectKey(pod.Namespace, pod.Name)]
+	if !ok {
+		return apierrors.NewNotFound(v1.Resource("pods"), pod.Name)
+	}
+	if stored.DeletionTimestamp == nil {
+		now := metav1.NewTime(om.clock.Now())
+		stored.DeletionTimestamp = &now
+		om.update(&stored.ObjectMeta)
+	}
+	return nil
+}
+
+// ForceDeletePod removes pod immediately.
+func (om *ObjectManager) ForceDeletePod(ctx context.Context, pod *v1.Pod) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(pod.Namespace, pod.Name)
+	if _, ok := om.pods[key]; !ok {
+		return apierrors.NewNotFound(v1.Resource("pods"), pod.Name)
+	}
+	delete(om.pods, key)
+	return nil
+}
+
+// CreateClaim stores claim as Bound.
+func (om *ObjectManager) CreateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(claim.Namespace, claim.Name)
+	if _, ok := om.claims[key]; ok {
+		return apierrors.NewAlreadyExists(v1.Resource("persistentvolumeclaims"), claim.Name)
+	}
+	claim = claim.DeepCopy()
+	om.create(&claim.ObjectMeta)
+	claim.Status.Phase = v1.ClaimBound
+	om.claims[key] = claim
+	return nil
+}
+
+func (om *ObjectManager) GetClaim(namespace, claimName string) (*v1.PersistentVolumeClaim, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	claim, ok := om.claims[objectKey(namespace, claimName)]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("persistentvolumeclaims"), claimName)
+	}
+	return claim.DeepCopy(), nil
+}
+
+func (om *ObjectManager) UpdateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(claim.Namespace, claim.Name)
+	stored, ok := om.claims[key]
+	if !ok {
+		return apierrors.NewNotFound(v1.Resource("persistentvolumeclaims"), claim.Name)
+	}
+	claim = claim.DeepCopy()
+	claim.UID, claim.CreationTimestamp = stored.UID, stored.CreationTimestamp
+	om.update(&claim.ObjectMeta)
+	om.claims[key] = claim
+	return nil
+}
+
+func (om *ObjectManager) GetNode(nodeName string) (*v1.Node, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	node, ok := om.nodes[nodeName]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("nodes"), nodeName)
+	}
+	return node.DeepCopy(), nil
+}
+
+func (om *ObjectManager) GetConfigMap(namespace, name string) (*v1.ConfigMap, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	configMap, ok := om.configMaps[objectKey(namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("configmaps"), name)
+	}
+	return configMap.DeepCopy(), nil
+}
+
+func (om *ObjectManager) GetSecret(namespace, name string) (*v1.Secret, error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	secret, ok := om.secrets[objectKey(namespace, name)]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("secrets"), name)
+	}
+	return secret.DeepCopy(), nil
+}
+
+// SetNode stores node, replacing any Node of the same name.
+func (om *ObjectManager) SetNode(node *v1.Node) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.nodes[node.Name] = node.DeepCopy()
+}
+
+// SetConfigMap stores configMap, replacing any ConfigMap of the same name.
+func (om *ObjectManager) SetConfigMap(configMap *v1.ConfigMap) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.configMaps[objectKey(configMap.Namespace, configMap.Name)] = configMap.DeepCopy()
+}
+
+// SetSecret stores secret, replacing any Secret of the same name.
+func (om *ObjectManager) SetSecret(secret *v1.Secret) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.secrets[objectKey(secret.Namespace, secret.Name)] = secret.DeepCopy()
+}
+
+// ListPods returns copies of the Pods in namespace, or in all namespaces for metav1.NamespaceAll, that match selector.
+func (om *ObjectManager) ListPods(namespace string, selector labels.Selector) []*v1.Pod {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	var pods []*v1.Pod
+	for _, pod := range om.pods {
+		if (namespace == metav1.NamespaceAll || pod.Namespace == namespace) && selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, pod.DeepCopy())
+		}
+	}
+	return pods
+}
+
+// ListClaims returns copies of the PersistentVolumeClaims in namespace.
+func (om *ObjectManager) ListClaims(namespace string) []*v1.PersistentVolumeClaim {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	var claims []*v1.PersistentVolumeClaim
+	for _, claim := range om.claims {
+		if claim.Namespace == namespace {
+			claims = append(claims, claim.DeepCopy())
+		}
+	}
+	return claims
+}
+
+// updatePod applies mutate to the stored Pod namespace/name. mutate returns false to remove the Pod.
+func (om *ObjectManager) updatePod(namespace, name string, mutate func(pod *v1.Pod) bool) error {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	key := objectKey(namespace, name)
+	pod, ok := om.pods[key]
+	if !ok {
+		return apierrors.NewNotFound(v1.Resource("pods"), name)
+	}
+	if !mutate(pod) {
+		delete(om.pods, key)
+		return nil
+	}
+	om.update(&pod.ObjectMeta)
+	return nil
+}
+
+// StatusUpdater is an in-memory StatefulSetStatusUpdaterInterface that records the last status written for each
+// StatefulSet. It is safe for concurrent use.
+type StatusUpdater struct {
+	mu       sync.Mutex
+	statuses map[string]xstsappv1.XStatefulSetStatus
+}
+
+var _ xstatefulset.StatefulSetStatusUpdaterInterface = &StatusUpdater{}
+
+// NewStatusUpdater returns a StatusUpdater that has not recorded any status.
+func NewStatusUpdater() *StatusUpdater {
+	return &StatusUpdater{statuses: map[string]xstsappv1.XStatefulSetStatus{}}
+}
+
+func (su *StatusUpdater) UpdateStatefulSetStatus(ctx context.Context, set *xstsappv1.XStatefulSet, status *xstsappv1.XStatefulSetStatus) error {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	set.Status = *status
+	su.statuses[objectKey(set.Namespace, set.Name)] = *status.DeepCopy()
+	return nil
+}
+
+// Status returns the last status written for the StatefulSet namespace/name, if any.
+func (su *StatusUpdater) Status(namespace, name string) (xstsappv1.XStatefulSetStatus, bool) {
+	su.mu.Lock()
+	defer su.mu.Unlock()
+	status, ok := su.statuses[objectKey(namespace, name)]
+	return *status.DeepCopy(), ok
+}
@@ -0,0 +1,228 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package testing simulates the rollouts of XStatefulSets in memory. A Harness drives the control loop of the
+// xstatefulset controller against fake Pods, PersistentVolumeClaims and ControllerRevisions step by step, while a
+// PodSimulator runs the Pods through their lifecycle as timed by a fake clock, so that rollout policies can be
+// validated deterministically without a cluster.
+package testing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+// Options configure a Harness.
+type Options struct {
+	// Start is the time the clock of the simulation starts at. It defaults to the current time.
+	Start time.Time
+	// StepInterval is how far the clock advances with every step. It defaults to one second.
+	StepInterval time.Duration
+	// Lifecycle times the simulated Pods.
+	Lifecycle PodLifecycle
+	// Extensions are called by the controller while syncing the StatefulSet, they may be nil.
+	Extensions *xstatefulset.Extensions
+	// Recorder records the events of the controller. Events are discarded by default.
+	Recorder record.EventRecorder
+}
+
+// Harness drives the rollout of a single StatefulSet step by step. Every step syncs the StatefulSet once, like the
+// controller does when it takes the StatefulSet from its queue, then advances the clock and the simulated Pods. A
+// Harness is not safe for concurrent use.
+type Harness struct {
+	Clock         *testingclock.FakeClock
+	Objects       *ObjectManager
+	StatusUpdater *StatusUpdater
+	History       history.Interface
+	Simulator     *PodSimulator
+
+	control  xstatefulset.StatefulSetControlInterface
+	interval time.Duration
+	set      *xstsappv1.XStatefulSet
+	steps    int
+}
+
+// NewHarness returns a Harness that simulates the rollout of set as configured by opts. The defaults of the API are
+// applied to a copy of set, which is given a UID and a generation if it has none.
+func NewHarness(set *xstsappv1.XStatefulSet, opts Options) *Harness {
+	start := opts.Start
+	if start.IsZero() {
+		start = time.Now()
+	}
+	interval := opts.StepInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	recorder := opts.Recorder
+	if recorder == nil {
+		// a FakeRecorder without a channel discards events
+		recorder = &record.FakeRecorder{}
+	}
+
+	set = set.DeepCopy()
+	if set.UID == "" {
+		set.UID = types.UID(set.Name + "-uid")
+	}
+	if set.Generation == 0 {
+		set.Generation = 1
+	}
+	legacyscheme.Scheme.Default(set)
+
+	fakeClock := testingclock.NewFakeClock(start)
+	objects := NewObjectManager(fakeClock)
+	statusUpdater := NewStatusUpdater()
+	revisions := informers.NewSharedInformerFactory(fake.NewClientset(), 0).Apps().V1().ControllerRevisions()
+	controllerHistory := history.NewFakeHistory(revisions)
+	podControl := xstatefulset.NewStatefulPodControlFromManager(objects, recorder)
+	return &Harness{
+		Clock:         fakeClock,
+		Objects:       objects,
+		StatusUpdater: statusUpdater,
+		History:       controllerHistory,
+		Simulator:     NewPodSimulator(objects, fakeClock, opts.Lifecycle),
+		control:       xstatefulset.NewDefaultStatefulSetControl(podControl, statusUpdater, controllerHistory, opts.Extensions, fakeClock),
+		interval:      interval,
+		set:           set,
+	}
+}
+
+// Set returns a copy of the simulated StatefulSet with the last status the controller wrote.
+func (h *Harness) Set() *xstsappv1.XStatefulSet {
+	return h.set.DeepCopy()
+}
+
+// UpdateSet applies mutate to the spec of the StatefulSet as a user would, and bumps its generation.
+func (h *Harness) UpdateSet(mutate func(set *xstsappv1.XStatefulSet)) {
+	set := h.set.DeepCopy()
+	mutate(set)
+	set.Generation = h.set.Generation + 1
+	set.Status = h.set.Status
+	legacyscheme.Scheme.Default(set)
+	h.set = set
+}
+
+// Pods returns copies of the Pods of the StatefulSet ordered by their ordinal.
+func (h *Harness) Pods() []*v1.Pod {
+	selector, err := metav1.LabelSelectorAsSelector(h.set.Spec.Selector)
+	if err != nil {
+		return nil
+	}
+	var pods []*v1.Pod
+	for _, pod := range h.Objects.ListPods(h.set.Namespace, selector) {
+		if ref := metav1.GetControllerOf(pod); ref != nil && ref.UID == h.set.UID {
+			pods = append(pods, pod)
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		return podOrdinal(pods[i]) < podOrdinal(pods[j])
+	})
+	return pods
+}
+
+// podOrdinal returns the ordinal of pod from its index label, or -1 if it has none.
+func podOrdinal(pod *v1.Pod) int {
+	ordinal, err := strconv.Atoi(pod.Labels[xstsappv1.PodIndexLabel])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
+
+// Revisions returns the ControllerRevisions of the StatefulSet.
+func (h *Harness) Revisions() ([]*apps.ControllerRevision, error) {
+	selector, err := metav1.LabelSelectorAsSelector(h.set.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return h.History.ListControllerRevisions(h.set, selector)
+}
+
+// Steps returns the number of steps taken so far.
+func (h *Harness) Steps() int {
+	return h.steps
+}
+
+// Sync syncs the StatefulSet once without advancing the simulation.
+func (h *Harness) Sync(ctx context.Context) error {
+	_, err := h.control.UpdateStatefulSet(ctx, h.set, h.Pods())
+	if status, ok := h.StatusUpdater.Status(h.set.Namespace, h.set.Name); ok {
+		h.set.Status = status
+	}
+	return err
+}
+
+// Plan returns the writes that a sync of the StatefulSet would make, without making them or advancing the
+// simulation.
+func (h *Harness) Plan(ctx context.Context) (*xstatefulset.Plan, error) {
+	return xstatefulset.PlanStatefulSet(ctx, h.control, h.set, h.Pods())
+}
+
+// Step syncs the StatefulSet, then advances the clock by the step interval and the simulated Pods to the new time.
+// The simulation advances even if the sync fails, and the error of the sync is returned.
+func (h *Harness) Step(ctx context.Context) error {
+	err := h.Sync(ctx)
+	h.Clock.Step(h.interval)
+	h.Simulator.Step()
+	h.steps++
+	return err
+}
+
+// RunUntil takes steps until done returns true. It fails if a sync fails, or if done still returns false after
+// maxSteps steps.
+func (h *Harness) RunUntil(ctx context.Context, done func(h *Harness) bool, maxSteps int) error {
+	for i := 0; i < maxSteps; i++ {
+		if done(h) {
+			return nil
+		}
+		if err := h.Step(ctx); err != nil {
+			return fmt.Errorf("step %d failed: %w", h.steps, err)
+		}
+	}
+	if done(h) {
+		return nil
+	}
+	return fmt.Errorf("condition not met after %d steps", maxSteps)
+}
+
+// RolloutComplete returns true once the controller observed the latest generation of the StatefulSet, and all of its
+// replicas are available at the update revision with no other Pods left.
+func RolloutComplete(h *Harness) bool {
+	set := h.set
+	replicas := *set.Spec.Replicas
+	status := set.Status
+	return status.ObservedGeneration >= set.Generation &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas &&
+		status.UpdatedReplicas == replicas &&
+		status.CurrentRevision == status.UpdateRevision &&
+		len(h.Pods()) == int(replicas)
+}
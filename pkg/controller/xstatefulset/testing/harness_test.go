@@ -0,0 +1,414 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+)
+
+func newSet(replicas int32) *xstsappv1.XStatefulSet {
+	labels := map[string]string{"app": "web"}
+	return &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas:        ptr.To(replicas),
+			Selector:        &metav1.LabelSelector{MatchLabels: labels},
+			MinReadySeconds: 5,
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+	}
+}
+
+func newHarness(t *testing.T, set *xstsappv1.XStatefulSet) *Harness {
+	t.Helper()
+	h := NewHarness(set, Options{
+		Start:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Lifecycle: PodLifecycle{StartDelay: 2 * time.Second, ReadyDelay: 3 * time.Second, TerminationDelay: 2 * time.Second},
+	})
+	if err := h.RunUntil(context.Background(), RolloutComplete, 200); err != nil {
+		t.Fatalf("initial rollout: %v", err)
+	}
+	return h
+}
+
+func TestRollingUpdate(t *testing.T) {
+	h := newHarness(t, newSet(3))
+	if claims := h.Objects.ListClaims("default"); len(claims) != 3 {
+		t.Errorf("expected a claim for each replica, got %d", len(claims))
+	}
+
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Template.Spec.Containers[0].Image = "web:v2"
+	})
+	maxUnavailable := 0
+	err := h.RunUntil(context.Background(), func(h *Harness) bool {
+		unavailable := 3 - len(h.Pods())
+		for _, pod := range h.Pods() {
+			if readyCondition(pod).Status != v1.ConditionTrue || pod.DeletionTimestamp != nil {
+				unavailable++
+			}
+		}
+		maxUnavailable = max(maxUnavailable, unavailable)
+		return RolloutComplete(h)
+	}, 200)
+	if err != nil {
+		t.Fatalf("rolling update: %v", err)
+	}
+	if maxUnavailable != 1 {
+		t.Errorf("expected the rolling update to take down one pod at a time, at most %d were unavailable", maxUnavailable)
+	}
+	for _, pod := range h.Pods() {
+		if pod.Labels[xstsappv1.StatefulSetRevisionLabel] != h.Set().Status.UpdateRevision || pod.Spec.Containers[0].Image != "web:v2" {
+			t.Errorf("expected pod %s to be updated", pod.Name)
+		}
+	}
+}
+
+func TestFailedPodsAreReplaced(t *testing.T) {
+	h := newHarness(t, newSet(2))
+	evicted := h.Pods()[1]
+	if err := h.Simulator.Evict("default", evicted.Name); err != nil {
+		t.Fatalf("Evict() error = %v", err)
+	}
+	if err := h.Simulator.Crash("default", h.Pods()[0].Name); err != nil {
+		t.Fatalf("Crash() error = %v", err)
+	}
+	if err := h.Step(context.Background()); err != nil {
+		t.Fatalf("Step() error = %v", err)
+	}
+	if status := h.Set().Status; status.AvailableReplicas != 0 {
+		t.Errorf("expected the crashed and evicted pods not to be available, got %d available replicas", status.AvailableReplicas)
+	}
+	if err := h.RunUntil(context.Background(), RolloutComplete, 200); err != nil {
+		t.Fatalf("recovery: %v", err)
+	}
+	if replaced := h.Pods()[1]; replaced.UID == evicted.UID {
+		t.Errorf("expected the evicted pod to be replaced")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	h := newHarness(t, newSet(2))
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Template.Spec.Containers[0].Image = "web:v2"
+	})
+	pods := h.Pods()
+	plan, err := h.Plan(context.Background())
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	want := []xstatefulset.Action{
+		{Type: xstatefulset.ActionCreateRevision, Namespace: "default"},
+		{Type: xstatefulset.ActionDeletePod, Namespace: "default", Name: "web-1"},
+		{Type: xstatefulset.ActionUpdateStatus, Namespace: "default", Name: "web"},
+	}
+	if len(plan.Actions) != len(want) {
+		t.Fatalf("expected actions %v, got %v", want, plan.Actions)
+	}
+	for i, action := range plan.Actions {
+		// the name of the new revision is a hash of the template
+		if action.Type == xstatefulset.ActionCreateRevision {
+			action.Name = ""
+		}
+		if action != want[i] {
+			t.Errorf("expected action %d to be %v, got %v", i, want[i], action)
+		}
+	}
+	if deletions := plan.Deletions(); len(deletions) != 1 || deletions[0].Name != "web-1" {
+		t.Errorf("expected only web-1 to be deleted, got %v", deletions)
+	}
+	if plan.Status == nil || plan.Status.UpdateRevision == h.Set().Status.UpdateRevision {
+		t.Errorf("expected the planned status to report the new revision, got %+v", plan.Status)
+	}
+
+	// nothing is written while planning
+	if got := h.Pods(); len(got) != len(pods) || got[1].DeletionTimestamp != nil {
+		t.Errorf("expected the pods to be left unchanged, got %d pods", len(got))
+	}
+	if revisions, err := h.Revisions(); err != nil || len(revisions) != 1 {
+		t.Errorf("expected no revision to be created, got %d revisions, error %v", len(revisions), err)
+	}
+}
+
+func TestSuspendAndResume(t *testing.T) {
+	set := newSet(3)
+	// claims of pods that are scaled down are deleted, those of suspended pods are not
+	set.Spec.PersistentVolumeClaimRetentionPolicy = &apps.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenScaled:  apps.DeletePersistentVolumeClaimRetentionPolicyType,
+		WhenDeleted: apps.RetainPersistentVolumeClaimRetentionPolicyType,
+	}
+	h := newHarness(t, set)
+	claims := map[string]types.UID{}
+	for _, claim := range h.Objects.ListClaims("default") {
+		claims[claim.Name] = claim.UID
+	}
+	if len(claims) != 3 {
+		t.Fatalf("expected 3 claims, got %d", len(claims))
+	}
+
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Suspend = ptr.To(true)
+	})
+	err := h.RunUntil(context.Background(), func(h *Harness) bool {
+		return len(h.Pods()) == 0 && h.Set().Status.Replicas == 0
+	}, 200)
+	if err != nil {
+		t.Fatalf("suspend: %v", err)
+	}
+	if replicas := *h.Set().Spec.Replicas; replicas != 3 {
+		t.Errorf("expected suspending to leave the replicas at 3, got %d", replicas)
+	}
+	for _, claim := range h.Objects.ListClaims("default") {
+		for _, ref := range claim.OwnerReferences {
+			if ref.Kind == "Pod" {
+				t.Errorf("expected claim %s of a suspended pod to be retained, it is owned by pod %s", claim.Name, ref.Name)
+			}
+		}
+	}
+
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Suspend = nil
+	})
+	if err := h.RunUntil(context.Background(), RolloutComplete, 200); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	for i, pod := range h.Pods() {
+		if want := fmt.Sprintf("web-%d", i); pod.Name != want {
+			t.Errorf("expected resuming to restore pod %s, got %s", want, pod.Name)
+		}
+	}
+	restored := h.Objects.ListClaims("default")
+	if len(restored) != len(claims) {
+		t.Errorf("expected the %d claims to be kept, got %d", len(claims), len(restored))
+	}
+	for _, claim := range restored {
+		if uid, ok := claims[claim.Name]; !ok || uid != claim.UID {
+			t.Errorf("expected claim %s to be the one from before the suspension", claim.Name)
+		}
+	}
+}
+
+type labelingMutator struct{}
+
+func (labelingMutator) MutatePod(_ context.Context, _ *xstsappv1.XStatefulSet, pod *v1.Pod) error {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations["mutated"] = "true"
+	return nil
+}
+
+// greedyUpdateTargets selects every Pod of the harness, and a Pod that does not exist, whatever the candidates are.
+type greedyUpdateTargets struct {
+	h **Harness
+}
+
+func (s greedyUpdateTargets) SelectUpdateTargets(context.Context, *xstsappv1.XStatefulSet, []*v1.Pod) []*v1.Pod {
+	return append((*s.h).Pods(), &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cache-0"}})
+}
+
+// conditionContributor sets the Contributed condition, replacing the one of the previous sync.
+type conditionContributor struct{}
+
+func (conditionContributor) ContributeStatus(_ context.Context, _ *xstsappv1.XStatefulSet, _ []*v1.Pod, status *xstsappv1.XStatefulSetStatus) error {
+	condition := apps.StatefulSetCondition{Type: "Contributed", Status: v1.ConditionTrue}
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condition.Type {
+			status.Conditions[i] = condition
+			return nil
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+	return nil
+}
+
+type failingContributor struct {
+	fail *bool
+}
+
+func (c failingContributor) ContributeStatus(context.Context, *xstsappv1.XStatefulSet, []*v1.Pod, *xstsappv1.XStatefulSetStatus) error {
+	if *c.fail {
+		return fmt.Errorf("contributor unavailable")
+	}
+	return nil
+}
+
+func TestExtensions(t *testing.T) {
+	var h *Harness
+	fail := false
+	set := newSet(3)
+	set.Spec.UpdateStrategy = apps.StatefulSetUpdateStrategy{
+		Type:          apps.RollingUpdateStatefulSetStrategyType,
+		RollingUpdate: &apps.RollingUpdateStatefulSetStrategy{Partition: ptr.To[int32](2)},
+	}
+	// a condition that was set on the status by another writer
+	set.Status.Conditions = []apps.StatefulSetCondition{{Type: "Maintained", Status: v1.ConditionTrue, Reason: "Backup"}}
+	h = NewHarness(set, Options{
+		Start:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Lifecycle: PodLifecycle{StartDelay: 2 * time.Second, ReadyDelay: 3 * time.Second, TerminationDelay: 2 * time.Second},
+		Extensions: xstatefulset.NewExtensions().
+			RegisterPodMutator(labelingMutator{}).
+			RegisterUpdateTargetSelector(greedyUpdateTargets{h: &h}).
+			RegisterStatusContributor(conditionContributor{}).
+			RegisterStatusContributor(failingContributor{fail: &fail}),
+	})
+	ctx := context.Background()
+	if err := h.RunUntil(ctx, RolloutComplete, 200); err != nil {
+		t.Fatalf("initial rollout: %v", err)
+	}
+	for _, pod := range h.Pods() {
+		if pod.Annotations["mutated"] != "true" {
+			t.Errorf("expected pod %s to be mutated", pod.Name)
+		}
+	}
+
+	// the selector can not update pods below the partition, or pods that are not candidates
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Template.Spec.Containers[0].Image = "web:v2"
+	})
+	err := h.RunUntil(ctx, func(h *Harness) bool {
+		return h.Set().Status.UpdatedReplicas == 1 && h.Set().Status.AvailableReplicas == 3
+	}, 200)
+	if err != nil {
+		t.Fatalf("partitioned rollout: %v", err)
+	}
+	for i, pod := range h.Pods() {
+		want := "web:v1"
+		if i >= 2 {
+			want = "web:v2"
+		}
+		if image := pod.Spec.Containers[0].Image; image != want {
+			t.Errorf("expected pod %s to run %s, got %s", pod.Name, want, image)
+		}
+	}
+
+	// the status is still written when a contributor fails
+	fail = true
+	h.UpdateSet(func(set *xstsappv1.XStatefulSet) {
+		set.Spec.Replicas = ptr.To[int32](4)
+	})
+	if err := h.Sync(ctx); err == nil {
+		t.Fatalf("expected the sync to fail with the contributor")
+	}
+	status := h.Set().Status
+	if status.ObservedGeneration != h.Set().Generation {
+		t.Errorf("expected the status of the sync to be written, got %+v", status)
+	}
+	wantConditions := []apps.StatefulSetCondition{
+		{Type: "Maintained", Status: v1.ConditionTrue, Reason: "Backup"},
+		{Type: "Contributed", Status: v1.ConditionTrue},
+	}
+	if !reflect.DeepEqual(status.Conditions, wantConditions) {
+		t.Errorf("expected the status to keep the existing and the contributed conditions, got %+v", status.Conditions)
+	}
+}
+
+// dryRunRecorder records for each call of an extension whether the sync was a dry run.
+type dryRunRecorder struct {
+	calls map[string][]bool
+}
+
+func (r *dryRunRecorder) record(ctx context.Context, extension string) {
+	r.calls[extension] = append(r.calls[extension], xstatefulset.IsDryRun(ctx))
+}
+
+func (r *dryRunRecorder) MutatePod(ctx context.Context, _ *xstsappv1.XStatefulSet, _ *v1.Pod) error {
+	r.record(ctx, "MutatePod")
+	return nil
+}
+
+func (r *dryRunRecorder) AllowDeletion(ctx context.Context, _ *xstsappv1.XStatefulSet, _ *v1.Pod) (xstatefulset.DeletionDecision, error) {
+	r.record(ctx, "AllowDeletion")
+	return xstatefulset.DeletionDecision{Allowed: true}, nil
+}
+
+func (r *dryRunRecorder) ContributeStatus(ctx context.Context, _ *xstsappv1.XStatefulSet, _ []*v1.Pod, _ *xstsappv1.XStatefulSetStatus) error {
+	r.record(ctx, "ContributeStatus")
+	return nil
+}
+
+func TestPlanExtensions(t *testing.T) {
+	recorder := &dryRunRecorder{calls: map[string][]bool{}}
+	h := NewHarness(newSet(2), Options{
+		Start:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Lifecycle: PodLifecycle{StartDelay: 2 * time.Second, ReadyDelay: 3 * time.Second, TerminationDelay: 2 * time.Second},
+		Extensions: xstatefulset.NewExtensions().
+			RegisterPodMutator(recorder).
+			RegisterDeletionGate(recorder).
+			RegisterStatusContributor(recorder),
+	})
+	ctx := context.Background()
+	if err := h.RunUntil(ctx, RolloutComplete, 200); err != nil {
+		t.Fatalf("initial rollout: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		update func(set *xstsappv1.XStatefulSet)
+		want   map[string][]bool
+	}{
+		{
+			name:   "scale up",
+			update: func(set *xstsappv1.XStatefulSet) { set.Spec.Replicas = ptr.To[int32](3) },
+			want:   map[string][]bool{"MutatePod": {true}, "ContributeStatus": {true}},
+		},
+		{
+			name:   "update",
+			update: func(set *xstsappv1.XStatefulSet) { set.Spec.Template.Spec.Containers[0].Image = "web:v2" },
+			want:   map[string][]bool{"AllowDeletion": {true}, "ContributeStatus": {true}},
+		},
+	}
+	for _, test := range tests {
+		h.UpdateSet(test.update)
+		recorder.calls = map[string][]bool{}
+		if _, err := h.Plan(ctx); err != nil {
+			t.Fatalf("%s: Plan() error = %v", test.name, err)
+		}
+		if !reflect.DeepEqual(recorder.calls, test.want) {
+			t.Errorf("%s: expected the plan to call the extensions for a dry run as %v, got %v", test.name, test.want, recorder.calls)
+		}
+		// the sync makes the planned writes
+		recorder.calls = map[string][]bool{}
+		if err := h.Sync(ctx); err != nil {
+			t.Fatalf("%s: Sync() error = %v", test.name, err)
+		}
+		for extension, calls := range test.want {
+			if got := recorder.calls[extension]; len(got) != len(calls) || got[0] {
+				t.Errorf("%s: expected the sync to call %s once not for a dry run, got %v", test.name, extension, got)
+			}
+		}
+		if err := h.RunUntil(ctx, RolloutComplete, 200); err != nil {
+			t.Fatalf("%s: rollout: %v", test.name, err)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package testing
+
+import (
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/clock"
+)
+
+// PodLifecycle times how the simulated kubelet runs Pods. A delay of 0 completes the transition on the next step.
+type PodLifecycle struct {
+	// StartDelay is how long a Pod stays Pending before it is Running.
+	StartDelay time.Duration
+	// ReadyDelay is how long a Running Pod, or a Pod that crashed, takes to become Ready.
+	ReadyDelay time.Duration
+	// TerminationDelay is how long a deleted Pod takes to terminate.
+	TerminationDelay time.Duration
+}
+
+// PodSimulator runs the Pods of an ObjectManager through their lifecycle, pending, running, ready and terminated,
+// as timed by a clock, and injects crashes and evictions.
+type PodSimulator struct {
+	objects   *ObjectManager
+	clock     clock.PassiveClock
+	lifecycle PodLifecycle
+}
+
+// NewPodSimulator returns a PodSimulator that runs the Pods of objects as timed by clock and lifecycle.
+func NewPodSimulator(objects *ObjectManager, clock clock.PassiveClock, lifecycle PodLifecycle) *PodSimulator {
+	return &PodSimulator{objects: objects, clock: clock, lifecycle: lifecycle}
+}
+
+// Step advances every Pod through the transitions that are due at the current time of the clock.
+func (s *PodSimulator) Step() {
+	now := s.clock.Now()
+	for _, pod := range s.objects.ListPods(metav1.NamespaceAll, labels.Everything()) {
+		_ = s.objects.updatePod(pod.Namespace, pod.Name, func(pod *v1.Pod) bool {
+			return s.advance(pod, now)
+		})
+	}
+}
+
+// advance applies the transitions of pod that are due at now. It returns false if pod has terminated.
+func (s *PodSimulator) advance(pod *v1.Pod, now time.Time) bool {
+	if pod.DeletionTimestamp != nil {
+		return now.Before(pod.DeletionTimestamp.Add(s.lifecycle.TerminationDelay))
+	}
+	if pod.Status.Phase == v1.PodPending && !now.Before(pod.CreationTimestamp.Add(s.lifecycle.StartDelay)) {
+		pod.Status.Phase = v1.PodRunning
+		pod.Status.StartTime = &metav1.Time{Time: now}
+		setReady(pod, v1.ConditionFalse, now)
+	}
+	if pod.Status.Phase == v1.PodRunning {
+		if ready := readyCondition(pod); ready.Status != v1.ConditionTrue && !now.Before(ready.LastTransitionTime.Add(s.lifecycle.ReadyDelay)) {
+			setReady(pod, v1.ConditionTrue, now)
+		}
+	}
+	return true
+}
+
+// Crash makes the Running Pod namespace/name not Ready, as if its containers restarted. It becomes Ready again after
+// the ReadyDelay of the lifecycle.
+func (s *PodSimulator) Crash(namespace, name string) error {
+	return s.objects.updatePod(namespace, name, func(pod *v1.Pod) bool {
+		if pod.Status.Phase == v1.PodRunning {
+			setReady(pod, v1.ConditionFalse, s.clock.Now())
+		}
+		return true
+	})
+}
+
+// Evict fails the Pod namespace/name as the kubelet does when it evicts a Pod. The Pod stays Failed until it is
+// deleted.
+func (s *PodSimulator) Evict(namespace, name string) error {
+	return s.objects.updatePod(namespace, name, func(pod *v1.Pod) bool {
+		pod.Status.Phase = v1.PodFailed
+		pod.Status.Reason = "Evicted"
+		setReady(pod, v1.ConditionFalse, s.clock.Now())
+		return true
+	})
+}
+
+// readyCondition returns the Ready condition of pod, or an Unknown one if pod has none.
+func readyCondition(pod *v1.Pod) v1.PodCondition {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodReady {
+			return condition
+		}
+	}
+	return v1.PodCondition{Type: v1.PodReady, Status: v1.ConditionUnknown}
+}
+
+// setReady sets the Ready condition of pod to status, and its transition time to now if status changed.
+func setReady(pod *v1.Pod, status v1.ConditionStatus, now time.Time) {
+	for i := range pod.Status.Conditions {
+		if condition := &pod.Status.Conditions[i]; condition.Type == v1.PodReady {
+			if condition.Status != status {
+				condition.Status = status
+				condition.LastTransitionTime = metav1.NewTime(now)
+			}
+			return
+		}
+	}
+	pod.Status.Conditions = append(pod.Status.Conditions, v1.PodCondition{
+		Type:               v1.PodReady,
+		Status:             status,
+		LastTransitionTime: metav1.NewTime(now),
+	})
+}
@@ -35,21 +35,31 @@ package xstatefulset
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
+	"time"
 
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	hashutil "github.com/xsts-sh/xstatefulset/pkg/controller/utils/hash"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	errorutils "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/rand"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clientset "k8s.io/client-go/kubernetes"
 	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
 )
 
 // StatefulPodControlObjectManager abstracts the manipulation of Pods and PVCs. The real controller implements this
@@ -57,11 +67,15 @@ import (
 type StatefulPodControlObjectManager interface {
 	CreatePod(ctx context.Context, pod *v1.Pod) error
 	GetPod(namespace, podName string) (*v1.Pod, error)
-	UpdatePod(pod *v1.Pod) error
-	DeletePod(pod *v1.Pod) error
-	CreateClaim(claim *v1.PersistentVolumeClaim) error
+	UpdatePod(ctx context.Context, pod *v1.Pod) error
+	DeletePod(ctx context.Context, pod *v1.Pod) error
+	ForceDeletePod(ctx context.Context, pod *v1.Pod) error
+	CreateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error
 	GetClaim(namespace, claimName string) (*v1.PersistentVolumeClaim, error)
-	UpdateClaim(claim *v1.PersistentVolumeClaim) error
+	UpdateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error
+	GetNode(nodeName string) (*v1.Node, error)
+	GetConfigMap(namespace, name string) (*v1.ConfigMap, error)
+	GetSecret(namespace, name string) (*v1.Secret, error)
 }
 
 // StatefulPodControl defines the interface that StatefulSetController uses to create, update, and delete Pods,
@@ -71,22 +85,43 @@ type StatefulPodControlObjectManager interface {
 type StatefulPodControl struct {
 	objectMgr StatefulPodControlObjectManager
 	recorder  record.EventRecorder
+	// expectations records the Pod creations and deletions that the StatefulSetController has yet to observe. It is
+	// nil if they are not tracked.
+	expectations *controller.UIDTrackingControllerExpectations
+	// limiter limits the rate of the Pod and PersistentVolumeClaim operations of each StatefulSet. It is nil if they
+	// are not limited.
+	limiter *podOperationLimiter
+	// audit records the writes to Pods and PersistentVolumeClaims. It is nil if they are not audited.
+	audit *AuditLog
+	// dryRun is set if the operations are only planned, or discarded while the status is updated. They are not recorded
+	// in metrics and the audit log then.
+	dryRun bool
 }
 
 // NewStatefulPodControl constructs a StatefulPodControl using a realStatefulPodControlObjectManager with the given
-// clientset, listers and EventRecorder.
+// clientset, listers, EventRecorder and expectations. The operations of each StatefulSet are limited by limits.
 func NewStatefulPodControl(
 	client clientset.Interface,
 	podLister corelisters.PodLister,
 	claimLister corelisters.PersistentVolumeClaimLister,
+	nodeLister corelisters.NodeLister,
+	configMapLister corelisters.ConfigMapLister,
+	secretLister corelisters.SecretLister,
 	recorder record.EventRecorder,
+	expectations *controller.UIDTrackingControllerExpectations,
+	limits PodOperationLimits,
 ) *StatefulPodControl {
-	return &StatefulPodControl{&realStatefulPodControlObjectManager{client, podLister, claimLister}, recorder}
+	return &StatefulPodControl{
+		objectMgr:    &realStatefulPodControlObjectManager{client, podLister, claimLister, nodeLister, configMapLister, secretLister},
+		recorder:     recorder,
+		expectations: expectations,
+		limiter:      newPodOperationLimiter(limits, clock.RealClock{}),
+	}
 }
 
 // NewStatefulPodControlFromManager creates a StatefulPodControl using the given StatefulPodControlObjectManager and recorder.
 func NewStatefulPodControlFromManager(om StatefulPodControlObjectManager, recorder record.EventRecorder) *StatefulPodControl {
-	return &StatefulPodControl{om, recorder}
+	return &StatefulPodControl{objectMgr: om, recorder: recorder}
 }
 
 // realStatefulPodControlObjectManager uses a clientset.Interface and listers.
@@ -94,10 +129,16 @@ type realStatefulPodControlObjectManager struct {
 	client      clientset.Interface
 	podLister   corelisters.PodLister
 	claimLister corelisters.PersistentVolumeClaimLister
+	nodeLister  corelisters.NodeLister
+
+	configMapLister corelisters.ConfigMapLister
+	secretLister    corelisters.SecretLister
 }
 
-func (om *realStatefulPodControlObjectManager) CreatePod(ctx context.Context, pod *v1.Pod) error {
-	_, err := om.client.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
+func (om *realStatefulPodControlObjectManager) CreatePod(ctx context.Context, pod *v1.Pod) (err error) {
+	ctx, span := startSpan(ctx, "CreatePod", objectAttributes("Pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})...)
+	defer func() { endSpan(span, err) }()
+	_, err = om.client.CoreV1().Pods(pod.Namespace).Create(ctx, pod, metav1.CreateOptions{})
 	return err
 }
 
@@ -105,17 +146,32 @@ func (om *realStatefulPodControlObjectManager) GetPod(namespace, podName string)
 	return om.podLister.Pods(namespace).Get(podName)
 }
 
-func (om *realStatefulPodControlObjectManager) UpdatePod(pod *v1.Pod) error {
-	_, err := om.client.CoreV1().Pods(pod.Namespace).Update(context.TODO(), pod, metav1.UpdateOptions{})
+func (om *realStatefulPodControlObjectManager) UpdatePod(ctx context.Context, pod *v1.Pod) (err error) {
+	ctx, span := startSpan(ctx, "UpdatePod", objectAttributes("Pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})...)
+	defer func() { endSpan(span, err) }()
+	_, err = om.client.CoreV1().Pods(pod.Namespace).Update(ctx, pod, metav1.UpdateOptions{})
 	return err
 }
 
-func (om *realStatefulPodControlObjectManager) DeletePod(pod *v1.Pod) error {
-	return om.client.CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+func (om *realStatefulPodControlObjectManager) DeletePod(ctx context.Context, pod *v1.Pod) (err error) {
+	ctx, span := startSpan(ctx, "DeletePod", objectAttributes("Pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})...)
+	defer func() { endSpan(span, err) }()
+	return om.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+}
+
+func (om *realStatefulPodControlObjectManager) ForceDeletePod(ctx context.Context, pod *v1.Pod) (err error) {
+	ctx, span := startSpan(ctx, "ForceDeletePod", objectAttributes("Pod", types.NamespacedName{Namespace: pod.Namespace, Name: pod.Name})...)
+	defer func() { endSpan(span, err) }()
+	return om.client.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{
+		GracePeriodSeconds: ptr.To[int64](0),
+		Preconditions:      metav1.NewUIDPreconditions(string(pod.UID)),
+	})
 }
 
-func (om *realStatefulPodControlObjectManager) CreateClaim(claim *v1.PersistentVolumeClaim) error {
-	_, err := om.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(context.TODO(), claim, metav1.CreateOptions{})
+func (om *realStatefulPodControlObjectManager) CreateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) (err error) {
+	ctx, span := startSpan(ctx, "CreateClaim", objectAttributes("PersistentVolumeClaim", types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name})...)
+	defer func() { endSpan(span, err) }()
+	_, err = om.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(ctx, claim, metav1.CreateOptions{})
 	return err
 }
 
@@ -123,19 +179,42 @@ func (om *realStatefulPodControlObjectManager) GetClaim(namespace, claimName str
 	return om.claimLister.PersistentVolumeClaims(namespace).Get(claimName)
 }
 
-func (om *realStatefulPodControlObjectManager) UpdateClaim(claim *v1.PersistentVolumeClaim) error {
-	_, err := om.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Update(context.TODO(), claim, metav1.UpdateOptions{})
+func (om *realStatefulPodControlObjectManager) UpdateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) (err error) {
+	ctx, span := startSpan(ctx, "UpdateClaim", objectAttributes("PersistentVolumeClaim", types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name})...)
+	defer func() { endSpan(span, err) }()
+	_, err = om.client.CoreV1().PersistentVolumeClaims(claim.Namespace).Update(ctx, claim, metav1.UpdateOptions{})
 	return err
 }
 
+func (om *realStatefulPodControlObjectManager) GetNode(nodeName string) (*v1.Node, error) {
+	return om.nodeLister.Get(nodeName)
+}
+
+func (om *realStatefulPodControlObjectManager) GetConfigMap(namespace, name string) (*v1.ConfigMap, error) {
+	return om.configMapLister.ConfigMaps(namespace).Get(name)
+}
+
+func (om *realStatefulPodControlObjectManager) GetSecret(namespace, name string) (*v1.Secret, error) {
+	return om.secretLister.Secrets(namespace).Get(name)
+}
+
 func (spc *StatefulPodControl) CreateStatefulPod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
 	// Create the Pod's PVCs prior to creating the Pod
-	if err := spc.createPersistentVolumeClaims(set, pod); err != nil {
+	if err := spc.createPersistentVolumeClaims(ctx, set, pod); err != nil {
 		spc.recordPodEvent("create", set, pod, err)
 		return err
 	}
-	// If we created the PVCs attempt to create the Pod
+	// the token of the Pod is only taken once its PVCs exist, so that it is not spent while their creation is throttled
+	if err := spc.limiter.reserve(set, podCreation); err != nil {
+		return err
+	}
+	// If we created the PVCs attempt to create the Pod, which the controller has to observe before it syncs set again
+	spc.expectCreation(ctx, set)
 	err := spc.objectMgr.CreatePod(ctx, pod)
+	spc.recordAudit(ctx, set, "Pod", pod.Name, "create", getPodRevision(pod), err)
+	if err != nil {
+		spc.creationFailed(ctx, set)
+	}
 	// sink already exists errors
 	if apierrors.IsAlreadyExists(err) {
 		return err
@@ -164,7 +243,7 @@ func (spc *StatefulPodControl) UpdateStatefulPod(ctx context.Context, set *xstsa
 		if !storageMatches(set, pod) {
 			updateStorage(set, pod)
 			consistent = false
-			if err := spc.createPersistentVolumeClaims(set, pod); err != nil {
+			if err := spc.createPersistentVolumeClaims(ctx, set, pod); err != nil {
 				spc.recordPodEvent("update", set, pod, err)
 				return err
 			}
@@ -190,7 +269,8 @@ func (spc *StatefulPodControl) UpdateStatefulPod(ctx context.Context, set *xstsa
 		attemptedUpdate = true
 		// commit the update, retrying on conflicts
 
-		updateErr := spc.objectMgr.UpdatePod(pod)
+		updateErr := spc.objectMgr.UpdatePod(ctx, pod)
+		spc.recordAudit(ctx, set, "Pod", pod.Name, "update", getPodRevision(pod), updateErr)
 		if updateErr == nil {
 			return nil
 		}
@@ -210,12 +290,87 @@ func (spc *StatefulPodControl) UpdateStatefulPod(ctx context.Context, set *xstsa
 	return err
 }
 
-func (spc *StatefulPodControl) DeleteStatefulPod(set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
-	err := spc.objectMgr.DeletePod(pod)
+func (spc *StatefulPodControl) DeleteStatefulPod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
+	if err := spc.limiter.reserve(set, podDeletion); err != nil {
+		return err
+	}
+	spc.expectDeletion(ctx, set, pod)
+	err := spc.objectMgr.DeletePod(ctx, pod)
+	spc.recordAudit(ctx, set, "Pod", pod.Name, "delete", getPodRevision(pod), err)
+	if err != nil && !apierrors.IsNotFound(err) {
+		spc.deletionFailed(ctx, set, pod)
+	}
 	spc.recordPodEvent("delete", set, pod, err)
 	return err
 }
 
+// ForceDeleteStatefulPod deletes pod with a zero grace period. It is used for Pods that can no longer be terminated
+// gracefully because their Node is unreachable, and records a warning event explaining why the Pod was force deleted.
+func (spc *StatefulPodControl) ForceDeleteStatefulPod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
+	spc.recorder.Eventf(set, v1.EventTypeWarning, "ForceDeletingPod",
+		"Force deleting Pod %s in StatefulSet %s: Pod has been terminating on unreachable Node %s for longer than the unreachable node policy allows",
+		pod.Name, set.Name, pod.Spec.NodeName)
+	spc.expectDeletion(ctx, set, pod)
+	err := spc.objectMgr.ForceDeletePod(ctx, pod)
+	spc.recordAudit(ctx, set, "Pod", pod.Name, "forceDelete", getPodRevision(pod), err)
+	if err != nil && !apierrors.IsNotFound(err) {
+		spc.deletionFailed(ctx, set, pod)
+	}
+	spc.recordPodEvent("delete", set, pod, err)
+	return err
+}
+
+// UnreachablePodForceDeleteTime returns the time at which pod may be force deleted because it is terminating on a
+// Node tainted as unreachable. The second return value is false if pod is not eligible for force deletion under set's
+// UnreachableNodePolicy.
+func (spc *StatefulPodControl) UnreachablePodForceDeleteTime(set *xstsappv1.XStatefulSet, pod *v1.Pod) (time.Time, bool, error) {
+	if _, ok := getUnreachableNodeForceDeleteTimeout(set); !ok || !isTerminating(pod) || pod.Spec.NodeName == "" {
+		return time.Time{}, false, nil
+	}
+	node, err := spc.objectMgr.GetNode(pod.Spec.NodeName)
+	switch {
+	case apierrors.IsNotFound(err):
+		// Pods bound to Nodes that no longer exist are removed by the pod garbage collector.
+		return time.Time{}, false, nil
+	case err != nil:
+		return time.Time{}, false, err
+	}
+	forceDeleteTime, ok := getUnreachablePodForceDeleteTime(set, pod, node)
+	return forceDeleteTime, ok, nil
+}
+
+// ConfigHash returns the hash of the content of the ConfigMaps and Secrets referenced by set's template. ConfigMaps
+// and Secrets that do not exist contribute to the hash as missing, so that their creation triggers a rollout.
+func (spc *StatefulPodControl) ConfigHash(set *xstsappv1.XStatefulSet) (string, error) {
+	configMaps, secrets := getReferencedConfig(&set.Spec.Template)
+	content := make(map[string]interface{}, configMaps.Len()+secrets.Len())
+	for _, name := range sets.List(configMaps) {
+		configMap, err := spc.objectMgr.GetConfigMap(set.Namespace, name)
+		switch {
+		case apierrors.IsNotFound(err):
+			content["configmap/"+name] = nil
+		case err != nil:
+			return "", err
+		default:
+			content["configmap/"+name] = []interface{}{configMap.Data, configMap.BinaryData}
+		}
+	}
+	for _, name := range sets.List(secrets) {
+		secret, err := spc.objectMgr.GetSecret(set.Namespace, name)
+		switch {
+		case apierrors.IsNotFound(err):
+			content["secret/"+name] = nil
+		case err != nil:
+			return "", err
+		default:
+			content["secret/"+name] = secret.Data
+		}
+	}
+	hasher := fnv.New32a()
+	hashutil.DeepHashObject(hasher, content)
+	return rand.SafeEncodeString(fmt.Sprint(hasher.Sum32())), nil
+}
+
 // ClaimsMatchRetentionPolicy returns false if the PVCs for pod are not consistent with set's PVC deletion policy.
 // An error is returned if something is not consistent. This is expected if the pod is being otherwise updated,
 // but a problem otherwise (see usage of this method in UpdateStatefulPod).
@@ -262,7 +417,12 @@ func (spc *StatefulPodControl) UpdatePodClaimForRetentionPolicy(ctx context.Cont
 			if !isClaimOwnerUpToDate(logger, claim, set, pod) {
 				claim = claim.DeepCopy() // Make a copy so we don't mutate the shared cache.
 				updateClaimOwnerRefForSetAndPod(logger, claim, set, pod)
-				if err := spc.objectMgr.UpdateClaim(claim); err != nil {
+				err := spc.objectMgr.UpdateClaim(ctx, claim)
+				spc.recordAudit(ctx, set, "PersistentVolumeClaim", claim.Name, "update", getPodRevision(pod), err)
+				if !spc.dryRun {
+					metrics.ClaimOperations.WithLabelValues("update", operationResult(err)).Inc()
+				}
+				if err != nil {
 					return fmt.Errorf("could not update claim %s for delete policy ownerRefs: %w", claimName, err)
 				}
 			}
@@ -297,9 +457,69 @@ func (spc *StatefulPodControl) PodClaimIsStale(set *xstsappv1.XStatefulSet, pod
 	return false, nil
 }
 
+// expectCreation records that the controller of set has to observe the creation of a Pod before it syncs set again.
+func (spc *StatefulPodControl) expectCreation(ctx context.Context, set *xstsappv1.XStatefulSet) {
+	if spc.expectations == nil {
+		return
+	}
+	if key, err := controller.KeyFunc(set); err == nil {
+		spc.expectations.RaiseExpectations(klog.FromContext(ctx), key, 1, 0)
+	}
+}
+
+// creationFailed lowers the creations expected for set after the creation of a Pod failed, as it will not be observed.
+func (spc *StatefulPodControl) creationFailed(ctx context.Context, set *xstsappv1.XStatefulSet) {
+	if spc.expectations == nil {
+		return
+	}
+	if key, err := controller.KeyFunc(set); err == nil {
+		spc.expectations.CreationObserved(klog.FromContext(ctx), key)
+	}
+}
+
+// expectDeletion records that the controller of set has to observe the deletion of pod before it syncs set again.
+func (spc *StatefulPodControl) expectDeletion(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) {
+	if spc.expectations == nil {
+		return
+	}
+	logger := klog.FromContext(ctx)
+	if key, err := controller.KeyFunc(set); err == nil {
+		if err := spc.expectations.RaiseDeletions(logger, key, []string{string(pod.UID)}); err != nil {
+			utilruntime.HandleErrorWithContext(ctx, err, "Couldn't record expected Pod deletion", "statefulSet", klog.KObj(set), "pod", klog.KObj(pod))
+		}
+	}
+}
+
+// deletionFailed lowers the deletions expected for set after the deletion of pod failed, as it will not be observed.
+// It is not called if pod was not found, the pod cache still has to observe the deletion in that case.
+func (spc *StatefulPodControl) deletionFailed(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) {
+	if spc.expectations == nil {
+		return
+	}
+	if key, err := controller.KeyFunc(set); err == nil {
+		spc.expectations.DeletionObserved(klog.FromContext(ctx), key, string(pod.UID))
+	}
+}
+
+// recordAudit records the write verb of the Pod or PersistentVolumeClaim kind name of set to the audit log, unless the
+// write is only planned.
+func (spc *StatefulPodControl) recordAudit(ctx context.Context, set *xstsappv1.XStatefulSet, kind, name, verb, revision string, err error) {
+	if spc.dryRun {
+		return
+	}
+	spc.audit.record(ctx, set, kind, name, verb, revision, err)
+}
+
 // recordPodEvent records an event for verb applied to a Pod in a StatefulSet. If err is nil the generated event will
 // have a reason of v1.EventTypeNormal. If err is not nil the generated event will have a reason of v1.EventTypeWarning.
+// No event is recorded if err only reports throttled operations, which were not attempted.
 func (spc *StatefulPodControl) recordPodEvent(verb string, set *xstsappv1.XStatefulSet, pod *v1.Pod, err error) {
+	if _, throttled := throttledAfter(err); throttled {
+		return
+	}
+	if !spc.dryRun {
+		metrics.PodOperations.WithLabelValues(verb, operationResult(err)).Inc()
+	}
 	if err == nil {
 		reason := fmt.Sprintf("Successful%s", cases.Title(language.English).String(verb))
 		message := fmt.Sprintf("%s Pod %s in StatefulSet %s successful",
@@ -317,6 +537,9 @@ func (spc *StatefulPodControl) recordPodEvent(verb string, set *xstsappv1.XState
 // nil the generated event will have a reason of v1.EventTypeNormal. If err is not nil the generated event will have a
 // reason of v1.EventTypeWarning.
 func (spc *StatefulPodControl) recordClaimEvent(verb string, set *xstsappv1.XStatefulSet, pod *v1.Pod, claim *v1.PersistentVolumeClaim, err error) {
+	if !spc.dryRun {
+		metrics.ClaimOperations.WithLabelValues(verb, operationResult(err)).Inc()
+	}
 	if err == nil {
 		reason := fmt.Sprintf("Successful%s", cases.Title(language.English).String(verb))
 		message := fmt.Sprintf("%s Claim %s Pod %s in StatefulSet %s success",
@@ -332,7 +555,7 @@ func (spc *StatefulPodControl) recordClaimEvent(verb string, set *xstsappv1.XSta
 
 // createMissingPersistentVolumeClaims creates all of the required PersistentVolumeClaims for pod, and updates its retention policy
 func (spc *StatefulPodControl) createMissingPersistentVolumeClaims(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
-	if err := spc.createPersistentVolumeClaims(set, pod); err != nil {
+	if err := spc.createPersistentVolumeClaims(ctx, set, pod); err != nil {
 		return err
 	}
 	// Set PVC policy as much as is possible at this point.
@@ -347,13 +570,18 @@ func (spc *StatefulPodControl) createMissingPersistentVolumeClaims(ctx context.C
 // set. If all of the claims for Pod are successfully created, the returned error is nil. If creation fails, this method
 // may be called again until no error is returned, indicating the PersistentVolumeClaims for pod are consistent with
 // set's Spec.
-func (spc *StatefulPodControl) createPersistentVolumeClaims(set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
+func (spc *StatefulPodControl) createPersistentVolumeClaims(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
 	var errs []error
 	for _, claim := range getPersistentVolumeClaims(set, pod) {
 		pvc, err := spc.objectMgr.GetClaim(claim.Namespace, claim.Name)
 		switch {
 		case apierrors.IsNotFound(err):
-			err := spc.objectMgr.CreateClaim(&claim)
+			if err := spc.limiter.reserve(set, claimCreation); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			err := spc.objectMgr.CreateClaim(ctx, &claim)
+			spc.recordAudit(ctx, set, "PersistentVolumeClaim", claim.Name, "create", getPodRevision(pod), err)
 			if err != nil {
 				errs = append(errs, fmt.Errorf("failed to create PVC %s: %s", claim.Name, err))
 			}
@@ -0,0 +1,217 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodExpectations(t *testing.T) {
+	ctx := context.Background()
+	logger := klog.FromContext(ctx)
+	set := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	existing := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0", UID: "web-0-uid"}}
+	client := fake.NewClientset(existing)
+	expectations := controller.NewUIDTrackingControllerExpectations(controller.NewControllerExpectations())
+	spc := &StatefulPodControl{
+		objectMgr:    &realStatefulPodControlObjectManager{client: client},
+		recorder:     record.NewFakeRecorder(10),
+		expectations: expectations,
+	}
+	ssc := &StatefulSetController{expectations: expectations}
+	const key = "default/web"
+	if err := expectations.ExpectDeletions(logger, key, nil); err != nil {
+		t.Fatalf("ExpectDeletions() error = %v", err)
+	}
+
+	// a failed creation is not waited for
+	if err := spc.CreateStatefulPod(ctx, set, existing.DeepCopy()); err == nil {
+		t.Fatalf("expected creating an existing pod to fail")
+	}
+	if !expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected a failed creation not to be expected")
+	}
+
+	if err := spc.DeleteStatefulPod(ctx, set, existing); err != nil {
+		t.Fatalf("DeleteStatefulPod() error = %v", err)
+	}
+	// deleting the pod again, as a sync on a stale cache would, neither adds nor drops the deletion
+	if err := spc.DeleteStatefulPod(ctx, set, existing); err == nil {
+		t.Fatalf("expected deleting a deleted pod to fail")
+	}
+	if expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected the deletion to be waited for")
+	}
+
+	// the pod is observed terminating first, then deleted
+	terminating := existing.DeepCopy()
+	terminating.DeletionTimestamp = ptr.To(metav1.Now())
+	ssc.observeDeletion(logger, set, terminating)
+	if !expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected the terminating pod to satisfy the deletion")
+	}
+	ssc.observeDeletion(logger, set, existing)
+	if _, del := mustGetExpectations(t, expectations, key); del != 0 {
+		t.Errorf("expected the deletion to be observed once, got %d pending deletions", del)
+	}
+
+	spc.expectCreation(ctx, set)
+	if expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected the creation to be waited for")
+	}
+	ssc.observeCreation(logger, set)
+	if !expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected the observed creation to satisfy the expectations")
+	}
+}
+
+func mustGetExpectations(t *testing.T, expectations *controller.UIDTrackingControllerExpectations, key string) (int64, int64) {
+	t.Helper()
+	exp, exists, err := expectations.GetExpectations(key)
+	if err != nil || !exists {
+		t.Fatalf("expected expectations for %s, exists = %v, err = %v", key, exists, err)
+	}
+	return exp.GetExpectations()
+}
+
+func TestPodOperationLimiter(t *testing.T) {
+	if limiter := newPodOperationLimiter(PodOperationLimits{Burst: 1}, clock.RealClock{}); limiter != nil {
+		t.Fatalf("expected no limiter without limits")
+	}
+
+	limiter := newPodOperationLimiter(PodOperationLimits{ClaimCreationsPerMinute: 60, Burst: 2}, clock.RealClock{})
+	web := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	db := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "db"}}
+	for i := 0; i < 2; i++ {
+		if err := limiter.reserve(web, claimCreation); err != nil {
+			t.Fatalf("expected claim creation %d within the burst to be allowed, got %v", i, err)
+		}
+	}
+	err := limiter.reserve(web, claimCreation)
+	after, throttled := throttledAfter(err)
+	if !throttled || after <= 0 || after > time.Second {
+		t.Fatalf("expected claim creation to be throttled for at most a second, got %v", err)
+	}
+	if err := limiter.reserve(web, podCreation); err != nil {
+		t.Errorf("expected unlimited pod creations, got %v", err)
+	}
+	if err := limiter.reserve(db, claimCreation); err != nil {
+		t.Errorf("expected the claim creations of another set to be limited separately, got %v", err)
+	}
+	limiter.forget("default/web")
+	if err := limiter.reserve(web, claimCreation); err != nil {
+		t.Errorf("expected a forgotten set to start with a full burst, got %v", err)
+	}
+
+	nested := utilerrors.NewAggregate([]error{
+		&throttledError{operation: podCreation, after: 2 * time.Second},
+		utilerrors.NewAggregate([]error{&throttledError{operation: claimCreation, after: time.Second}}),
+	})
+	if after, throttled := throttledAfter(nested); !throttled || after != time.Second {
+		t.Errorf("expected throttled errors to be retried after the shortest delay, got %v, %v", after, throttled)
+	}
+	mixed := utilerrors.NewAggregate([]error{nested, errors.New("conflict")})
+	if _, throttled := throttledAfter(mixed); throttled {
+		t.Errorf("expected errors other than throttling not to be treated as throttled")
+	}
+	if _, throttled := throttledAfter(fmt.Errorf("wrapped: %w", &throttledError{after: time.Second})); !throttled {
+		t.Errorf("expected a wrapped throttled error to be throttled")
+	}
+}
+
+// claimIndexingObjectManager adds the claims it creates to the indexer of its claim lister, as the informer would.
+type claimIndexingObjectManager struct {
+	*realStatefulPodControlObjectManager
+	claims cache.Indexer
+}
+
+func (om *claimIndexingObjectManager) CreateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error {
+	if err := om.realStatefulPodControlObjectManager.CreateClaim(ctx, claim); err != nil {
+		return err
+	}
+	return om.claims.Add(claim)
+}
+
+func TestCreateStatefulPodLimits(t *testing.T) {
+	ctx := context.Background()
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas: ptr.To[int32](2),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+	}
+	client := fake.NewClientset()
+	claims := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	fakeClock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	spc := &StatefulPodControl{
+		objectMgr: &claimIndexingObjectManager{
+			realStatefulPodControlObjectManager: &realStatefulPodControlObjectManager{
+				client:      client,
+				claimLister: corelisters.NewPersistentVolumeClaimLister(claims),
+			},
+			claims: claims,
+		},
+		recorder: record.NewFakeRecorder(10),
+		limiter: newPodOperationLimiter(PodOperationLimits{
+			PodCreationsPerMinute:   30,
+			ClaimCreationsPerMinute: 60,
+			Burst:                   1,
+		}, fakeClock),
+	}
+
+	// the claim of web-0 takes the only claim token
+	if err := spc.createMissingPersistentVolumeClaims(ctx, set, newStatefulSetPod(set, 0)); err != nil {
+		t.Fatalf("createMissingPersistentVolumeClaims() error = %v", err)
+	}
+	// web-1 waits for a claim token, its pod token is not spent meanwhile
+	web1 := newStatefulSetPod(set, 1)
+	err := spc.CreateStatefulPod(ctx, set, web1.DeepCopy())
+	if after, throttled := throttledAfter(err); !throttled || after != time.Second {
+		t.Fatalf("expected the claim creation of web-1 to be throttled for a second, got %v", err)
+	}
+	fakeClock.Step(time.Second)
+	if err := spc.CreateStatefulPod(ctx, set, web1.DeepCopy()); err != nil {
+		t.Fatalf("expected web-1 to be created once its claim may be, got %v", err)
+	}
+	if _, err := client.CoreV1().Pods("default").Get(ctx, web1.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("expected web-1 to be created, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"golang.org/x/time/rate"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/clock"
+)
+
+// PodOperationLimits limits the rate of the Pod and PersistentVolumeClaim operations of each StatefulSet, so that
+// large Parallel StatefulSets do not overwhelm the components that act on them, e.g. a CSI provisioner. A limit of 0
+// is unlimited.
+type PodOperationLimits struct {
+	PodCreationsPerMinute   int32
+	PodDeletionsPerMinute   int32
+	ClaimCreationsPerMinute int32
+	// Burst is the number of operations of each kind that may be issued at once.
+	Burst int32
+}
+
+// Kinds of operations limited by PodOperationLimits.
+const (
+	podCreation   = "pod creation"
+	podDeletion   = "pod deletion"
+	claimCreation = "claim creation"
+)
+
+// throttledError is returned by StatefulPodControl for an operation that exceeds the PodOperationLimits of its
+// StatefulSet, or by defaultStatefulSetControl for a Pod deletion that a DeletionGate delays. The operation is not
+// issued, and may be retried after the returned delay.
+type throttledError struct {
+	operation string
+	after     time.Duration
+}
+
+func (e *throttledError) Error() string {
+	return fmt.Sprintf("%s is rate limited for %v", e.operation, e.after)
+}
+
+// throttledAfter returns the delay after which the operations that failed with err may be retried, if all of them
+// were throttled.
+func throttledAfter(err error) (time.Duration, bool) {
+	if err == nil {
+		return 0, false
+	}
+	errs := []error{err}
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		errs = utilerrors.Flatten(agg).Errors()
+	}
+	after := time.Duration(0)
+	for i, err := range errs {
+		var throttled *throttledError
+		if !errors.As(err, &throttled) {
+			return 0, false
+		}
+		if i == 0 || throttled.after < after {
+			after = throttled.after
+		}
+	}
+	return after, len(errs) > 0
+}
+
+// podOperationLimiter enforces PodOperationLimits with a token bucket per StatefulSet and kind of operation. A nil
+// podOperationLimiter is unlimited. It is safe for concurrent use.
+type podOperationLimiter struct {
+	limits PodOperationLimits
+	clock  clock.PassiveClock
+
+	mu       sync.Mutex
+	limiters map[string]map[string]*rate.Limiter
+}
+
+// newPodOperationLimiter returns a podOperationLimiter enforcing limits as timed by clock, or nil if limits are
+// unlimited.
+func newPodOperationLimiter(limits PodOperationLimits, clock clock.PassiveClock) *podOperationLimiter {
+	if limits.PodCreationsPerMinute <= 0 && limits.PodDeletionsPerMinute <= 0 && limits.ClaimCreationsPerMinute <= 0 {
+		return nil
+	}
+	return &podOperationLimiter{limits: limits, clock: clock, limiters: map[string]map[string]*rate.Limiter{}}
+}
+
+// perMinute returns the limit of operation.
+func (l *podOperationLimiter) perMinute(operation string) int32 {
+	switch operation {
+	case podCreation:
+		return l.limits.PodCreationsPerMinute
+	case podDeletion:
+		return l.limits.PodDeletionsPerMinute
+	case claimCreation:
+		return l.limits.ClaimCreationsPerMinute
+	}
+	return 0
+}
+
+// reserve takes a token of operation for set. It returns a throttledError if there is none left.
+func (l *podOperationLimiter) reserve(set *xstsappv1.XStatefulSet, operation string) error {
+	if l == nil || l.perMinute(operation) <= 0 {
+		return nil
+	}
+	key, err := controller.KeyFunc(set)
+	if err != nil {
+		return nil
+	}
+	now := l.clock.Now()
+	reservation := l.limiter(key, operation).ReserveN(now, 1)
+	if after := reservation.DelayFrom(now); after > 0 {
+		reservation.CancelAt(now)
+		recordSyncBlocked(set, blockedRateLimited)
+		return &throttledError{operation: operation, after: after}
+	}
+	return nil
+}
+
+func (l *podOperationLimiter) limiter(key, operation string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limiters, ok := l.limiters[key]
+	if !ok {
+		limiters = map[string]*rate.Limiter{}
+		l.limiters[key] = limiters
+	}
+	limiter, ok := limiters[operation]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(float64(l.perMinute(operation))/60), int(max(l.limits.Burst, 1)))
+		limiters[operation] = limiter
+	}
+	return limiter
+}
+
+// forget drops the token buckets of the StatefulSet key, which has been deleted.
+func (l *podOperationLimiter) forget(key string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.limiters, key)
+}
@@ -37,6 +37,7 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
@@ -46,15 +47,20 @@ import (
 	"github.com/xsts-sh/xstatefulset/pkg/controller"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/sharding"
 	podutil "github.com/xsts-sh/xstatefulset/pkg/controller/utils"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
+	"github.com/xsts-sh/xstatefulset/pkg/feature"
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"k8s.io/apimachinery/pkg/util/wait"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	appsinformers "k8s.io/client-go/informers/apps/v1"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	clientset "k8s.io/client-go/kubernetes"
@@ -63,6 +69,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+	utilclock "k8s.io/utils/clock"
 
 	"k8s.io/klog/v2"
 )
@@ -83,6 +90,12 @@ type StatefulSetController struct {
 	control StatefulSetControlInterface
 	// podControl is used for patching pods.
 	podControl controller.PodControlInterface
+	// expectations records the pod creations and deletions of each xstatefulset that have not been observed yet. A
+	// xstatefulset is not synced until they are, so that a lagging pod cache does not repeat them.
+	expectations *controller.UIDTrackingControllerExpectations
+	// podOperationLimiter limits the rate of the pod and claim operations of each xstatefulset, it is nil if they
+	// are not limited.
+	podOperationLimiter *podOperationLimiter
 	// podIndexer allows looking up pods by ControllerRef UID
 	podIndexer cache.Indexer
 	// podLister is able to list/get pods from a shared informer's store
@@ -95,49 +108,166 @@ type StatefulSetController struct {
 	setListerSynced cache.InformerSynced
 	// pvcListerSynced returns true if the pvc shared informer has synced at least once
 	pvcListerSynced cache.InformerSynced
+	// nodeLister is able to list/get nodes from a shared informer's store. It is nil if Nodes are not watched.
+	nodeLister corelisters.NodeLister
+	// nodeListerSynced returns true if the node shared informer has synced at least once. It is nil if Nodes are not
+	// watched.
+	nodeListerSynced cache.InformerSynced
+	// configMapListerSynced returns true if the configMap shared informer has synced at least once. It is nil if
+	// ConfigMaps are not watched.
+	configMapListerSynced cache.InformerSynced
+	// secretListerSynced returns true if the secret shared informer has synced at least once. It is nil if Secrets
+	// are not watched.
+	secretListerSynced cache.InformerSynced
 	// revListerSynced returns true if the rev shared informer has synced at least once
 	revListerSynced cache.InformerSynced
+	// namespaceFilter decides which namespaces the controller watches, it is nil if all namespaces are watched.
+	namespaceFilter *controller.NamespaceFilter
+	// sharder decides which xstatefulsets this replica syncs, it is nil if the controller is not sharded.
+	sharder *sharding.Sharder
 	// StatefulSets that need to be synced.
 	queue workqueue.TypedRateLimitingInterface[string]
-	// eventBroadcaster is the core of event processing pipeline.
+	// eventBroadcaster is the core of event processing pipeline, it is nil if events are recorded with a recorder
+	// of the caller.
 	eventBroadcaster record.EventBroadcaster
+	// workersStarted is set once the caches have synced and the workers have been started.
+	workersStarted atomic.Bool
+	// runningWorkers is the number of workers that are processing the queue.
+	runningWorkers atomic.Int32
+	// clock is the time availability, force deletions, scaling schedules and expectations are evaluated at.
+	clock utilclock.PassiveClock
+	// lastDequeueTime is the time, in unix nanoseconds, a worker last took a key from the queue.
+	lastDequeueTime atomic.Int64
+	// dryRun logs the writes that syncs would make instead of making them.
+	dryRun bool
+	// audit records the adoptions and releases of Pods. It is nil if they are not audited.
+	audit *AuditLog
 }
 
 // NewStatefulSetController creates a new xstatefulset controller.
+//
+// Deprecated: Use NewController with WithNamespaceFilter, WithSharder, WithQueueOptions and WithPodOperationLimits
+// instead.
 func NewStatefulSetController(
 	ctx context.Context,
 	podInformer coreinformers.PodInformer,
 	localSetInformer appsv1informers.XStatefulSetInformer,
 	pvcInformer coreinformers.PersistentVolumeClaimInformer,
 	revInformer appsinformers.ControllerRevisionInformer,
+	nodeInformer coreinformers.NodeInformer,
+	configMapInformer coreinformers.ConfigMapInformer,
+	secretInformer coreinformers.SecretInformer,
+	kubeClient clientset.Interface,
+	kthenaClientSet kthenaclientset.Interface,
+	namespaceFilter *controller.NamespaceFilter,
+	sharder *sharding.Sharder,
+	queueOptions controller.QueueOptions,
+	podOperationLimits PodOperationLimits,
+) *StatefulSetController {
+	return newStatefulSetController(ctx, podInformer, localSetInformer, pvcInformer, revInformer, nodeInformer,
+		configMapInformer, secretInformer, kubeClient, kthenaClientSet, newControllerOptions(
+			WithNamespaceFilter(namespaceFilter),
+			WithSharder(sharder),
+			WithQueueOptions(queueOptions),
+			WithPodOperationLimits(podOperationLimits),
+		))
+}
+
+// newStatefulSetController creates a xstatefulset controller from the components in o, and creates the components
+// that are not set from the informers and clients.
+func newStatefulSetController(
+	ctx context.Context,
+	podInformer coreinformers.PodInformer,
+	localSetInformer appsv1informers.XStatefulSetInformer,
+	pvcInformer coreinformers.PersistentVolumeClaimInformer,
+	revInformer appsinformers.ControllerRevisionInformer,
+	nodeInformer coreinformers.NodeInformer,
+	configMapInformer coreinformers.ConfigMapInformer,
+	secretInformer coreinformers.SecretInformer,
 	kubeClient clientset.Interface,
 	kthenaClientSet kthenaclientset.Interface,
+	o *controllerOptions,
 ) *StatefulSetController {
 	logger := klog.FromContext(ctx)
-	eventBroadcaster := record.NewBroadcaster(record.WithContext(ctx))
-	recorder := eventBroadcaster.NewRecorder(legacyscheme.Scheme, v1.EventSource{Component: "xstatefulset"})
+	var eventBroadcaster record.EventBroadcaster
+	recorder := o.recorder
+	if recorder == nil {
+		eventBroadcaster = record.NewBroadcaster(record.WithContext(ctx))
+		recorder = eventBroadcaster.NewRecorder(legacyscheme.Scheme, v1.EventSource{Component: "xstatefulset"})
+	}
 
 	// Register metrics
-	metrics.Register()
+	if o.metricsRegistry != nil {
+		metrics.RegisterTo(o.metricsRegistry)
+	} else {
+		metrics.Register()
+	}
+	clock := o.clock
+	if clock == nil {
+		clock = utilclock.RealClock{}
+	}
+	expectations := controller.NewUIDTrackingControllerExpectations(controller.NewControllerExpectationsWithClock(clock))
+	if o.auditLog != nil {
+		o.auditLog.clock = clock
+	}
+	// the ConfigMaps and Secrets of the cluster are only watched, and cached, if StatefulSets may roll out on their
+	// changes
+	watchConfig := utilfeature.DefaultFeatureGate.Enabled(feature.RolloutOnConfigChange)
+	// the Nodes of the cluster are only watched if Pods on unreachable Nodes may be force deleted
+	watchNodes := utilfeature.DefaultFeatureGate.Enabled(feature.UnreachableNodeForceDelete)
+	objectManager := o.objectManager
+	if objectManager == nil {
+		om := &realStatefulPodControlObjectManager{
+			client:      kubeClient,
+			podLister:   podInformer.Lister(),
+			claimLister: pvcInformer.Lister(),
+		}
+		if watchNodes {
+			om.nodeLister = nodeInformer.Lister()
+		}
+		if watchConfig {
+			om.configMapLister = configMapInformer.Lister()
+			om.secretLister = secretInformer.Lister()
+		}
+		objectManager = om
+	}
+	podControl := &StatefulPodControl{
+		objectMgr:    objectManager,
+		recorder:     recorder,
+		expectations: expectations,
+		limiter:      newPodOperationLimiter(o.podOperationLimits, clock),
+		audit:        o.auditLog,
+	}
+	statusUpdater := o.statusUpdater
+	if statusUpdater == nil {
+		statusUpdater = NewRealStatefulSetStatusUpdater(kthenaClientSet, localSetInformer.Lister())
+	}
+	controllerHistory := o.history
+	if controllerHistory == nil {
+		controllerHistory = history.NewHistory(kubeClient, revInformer.Lister())
+	}
+	namespaceFilter, sharder := o.namespaceFilter, o.sharder
+	queueOptions := o.queueOptions
+	// a clock that can also time the delays of the queue times the retries of failed syncs too
+	if withTicker, ok := clock.(utilclock.WithTicker); ok && queueOptions.Clock == nil {
+		queueOptions.Clock = withTicker
+	}
 	ssc := &StatefulSetController{
 		kubeClient:      kubeClient,
 		kthenaClientset: kthenaClientSet,
-		control: NewDefaultStatefulSetControl(
-			NewStatefulPodControl(
-				kubeClient,
-				podInformer.Lister(),
-				pvcInformer.Lister(),
-				recorder),
-			NewRealStatefulSetStatusUpdater(kthenaClientSet, localSetInformer.Lister()),
-			history.NewHistory(kubeClient, revInformer.Lister()),
-		),
+		control:         NewDefaultStatefulSetControl(podControl, statusUpdater, controllerHistory, o.extensions, clock),
 		pvcListerSynced: pvcInformer.Informer().HasSynced,
 		revListerSynced: revInformer.Informer().HasSynced,
-		queue: workqueue.NewTypedRateLimitingQueueWithConfig(
-			workqueue.DefaultTypedControllerRateLimiter[string](),
-			workqueue.TypedRateLimitingQueueConfig[string]{Name: "xstatefulset"},
-		),
-		podControl: controller.RealPodControl{KubeClient: kubeClient, Recorder: recorder},
+
+		queue:               controller.NewRateLimitingQueue("xstatefulset", queueOptions),
+		podControl:          controller.RealPodControl{KubeClient: kubeClient, Recorder: recorder},
+		expectations:        expectations,
+		podOperationLimiter: podControl.limiter,
+		namespaceFilter:     namespaceFilter,
+		sharder:             sharder,
+		clock:               clock,
+		dryRun:              o.dryRun,
+		audit:               o.auditLog,
 
 		eventBroadcaster: eventBroadcaster,
 	}
@@ -160,9 +290,25 @@ func NewStatefulSetController(
 	ssc.podListerSynced = podInformer.Informer().HasSynced
 	controller.AddPodControllerIndexer(podInformer.Informer())
 	ssc.podIndexer = podInformer.Informer().GetIndexer()
-	localSetInformer.Informer().AddEventHandler(
-		cache.ResourceEventHandlerFuncs{
+	// claims are deleted, bound, resized and released from their owners without any event on the pods of the set
+	pvcInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			ssc.enqueueStatefulSetsForClaim(logger, obj)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			ssc.updateClaim(logger, old, cur)
+		},
+		DeleteFunc: func(obj interface{}) {
+			ssc.enqueueStatefulSetsForClaim(logger, obj)
+		},
+	})
+	// sets in namespaces that are not watched are ignored, sets whose namespace stops being watched are handled as
+	// deleted
+	localSetInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: ssc.watches,
+		Handler: cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
+				updateGenerationLagMetric(obj.(*xstsappv1.XStatefulSet))
 				ssc.enqueueStatefulSet(logger, obj)
 			},
 			UpdateFunc: func(old, cur interface{}) {
@@ -171,15 +317,45 @@ func NewStatefulSetController(
 				if oldPS.Status.Replicas != curPS.Status.Replicas {
 					logger.V(4).Info("Observed updated replica count for StatefulSet", "statefulSet", klog.KObj(curPS), "oldReplicas", oldPS.Status.Replicas, "newReplicas", curPS.Status.Replicas)
 				}
+				updateGenerationLagMetric(curPS)
 				ssc.enqueueStatefulSet(logger, cur)
 			},
 			DeleteFunc: func(obj interface{}) {
-				ssc.enqueueStatefulSet(logger, obj)
+				ssc.deleteStatefulSet(logger, obj)
 			},
 		},
-	)
+	})
 	ssc.setLister = localSetInformer.Lister()
 	ssc.setListerSynced = localSetInformer.Informer().HasSynced
+	if watchNodes {
+		ssc.nodeLister = nodeInformer.Lister()
+		ssc.nodeListerSynced = nodeInformer.Informer().HasSynced
+	}
+	namespaceFilter.OnChange(func(namespace string, watched bool) {
+		ssc.namespaceWatchChanged(logger, namespace, watched)
+	})
+	sharder.OnChange(func(shard int, owned bool) {
+		ssc.shardChanged(logger, shard, owned)
+	})
+
+	// roll out StatefulSets that reference changed ConfigMaps and Secrets
+	if watchConfig {
+		configHandler := cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				ssc.enqueueStatefulSetsForConfig(logger, obj)
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				ssc.updateConfig(logger, old, cur)
+			},
+			DeleteFunc: func(obj interface{}) {
+				ssc.enqueueStatefulSetsForConfig(logger, obj)
+			},
+		}
+		configMapInformer.Informer().AddEventHandler(configHandler)
+		secretInformer.Informer().AddEventHandler(configHandler)
+		ssc.configMapListerSynced = configMapInformer.Informer().HasSynced
+		ssc.secretListerSynced = secretInformer.Informer().HasSynced
+	}
 
 	// TODO: Watch volumes
 	return ssc
@@ -190,9 +366,11 @@ func (ssc *StatefulSetController) Run(ctx context.Context, workers int) {
 	defer utilruntime.HandleCrashWithContext(ctx)
 
 	// Start events processing pipeline.
-	ssc.eventBroadcaster.StartStructuredLogging(3)
-	ssc.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: ssc.kubeClient.CoreV1().Events("")})
-	defer ssc.eventBroadcaster.Shutdown()
+	if ssc.eventBroadcaster != nil {
+		ssc.eventBroadcaster.StartStructuredLogging(3)
+		ssc.eventBroadcaster.StartRecordingToSink(&v1core.EventSinkImpl{Interface: ssc.kubeClient.CoreV1().Events("")})
+		defer ssc.eventBroadcaster.Shutdown()
+	}
 
 	logger := klog.FromContext(ctx)
 	logger.Info("Starting stateful set controller")
@@ -204,10 +382,12 @@ func (ssc *StatefulSetController) Run(ctx context.Context, workers int) {
 		wg.Wait()
 	}()
 
-	if !cache.WaitForNamedCacheSyncWithContext(ctx, ssc.podListerSynced, ssc.setListerSynced, ssc.pvcListerSynced, ssc.revListerSynced) {
+	if !cache.WaitForNamedCacheSyncWithContext(ctx, ssc.cacheSyncs()...) {
 		return
 	}
 
+	ssc.lastDequeueTime.Store(ssc.clock.Now().UnixNano())
+	ssc.workersStarted.Store(true)
 	for i := 0; i < workers; i++ {
 		wg.Go(func() {
 			wait.UntilWithContext(ctx, ssc.worker, time.Second)
@@ -234,6 +414,7 @@ func (ssc *StatefulSetController) addPod(logger klog.Logger, obj interface{}) {
 			return
 		}
 		logger.V(4).Info("Pod created with labels", "pod", klog.KObj(pod), "labels", pod.Labels)
+		ssc.observeCreation(logger, set)
 		ssc.enqueueStatefulSet(logger, set)
 		return
 	}
@@ -282,6 +463,10 @@ func (ssc *StatefulSetController) updatePod(logger klog.Logger, old, cur interfa
 		if oldPod.Status.Phase != curPod.Status.Phase {
 			logger.V(4).Info("StatefulSet Pod phase changed", "pod", klog.KObj(curPod), "statefulSet", klog.KObj(set), "podPhase", curPod.Status.Phase)
 		}
+		// a graceful deletion is observed once the pod is terminating, the pod cache no longer considers it running
+		if curPod.DeletionTimestamp != nil {
+			ssc.observeDeletion(logger, set, curPod)
+		}
 		ssc.enqueueStatefulSet(logger, set)
 		// TODO: MinReadySeconds in the Pod will generate an Available condition to be added in
 		// the Pod status which in turn will trigger a requeue of the owning replica set thus
@@ -339,15 +524,162 @@ func (ssc *StatefulSetController) deletePod(logger klog.Logger, obj interface{})
 		return
 	}
 	logger.V(4).Info("Pod deleted.", "pod", klog.KObj(pod), "caller", utilruntime.GetCaller())
+	ssc.observeDeletion(logger, set, pod)
 	ssc.enqueueStatefulSet(logger, set)
 }
 
+// observeCreation lowers the pod creations expected for set.
+func (ssc *StatefulSetController) observeCreation(logger klog.Logger, set *xstsappv1.XStatefulSet) {
+	key, err := controller.KeyFunc(set)
+	if err != nil {
+		utilruntime.HandleErrorWithLogger(logger, err, "Couldn't get key for StatefulSet object", "statefulSet", klog.KObj(set))
+		return
+	}
+	ssc.expectations.CreationObserved(logger, key)
+}
+
+// observeDeletion records the deletion of pod if it is expected for set. Deletions are tracked by pod UID, so a pod
+// that is observed terminating and then deleted is only counted once.
+func (ssc *StatefulSetController) observeDeletion(logger klog.Logger, set *xstsappv1.XStatefulSet, pod *v1.Pod) {
+	key, err := controller.KeyFunc(set)
+	if err != nil {
+		utilruntime.HandleErrorWithLogger(logger, err, "Couldn't get key for StatefulSet object", "statefulSet", klog.KObj(set))
+		return
+	}
+	ssc.expectations.DeletionObserved(logger, key, string(pod.UID))
+}
+
+// updateClaim enqueues the xstatefulsets of a PersistentVolumeClaim that changed.
+func (ssc *StatefulSetController) updateClaim(logger klog.Logger, old, cur interface{}) {
+	if old.(*v1.PersistentVolumeClaim).ResourceVersion == cur.(*v1.PersistentVolumeClaim).ResourceVersion {
+		// periodic resyncs and re-lists deliver updates for unchanged claims
+		return
+	}
+	ssc.enqueueStatefulSetsForClaim(logger, old)
+	ssc.enqueueStatefulSetsForClaim(logger, cur)
+}
+
+// enqueueStatefulSetsForClaim enqueues the xstatefulsets of a PersistentVolumeClaim, or of its deletion tombstone.
+func (ssc *StatefulSetController) enqueueStatefulSetsForClaim(logger klog.Logger, obj interface{}) {
+	claim, ok := obj.(*v1.PersistentVolumeClaim)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleErrorWithLogger(logger, nil, "Couldn't get object from tombstone", "obj", obj)
+			return
+		}
+		claim, ok = tombstone.Obj.(*v1.PersistentVolumeClaim)
+		if !ok {
+			utilruntime.HandleErrorWithLogger(logger, nil, "Tombstone contained object that is not a PersistentVolumeClaim", "type", fmt.Sprintf("%T", obj))
+			return
+		}
+	}
+	for _, set := range ssc.getStatefulSetsForClaim(logger, claim) {
+		logger.V(4).Info("PersistentVolumeClaim of StatefulSet changed", "persistentVolumeClaim", klog.KObj(claim), "statefulSet", klog.KObj(set))
+		ssc.enqueueStatefulSet(logger, set)
+	}
+}
+
+// getStatefulSetsForClaim returns the xstatefulsets that claim belongs to: those it has an owner reference to, the
+// owners of the pods it has an owner reference to, and those of which it is named like a claim of a pod. Owner
+// references are matched by name only, so that the set of a stale reference is synced and cleans it up.
+func (ssc *StatefulSetController) getStatefulSetsForClaim(logger klog.Logger, claim *v1.PersistentVolumeClaim) []*xstsappv1.XStatefulSet {
+	var claimSets []*xstsappv1.XStatefulSet
+	seen := sets.New[string]()
+	add := func(set *xstsappv1.XStatefulSet) {
+		if !seen.Has(set.Name) {
+			seen.Insert(set.Name)
+			claimSets = append(claimSets, set)
+		}
+	}
+	for _, ownerRef := range claim.OwnerReferences {
+		switch ownerRef.Kind {
+		case controllerKind.Kind:
+			if set, err := ssc.setLister.XStatefulSets(claim.Namespace).Get(ownerRef.Name); err == nil {
+				add(set)
+			}
+		case podKind.Kind:
+			pod, err := ssc.podLister.Pods(claim.Namespace).Get(ownerRef.Name)
+			if err != nil {
+				continue
+			}
+			if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil {
+				if set := ssc.resolveControllerRef(pod.Namespace, controllerRef); set != nil {
+					add(set)
+				}
+			}
+		}
+	}
+	namespaceSets, err := ssc.setLister.XStatefulSets(claim.Namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleErrorWithLogger(logger, err, "Couldn't list StatefulSets", "namespace", claim.Namespace)
+		return claimSets
+	}
+	for _, set := range namespaceSets {
+		if isClaimOf(set, claim) {
+			add(set)
+		}
+	}
+	return claimSets
+}
+
+// updateConfig enqueues the StatefulSets referencing a ConfigMap or Secret whose content changed.
+func (ssc *StatefulSetController) updateConfig(logger klog.Logger, old, cur interface{}) {
+	switch cur := cur.(type) {
+	case *v1.ConfigMap:
+		old := old.(*v1.ConfigMap)
+		if reflect.DeepEqual(old.Data, cur.Data) && reflect.DeepEqual(old.BinaryData, cur.BinaryData) {
+			return
+		}
+	case *v1.Secret:
+		old := old.(*v1.Secret)
+		if reflect.DeepEqual(old.Data, cur.Data) {
+			return
+		}
+	}
+	ssc.enqueueStatefulSetsForConfig(logger, cur)
+}
+
+// enqueueStatefulSetsForConfig enqueues the StatefulSets that roll out on config changes and whose template
+// references the ConfigMap or Secret obj.
+func (ssc *StatefulSetController) enqueueStatefulSetsForConfig(logger klog.Logger, obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	var isReferenced func(configMaps, secrets sets.Set[string]) bool
+	var namespace string
+	switch obj := obj.(type) {
+	case *v1.ConfigMap:
+		namespace = obj.Namespace
+		isReferenced = func(configMaps, _ sets.Set[string]) bool { return configMaps.Has(obj.Name) }
+	case *v1.Secret:
+		namespace = obj.Namespace
+		isReferenced = func(_, secrets sets.Set[string]) bool { return secrets.Has(obj.Name) }
+	default:
+		utilruntime.HandleErrorWithLogger(logger, nil, "Unexpected config object", "type", fmt.Sprintf("%T", obj))
+		return
+	}
+	setList, err := ssc.setLister.XStatefulSets(namespace).List(labels.Everything())
+	if err != nil {
+		return
+	}
+	for _, set := range setList {
+		if !rollsOutOnConfigChange(set) {
+			continue
+		}
+		if isReferenced(getReferencedConfig(&set.Spec.Template)) {
+			logger.V(4).Info("Referenced config of StatefulSet changed", "statefulSet", klog.KObj(set), "config", klog.KObj(obj.(metav1.Object)))
+			ssc.enqueueStatefulSet(logger, set)
+		}
+	}
+}
+
 // getPodsForStatefulSet returns the Pods that a given StatefulSet should manage.
-// It also reconciles ControllerRef by adopting/orphaning.
+// It also reconciles ControllerRef by adopting/orphaning with podControl.
 //
 // NOTE: Returned Pods are pointers to objects from the cache.
 // If you need to modify one, you need to copy it first.
-func (ssc *StatefulSetController) getPodsForStatefulSet(ctx context.Context, set *xstsappv1.XStatefulSet, selector labels.Selector) ([]*v1.Pod, error) {
+func (ssc *StatefulSetController) getPodsForStatefulSet(ctx context.Context, set *xstsappv1.XStatefulSet, selector labels.Selector, podControl controller.PodControlInterface) ([]*v1.Pod, error) {
 	podsForSts, err := controller.FilterPodsByOwner(ssc.podIndexer, &set.ObjectMeta, controllerKind.Kind, true)
 	if err != nil {
 		return nil, err
@@ -358,7 +690,7 @@ func (ssc *StatefulSetController) getPodsForStatefulSet(ctx context.Context, set
 		return isMemberOf(set, pod)
 	}
 
-	cm := controller.NewPodControllerRefManager(ssc.podControl, set, selector, controllerKind, ssc.canAdoptFunc(ctx, set))
+	cm := controller.NewPodControllerRefManager(podControl, set, selector, controllerKind, ssc.canAdoptFunc(ctx, set))
 	return cm.ClaimPods(ctx, podsForSts, filter)
 }
 
@@ -377,9 +709,9 @@ func (ssc *StatefulSetController) canAdoptFunc(ctx context.Context, set *xstsapp
 	})
 }
 
-// adoptOrphanRevisions adopts any orphaned ControllerRevisions matched by set's Selector.
-func (ssc *StatefulSetController) adoptOrphanRevisions(ctx context.Context, set *xstsappv1.XStatefulSet) error {
-	revisions, err := ssc.control.ListRevisions(set)
+// adoptOrphanRevisions adopts any orphaned ControllerRevisions matched by set's Selector with control.
+func (ssc *StatefulSetController) adoptOrphanRevisions(ctx context.Context, set *xstsappv1.XStatefulSet, control StatefulSetControlInterface) error {
+	revisions, err := control.ListRevisions(set)
 	if err != nil {
 		return err
 	}
@@ -394,7 +726,7 @@ func (ssc *StatefulSetController) adoptOrphanRevisions(ctx context.Context, set
 		if canAdoptErr != nil {
 			return fmt.Errorf("can't adopt ControllerRevisions: %v", canAdoptErr)
 		}
-		return ssc.control.AdoptOrphanRevisions(set, orphanRevisions)
+		return control.AdoptOrphanRevisions(ctx, set, orphanRevisions)
 	}
 	return nil
 }
@@ -450,6 +782,73 @@ func (ssc *StatefulSetController) enqueueStatefulSet(logger klog.Logger, obj int
 	ssc.queue.Add(key)
 }
 
+// deleteStatefulSet removes the metrics of a deleted xstatefulset and enqueues it.
+func (ssc *StatefulSetController) deleteStatefulSet(logger klog.Logger, obj interface{}) {
+	set, ok := obj.(*xstsappv1.XStatefulSet)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleErrorWithLogger(logger, nil, "Couldn't get object from tombstone", "obj", obj)
+			return
+		}
+		set, ok = tombstone.Obj.(*xstsappv1.XStatefulSet)
+		if !ok {
+			utilruntime.HandleErrorWithLogger(logger, nil, "Tombstone contained object that is not a StatefulSet", "type", fmt.Sprintf("%T", obj))
+			return
+		}
+	}
+	deleteStatefulSetMetrics(set)
+	ssc.enqueueStatefulSet(logger, set)
+}
+
+// watches returns true if obj, an xstatefulset or its tombstone, is in a namespace the controller watches.
+func (ssc *StatefulSetController) watches(obj interface{}) bool {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	set, ok := obj.(*xstsappv1.XStatefulSet)
+	return ok && ssc.namespaceFilter.Watches(set.Namespace)
+}
+
+// namespaceWatchChanged enqueues the xstatefulsets of a namespace the controller starts watching, and removes the
+// metrics of the xstatefulsets of a namespace it stops watching.
+func (ssc *StatefulSetController) namespaceWatchChanged(logger klog.Logger, namespace string, watched bool) {
+	sets, err := ssc.setLister.XStatefulSets(namespace).List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleErrorWithLogger(logger, err, "Couldn't list StatefulSets", "namespace", namespace)
+		return
+	}
+	logger.V(2).Info("Watched namespaces changed", "namespace", namespace, "watched", watched, "statefulSets", len(sets))
+	for _, set := range sets {
+		if watched {
+			ssc.enqueueStatefulSet(logger, set)
+		} else {
+			deleteStatefulSetMetrics(set)
+		}
+	}
+}
+
+// shardChanged enqueues the xstatefulsets of a shard this replica starts owning, and removes the metrics of the
+// xstatefulsets of a shard it stops owning, which are exported by the new owner.
+func (ssc *StatefulSetController) shardChanged(logger klog.Logger, shard int, owned bool) {
+	sets, err := ssc.setLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleErrorWithLogger(logger, err, "Couldn't list StatefulSets", "shard", shard)
+		return
+	}
+	for _, set := range sets {
+		key, err := controller.KeyFunc(set)
+		if err != nil || ssc.sharder.ShardFor(key) != shard || !ssc.namespaceFilter.Watches(set.Namespace) {
+			continue
+		}
+		if owned {
+			ssc.queue.Add(key)
+		} else {
+			deleteStatefulSetMetrics(set)
+		}
+	}
+}
+
 // enqueueStatefulSet enqueues the given xstatefulset in the work queue after given time
 func (ssc *StatefulSetController) enqueueSSAfter(logger klog.Logger, ss *xstsappv1.XStatefulSet, duration time.Duration) {
 	key, err := controller.KeyFunc(ss)
@@ -467,30 +866,59 @@ func (ssc *StatefulSetController) processNextWorkItem(ctx context.Context) bool
 	if quit {
 		return false
 	}
+	ssc.lastDequeueTime.Store(ssc.clock.Now().UnixNano())
 	defer ssc.queue.Done(key)
-	if err := ssc.sync(ctx, key); err != nil {
+	startTime := time.Now()
+	err := ssc.sync(ctx, key)
+	unobserved := err == errUnobservedPodWrites
+	if unobserved {
+		err = nil
+	}
+	metrics.SyncDuration.WithLabelValues(operationResult(err)).Observe(time.Since(startTime).Seconds())
+	switch {
+	case unobserved:
+		// the set is retried with the backoff of failed syncs, the observation of its Pods enqueues it sooner
+		ssc.queue.AddRateLimited(key)
+	case err != nil:
 		utilruntime.HandleErrorWithContext(ctx, err, "Error syncing StatefulSet, requeuing", "key", key)
 		ssc.queue.AddRateLimited(key)
-	} else {
+	default:
 		ssc.queue.Forget(key)
 	}
 	return true
 }
 
+// errUnobservedPodWrites is returned by sync if the Pod writes of the previous sync of the set are not observed yet.
+var errUnobservedPodWrites = fmt.Errorf("pod writes of the previous sync are not observed yet")
+
 // worker runs a worker goroutine that invokes processNextWorkItem until the controller's queue is closed
 func (ssc *StatefulSetController) worker(ctx context.Context) {
+	ssc.runningWorkers.Add(1)
+	defer ssc.runningWorkers.Add(-1)
 	for ssc.processNextWorkItem(ctx) {
 	}
 }
 
 // sync syncs the given xstatefulset.
-func (ssc *StatefulSetController) sync(ctx context.Context, key string) error {
+func (ssc *StatefulSetController) sync(ctx context.Context, key string) (err error) {
+	ctx, span := startSyncSpan(ctx, key)
+	defer func() { endSpan(span, err) }()
+	// the writes of the sync are correlated in the audit log by the ID of the sync
+	ctx = withSyncID(ctx, string(uuid.NewUUID()))
 	startTime := time.Now()
 	logger := klog.FromContext(ctx)
 	defer func() {
 		logger.V(4).Info("Finished syncing xstatefulset", "key", key, "time", time.Since(startTime))
 	}()
 
+	// the shard of the set is not released while it is synced
+	done, owned := ssc.sharder.Begin(key)
+	if !owned {
+		logger.V(4).Info("Ignoring StatefulSet of a shard owned by another replica", "key", key)
+		return nil
+	}
+	defer done()
+
 	namespace, name, err := cache.SplitMetaNamespaceKey(key)
 	if err != nil {
 		return err
@@ -498,12 +926,18 @@ func (ssc *StatefulSetController) sync(ctx context.Context, key string) error {
 	set, err := ssc.setLister.XStatefulSets(namespace).Get(name)
 	if errors.IsNotFound(err) {
 		logger.Info("StatefulSet has been deleted", "key", key)
+		ssc.expectations.DeleteExpectations(logger, key)
+		ssc.podOperationLimiter.forget(key)
 		return nil
 	}
 	if err != nil {
 		utilruntime.HandleErrorWithContext(ctx, err, "Unable to retrieve StatefulSet from store", "key", key)
 		return err
 	}
+	if !ssc.namespaceFilter.Watches(namespace) {
+		logger.V(4).Info("Ignoring StatefulSet in a namespace that is not watched", "key", key)
+		return nil
+	}
 
 	selector, err := metav1.LabelSelectorAsSelector(set.Spec.Selector)
 	if err != nil {
@@ -517,11 +951,34 @@ func (ssc *StatefulSetController) sync(ctx context.Context, key string) error {
 	// even if they weren't set when the object was created
 	legacyscheme.Scheme.Default(set)
 
-	if err := ssc.adoptOrphanRevisions(ctx, set); err != nil {
+	// the pod cache may not reflect the pods created and deleted by the previous sync yet, syncing now could repeat
+	// them. Only the status is updated, and the sync is retried when they are observed, or once the expectations
+	// expire.
+	if !ssc.expectations.SatisfiedExpectations(logger, key) {
+		logger.V(4).Info("Waiting for previous pod creations and deletions to be observed", "key", key)
+		if err := ssc.syncStatus(ctx, set); err != nil {
+			return err
+		}
+		return errUnobservedPodWrites
+	}
+	if err := ssc.expectations.ExpectDeletions(logger, key, nil); err != nil {
 		return err
 	}
 
-	pods, err := ssc.getPodsForStatefulSet(ctx, set, selector)
+	if ssc.dryRun {
+		plan, err := ssc.plan(ctx, set, selector)
+		if err != nil {
+			return err
+		}
+		logger.Info("Planned StatefulSet sync in dry-run mode", "statefulSet", klog.KObj(set), "actions", plan.String())
+		return nil
+	}
+
+	if err := ssc.adoptOrphanRevisions(ctx, set, ssc.control); err != nil {
+		return err
+	}
+
+	pods, err := ssc.getPodsForStatefulSet(ctx, set, selector, &auditingPodControl{PodControlInterface: ssc.podControl, audit: ssc.audit, set: set})
 	if err != nil {
 		return err
 	}
@@ -529,21 +986,91 @@ func (ssc *StatefulSetController) sync(ctx context.Context, key string) error {
 	return ssc.syncStatefulSet(ctx, set, pods)
 }
 
+// syncStatus updates the status of set from the Pods it controls in the cache, without writing Pods or
+// PersistentVolumeClaims. The Pods are neither adopted nor released.
+func (ssc *StatefulSetController) syncStatus(ctx context.Context, set *xstsappv1.XStatefulSet) error {
+	control, ok := ssc.control.(*defaultStatefulSetControl)
+	if !ok {
+		return nil
+	}
+	podsForSts, err := controller.FilterPodsByOwner(ssc.podIndexer, &set.ObjectMeta, controllerKind.Kind, false)
+	if err != nil {
+		return err
+	}
+	pods := make([]*v1.Pod, 0, len(podsForSts))
+	for _, pod := range podsForSts {
+		if isMemberOf(set, pod) {
+			pods = append(pods, pod)
+		}
+	}
+	_, err = control.statusSyncer().UpdateStatefulSet(ctx, set, pods)
+	return err
+}
+
 // syncStatefulSet syncs a tuple of (xstatefulset, []*v1.Pod).
 func (ssc *StatefulSetController) syncStatefulSet(ctx context.Context, set *xstsappv1.XStatefulSet, pods []*v1.Pod) error {
 	logger := klog.FromContext(ctx)
 	logger.V(4).Info("Syncing StatefulSet with pods", "statefulSet", klog.KObj(set), "pods", len(pods))
 	var status *xstsappv1.XStatefulSetStatus
 	var err error
+	// the pods are evaluated for availability no earlier than now
+	now := ssc.clock.Now()
 	status, err = ssc.control.UpdateStatefulSet(ctx, set, pods)
+	if after, throttled := throttledAfter(err); throttled {
+		// the operations are retried once the limits or deletion gates allow them, not with the backoff of failed syncs
+		logger.V(4).Info("StatefulSet will be enqueued for delayed pod operations", "statefulSet", klog.KObj(set), "after", after)
+		ssc.enqueueSSAfter(logger, set, after)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 	logger.V(4).Info("Successfully synced StatefulSet", "statefulSet", klog.KObj(set))
-	// One more sync to handle the clock skew. This is also helping in requeuing right after status update
+	// Pods becoming available produce no events, so requeue for the earliest one to recompute the status.
 	if set.Spec.MinReadySeconds > 0 && status != nil && status.AvailableReplicas != *set.Spec.Replicas {
-		ssc.enqueueSSAfter(logger, set, time.Duration(set.Spec.MinReadySeconds)*time.Second)
+		if after := controller.FindMinNextPodAvailabilityCheck(pods, set.Spec.MinReadySeconds, now, ssc.clock); after != nil {
+			// Add a second to avoid milliseconds skew in AddAfter.
+			ssc.enqueueSSAfter(logger, set, *after+time.Second)
+		}
+	}
+	// Pods stuck terminating on unreachable Nodes produce no further events, so requeue for their force deletion.
+	if after, ok := ssc.nextUnreachablePodForceDelete(set, pods); ok {
+		logger.V(4).Info("StatefulSet will be enqueued to force delete Pods on unreachable Nodes", "statefulSet", klog.KObj(set), "after", after)
+		ssc.enqueueSSAfter(logger, set, after)
+	}
+	// Scaling schedules fire without any event, so requeue for the next one.
+	if status != nil && status.ScalingSchedule != nil && status.ScalingSchedule.NextScheduleTime != nil {
+		after := status.ScalingSchedule.NextScheduleTime.Sub(ssc.clock.Now())
+		logger.V(4).Info("StatefulSet will be enqueued for its next scaling schedule", "statefulSet", klog.KObj(set),
+			"schedule", status.ScalingSchedule.NextSchedule, "after", after)
+		ssc.enqueueSSAfter(logger, set, after)
 	}
 
 	return nil
 }
+
+// nextUnreachablePodForceDelete returns the duration until the earliest Pod in pods becomes eligible for force
+// deletion under set's UnreachableNodePolicy. The second return value is false if no Pod is waiting for force deletion.
+func (ssc *StatefulSetController) nextUnreachablePodForceDelete(set *xstsappv1.XStatefulSet, pods []*v1.Pod) (time.Duration, bool) {
+	if _, ok := getUnreachableNodeForceDeleteTimeout(set); !ok {
+		return 0, false
+	}
+	var next time.Time
+	for _, pod := range pods {
+		if !isTerminating(pod) || pod.Spec.NodeName == "" {
+			continue
+		}
+		node, err := ssc.nodeLister.Get(pod.Spec.NodeName)
+		if err != nil {
+			continue
+		}
+		if forceDeleteTime, ok := getUnreachablePodForceDeleteTime(set, pod, node); ok && (next.IsZero() || forceDeleteTime.Before(next)) {
+			next = forceDeleteTime
+		}
+	}
+	if next.IsZero() {
+		return 0, false
+	}
+	// Add a second to avoid milliseconds skew in AddAfter.
+	return max(next.Sub(ssc.clock.Now()), 0) + time.Second, true
+}
@@ -0,0 +1,162 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
+)
+
+// The reasons the controller makes the writes it records to the audit log.
+const (
+	auditReasonMissingReplica  = "MissingReplica"
+	auditReasonFailedReplica   = "FailedReplica"
+	auditReasonInconsistentPod = "InconsistentPod"
+	auditReasonScaleDown       = "ScaleDown"
+	auditReasonRollingUpdate   = "RollingUpdate"
+	auditReasonUnreachableNode = "UnreachableNode"
+	auditReasonRetentionPolicy = "RetentionPolicy"
+	auditReasonNewRevision     = "NewRevision"
+	auditReasonRollback        = "Rollback"
+	auditReasonHistoryLimit    = "HistoryLimit"
+	auditReasonOrphanRevision  = "OrphanRevision"
+	auditReasonOrphanPod       = "OrphanPod"
+	auditReasonForeignPod      = "ForeignPod"
+	auditReasonStatusChanged   = "StatusChanged"
+)
+
+// AuditRecord is a write that the controller made to the API server while syncing a StatefulSet.
+type AuditRecord struct {
+	Time time.Time `json:"time"`
+	// SyncID identifies the sync the write was made in, it is shared by all writes of a sync.
+	SyncID string `json:"syncID,omitempty"`
+	// StatefulSet is the namespace/name of the StatefulSet that was synced.
+	StatefulSet string `json:"statefulSet"`
+	// Kind, Namespace and Name identify the object that was written.
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Verb      string `json:"verb"`
+	// Reason is why the controller made the write, e.g. ScaleDown for the deletion of a condemned Pod.
+	Reason string `json:"reason,omitempty"`
+	// Revision is the ControllerRevision of the written Pod, the written ControllerRevision, or the update revision
+	// of the written status.
+	Revision string `json:"revision,omitempty"`
+	// Result is success or error, Error is the error of the write if it failed.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// AuditLog writes a JSON line for each Pod, PersistentVolumeClaim, ControllerRevision and status write of the
+// controller. Unlike events, the records do not expire and are not aggregated. Writes that are throttled or planned
+// in dry-run mode are not recorded, as they are not made.
+type AuditLog struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	clock   clock.PassiveClock
+}
+
+// NewAuditLog creates an AuditLog that writes its records to w. The records are timed by the clock of the controller
+// the AuditLog is passed to with WithAuditLog.
+func NewAuditLog(w io.Writer) *AuditLog {
+	return &AuditLog{encoder: json.NewEncoder(w), clock: clock.RealClock{}}
+}
+
+// record records the write verb of the object kind name of set. The reason and sync ID are taken from ctx. A nil
+// AuditLog records nothing.
+func (l *AuditLog) record(ctx context.Context, set *xstsappv1.XStatefulSet, kind, name, verb, revision string, err error) {
+	if l == nil {
+		return
+	}
+	record := AuditRecord{
+		Time:        l.clock.Now().UTC(),
+		SyncID:      syncIDFrom(ctx),
+		StatefulSet: set.Namespace + "/" + set.Name,
+		Kind:        kind,
+		Namespace:   set.Namespace,
+		Name:        name,
+		Verb:        verb,
+		Reason:      auditReasonFrom(ctx),
+		Revision:    revision,
+		Result:      operationResult(err),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.encoder.Encode(&record); err != nil {
+		klog.FromContext(ctx).Error(err, "Failed to write audit record", "statefulSet", klog.KObj(set), "kind", kind, "name", name, "verb", verb)
+	}
+}
+
+// auditingPodControl records the adoptions and releases of the Pods of set that the embedded PodControlInterface
+// patches to audit.
+type auditingPodControl struct {
+	controller.PodControlInterface
+	audit *AuditLog
+	set   *xstsappv1.XStatefulSet
+}
+
+func (pc *auditingPodControl) PatchPod(ctx context.Context, namespace, name string, data []byte) error {
+	err := pc.PodControlInterface.PatchPod(ctx, namespace, name, data)
+	if isReleasePatch(data) {
+		pc.audit.record(withAuditReason(ctx, auditReasonForeignPod), pc.set, "Pod", name, "release", "", err)
+	} else {
+		pc.audit.record(withAuditReason(ctx, auditReasonOrphanPod), pc.set, "Pod", name, "adopt", "", err)
+	}
+	return err
+}
+
+// isReleasePatch returns true if data, an owner reference patch of a PodControllerRefManager, deletes the owner
+// reference of a Pod rather than adding it.
+func isReleasePatch(data []byte) bool {
+	return bytes.Contains(data, []byte(`"$patch":"delete"`))
+}
+
+type syncIDKey struct{}
+
+type auditReasonKey struct{}
+
+// withSyncID returns a copy of ctx that identifies the writes made with it as part of the sync id.
+func withSyncID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, syncIDKey{}, id)
+}
+
+func syncIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(syncIDKey{}).(string)
+	return id
+}
+
+// withAuditReason returns a copy of ctx that records reason as the reason of the writes made with it.
+func withAuditReason(ctx context.Context, reason string) context.Context {
+	return context.WithValue(ctx, auditReasonKey{}, reason)
+}
+
+func auditReasonFrom(ctx context.Context) string {
+	reason, _ := ctx.Value(auditReasonKey{}).(string)
+	return reason
+}
@@ -0,0 +1,226 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	componentmetrics "k8s.io/component-base/metrics"
+	testingclock "k8s.io/utils/clock/testing"
+	"k8s.io/utils/ptr"
+)
+
+func TestAuditLog(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	audit := NewAuditLog(&buf)
+	audit.clock = testingclock.NewFakePassiveClock(now)
+	set := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	existing := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-1", UID: "web-1-uid",
+		Labels: map[string]string{xstsappv1.StatefulSetRevisionLabel: "web-1234"}}}
+	spc := &StatefulPodControl{
+		objectMgr: &realStatefulPodControlObjectManager{client: fake.NewClientset(existing)},
+		recorder:  record.NewFakeRecorder(10),
+		audit:     audit,
+	}
+	ctx := withSyncID(context.Background(), "sync-1")
+
+	created := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0",
+		Labels: map[string]string{xstsappv1.StatefulSetRevisionLabel: "web-5678"}}}
+	if err := spc.CreateStatefulPod(withAuditReason(ctx, auditReasonMissingReplica), set, created); err != nil {
+		t.Fatalf("CreateStatefulPod() error = %v", err)
+	}
+	if err := spc.DeleteStatefulPod(withAuditReason(ctx, auditReasonScaleDown), set, existing); err != nil {
+		t.Fatalf("DeleteStatefulPod() error = %v", err)
+	}
+	if err := spc.DeleteStatefulPod(withAuditReason(ctx, auditReasonScaleDown), set, existing); err == nil {
+		t.Fatalf("expected deleting a deleted pod to fail")
+	}
+
+	want := []AuditRecord{
+		{Name: "web-0", Verb: "create", Reason: auditReasonMissingReplica, Revision: "web-5678", Result: "success"},
+		{Name: "web-1", Verb: "delete", Reason: auditReasonScaleDown, Revision: "web-1234", Result: "success"},
+		{Name: "web-1", Verb: "delete", Reason: auditReasonScaleDown, Revision: "web-1234", Result: "error",
+			Error: `pods "web-1" not found`},
+	}
+	decoder := json.NewDecoder(&buf)
+	for i := range want {
+		want[i].Time, want[i].SyncID, want[i].StatefulSet = now, "sync-1", "default/web"
+		want[i].Kind, want[i].Namespace = "Pod", "default"
+		var got AuditRecord
+		if err := decoder.Decode(&got); err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if got != want[i] {
+			t.Errorf("expected record %d to be %+v, got %+v", i, want[i], got)
+		}
+	}
+	if decoder.More() {
+		t.Errorf("expected %d records", len(want))
+	}
+}
+
+// decodeAuditRecords returns the records written to buf.
+func decodeAuditRecords(t *testing.T, buf *bytes.Buffer) []AuditRecord {
+	t.Helper()
+	var records []AuditRecord
+	decoder := json.NewDecoder(buf)
+	for decoder.More() {
+		var record AuditRecord
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditRevisions(t *testing.T) {
+	ctx := context.Background()
+	var buf bytes.Buffer
+	audit := NewAuditLog(&buf)
+	client := fake.NewClientset()
+	ssc := &defaultStatefulSetControl{
+		podControl: &StatefulPodControl{audit: audit},
+		// the lister never observes the created revisions, like a stale cache
+		controllerHistory: history.NewHistory(client, appslisters.NewControllerRevisionLister(
+			cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{}))),
+	}
+	set := newAuditedSet()
+
+	_, created, _, err := ssc.getStatefulSetRevisions(ctx, set, nil)
+	if err != nil {
+		t.Fatalf("getStatefulSetRevisions() error = %v", err)
+	}
+	// the equal revision that already exists is returned, it is not created again
+	if _, _, _, err := ssc.getStatefulSetRevisions(ctx, set, nil); err != nil {
+		t.Fatalf("getStatefulSetRevisions() error = %v", err)
+	}
+
+	client.PrependReactor("create", "controllerrevisions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	updated := set.DeepCopy()
+	updated.Spec.Template.Spec.Containers[0].Image = "web:v2"
+	if _, _, _, err := ssc.getStatefulSetRevisions(ctx, updated, nil); err == nil {
+		t.Fatalf("expected the creation of the revision to fail")
+	}
+	failed, err := newRevision(updated, 1, ptr.To[int32](0))
+	if err != nil {
+		t.Fatalf("newRevision() error = %v", err)
+	}
+	failedName := history.ControllerRevisionName(updated.Name, history.HashControllerRevision(failed, ptr.To[int32](0)))
+
+	records := decodeAuditRecords(t, &buf)
+	want := []AuditRecord{
+		{Name: created.Name, Revision: created.Name, Result: "success"},
+		{Name: failedName, Revision: failedName, Result: "error", Error: "unavailable"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("expected records %+v, got %+v", want, records)
+	}
+	for i := range want {
+		want[i].Time, want[i].StatefulSet = records[i].Time, "default/web"
+		want[i].Kind, want[i].Namespace, want[i].Verb, want[i].Reason = "ControllerRevision", "default", "create", auditReasonNewRevision
+		if records[i] != want[i] {
+			t.Errorf("expected record %d to be %+v, got %+v", i, want[i], records[i])
+		}
+	}
+}
+
+func TestAuditPodOwnership(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	set := newAuditedSet()
+	// web-0 is an orphan the sync adopts, other-0 is owned by set but not a member of it, the sync releases it
+	orphan := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0", Labels: set.Spec.Template.Labels},
+		Status: v1.PodStatus{Phase: v1.PodPending}}
+	foreign := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other-0", Labels: set.Spec.Template.Labels,
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(set, controllerKind)}}}
+	kubeClient := fake.NewClientset(orphan, foreign)
+	xstatefulsetClient := xstatefulsetfake.NewClientset(set)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	xstatefulsetInformers := xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	ssc := NewController(ctx, kubeClient, xstatefulsetClient, kubeInformers, xstatefulsetInformers,
+		WithEventRecorder(record.NewFakeRecorder(100)),
+		WithMetricsRegistry(componentmetrics.NewKubeRegistry()),
+		WithClock(testingclock.NewFakeClock(now)),
+		WithAuditLog(NewAuditLog(&buf)))
+	kubeInformers.Start(ctx.Done())
+	xstatefulsetInformers.Start(ctx.Done())
+	kubeInformers.WaitForCacheSync(ctx.Done())
+	xstatefulsetInformers.WaitForCacheSync(ctx.Done())
+
+	if err := ssc.sync(ctx, "default/web"); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	var got []AuditRecord
+	for _, record := range decodeAuditRecords(t, &buf) {
+		if !record.Time.Equal(now) {
+			t.Errorf("expected the record %+v to be timed by the clock of the controller", record)
+		}
+		if record.Kind == "Pod" && (record.Verb == "adopt" || record.Verb == "release") {
+			record.Time, record.SyncID = time.Time{}, ""
+			got = append(got, record)
+		}
+	}
+	want := []AuditRecord{
+		{StatefulSet: "default/web", Kind: "Pod", Namespace: "default", Name: "web-0", Verb: "adopt", Reason: auditReasonOrphanPod, Result: "success"},
+		{StatefulSet: "default/web", Kind: "Pod", Namespace: "default", Name: "other-0", Verb: "release", Reason: auditReasonForeignPod, Result: "success"},
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Verb < got[j].Verb })
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the adoption and release of pods to be audited as %+v, got %+v", want, got)
+	}
+}
+
+func newAuditedSet() *xstsappv1.XStatefulSet {
+	labels := map[string]string{"app": "web"}
+	return &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "web-uid"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+		},
+	}
+}
@@ -36,13 +36,16 @@ import (
 	"context"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
-	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
 	"github.com/xsts-sh/xstatefulset/pkg/feature"
+	"go.opentelemetry.io/otel/attribute"
 	"k8s.io/klog/v2"
+	"k8s.io/utils/clock"
 	"k8s.io/utils/lru"
 
 	apps "k8s.io/api/apps/v1"
@@ -52,6 +55,7 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/utils/ptr"
 )
 
 // Realistic value for maximum in-flight requests when processing in parallel mode.
@@ -71,25 +75,30 @@ type StatefulSetControlInterface interface {
 	ListRevisions(set *xstsappv1.XStatefulSet) ([]*apps.ControllerRevision, error)
 	// AdoptOrphanRevisions adopts any orphaned ControllerRevisions that match set's Selector. If all adoptions are
 	// successful the returned error is nil.
-	AdoptOrphanRevisions(set *xstsappv1.XStatefulSet, revisions []*apps.ControllerRevision) error
+	AdoptOrphanRevisions(ctx context.Context, set *xstsappv1.XStatefulSet, revisions []*apps.ControllerRevision) error
 }
 
 // NewDefaultStatefulSetControl returns a new instance of the default implementation StatefulSetControlInterface that
 // implements the documented semantics for StatefulSets. podControl is the PodControlInterface used to create, update,
 // and delete Pods and to create PersistentVolumeClaims. statusUpdater is the StatefulSetStatusUpdaterInterface used
-// to update the status of StatefulSets. You should use an instance returned from NewRealStatefulPodControl() for any
-// scenario other than testing.
+// to update the status of StatefulSets. extensions are called while syncing StatefulSets, and may be nil. clock is
+// the time availability, force deletions and scaling schedules are evaluated at. You should use an instance returned
+// from NewRealStatefulPodControl() for any scenario other than testing.
 func NewDefaultStatefulSetControl(
 	podControl *StatefulPodControl,
 	statusUpdater StatefulSetStatusUpdaterInterface,
-	controllerHistory history.Interface) StatefulSetControlInterface {
-	return &defaultStatefulSetControl{podControl, statusUpdater, controllerHistory, lru.New(maxRevisionEqualityCacheEntries)}
+	controllerHistory history.Interface,
+	extensions *Extensions,
+	clock clock.PassiveClock) StatefulSetControlInterface {
+	return &defaultStatefulSetControl{podControl, statusUpdater, controllerHistory, extensions, clock, lru.New(maxRevisionEqualityCacheEntries)}
 }
 
 type defaultStatefulSetControl struct {
 	podControl        *StatefulPodControl
 	statusUpdater     StatefulSetStatusUpdaterInterface
 	controllerHistory history.Interface
+	extensions        *Extensions
+	clock             clock.PassiveClock
 
 	revisionEqualityCache *lru.Cache
 }
@@ -102,6 +111,22 @@ type defaultStatefulSetControl struct {
 // understand the consistency implications of having unpredictable numbers of pods available.
 func (ssc *defaultStatefulSetControl) UpdateStatefulSet(ctx context.Context, set *xstsappv1.XStatefulSet, pods []*v1.Pod) (*xstsappv1.XStatefulSetStatus, error) {
 	set = set.DeepCopy() // set is modified when a new revision is created in performUpdate. Make a copy now to avoid mutation errors.
+	// let the extensions know that the Pods are not written
+	if ssc.podControl.dryRun {
+		ctx = withDryRun(ctx)
+	}
+
+	// record the hash of the referenced config in the template, so that a config change produces a new revision.
+	if rollsOutOnConfigChange(set) {
+		configHash, err := ssc.podControl.ConfigHash(set)
+		if err != nil {
+			return nil, err
+		}
+		if set.Spec.Template.Annotations == nil {
+			set.Spec.Template.Annotations = make(map[string]string)
+		}
+		set.Spec.Template.Annotations[xstsappv1.ConfigHashAnnotation] = configHash
+	}
 
 	// list all revisions and sort them
 	revisions, err := ssc.ListRevisions(set)
@@ -116,11 +141,11 @@ func (ssc *defaultStatefulSetControl) UpdateStatefulSet(ctx context.Context, set
 		if agg, ok := err.(utilerrors.Aggregate); ok {
 			errs = agg.Errors()
 		}
-		return nil, utilerrors.NewAggregate(append(errs, ssc.truncateHistory(set, pods, revisions, currentRevision, updateRevision)))
+		return nil, utilerrors.NewAggregate(append(errs, ssc.truncateHistory(ctx, set, pods, revisions, currentRevision, updateRevision)))
 	}
 
 	// maintain the set's revision history limit
-	return status, ssc.truncateHistory(set, pods, revisions, currentRevision, updateRevision)
+	return status, ssc.truncateHistory(ctx, set, pods, revisions, currentRevision, updateRevision)
 }
 
 func (ssc *defaultStatefulSetControl) performUpdate(
@@ -128,7 +153,9 @@ func (ssc *defaultStatefulSetControl) performUpdate(
 	var currentStatus *xstsappv1.XStatefulSetStatus
 	logger := klog.FromContext(ctx)
 	// get the current, and update revisions
-	currentRevision, updateRevision, collisionCount, err := ssc.getStatefulSetRevisions(set, revisions)
+	_, span := startSpan(ctx, "getStatefulSetRevisions", attribute.Int("revisions", len(revisions)))
+	currentRevision, updateRevision, collisionCount, err := ssc.getStatefulSetRevisions(ctx, set, revisions)
+	endSpan(span, err)
 	if err != nil {
 		return currentRevision, updateRevision, currentStatus, err
 	}
@@ -139,6 +166,11 @@ func (ssc *defaultStatefulSetControl) performUpdate(
 		return currentRevision, updateRevision, nil, err
 	}
 
+	// let the extensions contribute to the status, which is updated even if they fail
+	if contributeErr := ssc.extensions.contributeStatus(ctx, set, pods, currentStatus); contributeErr != nil {
+		err = utilerrors.NewAggregate([]error{err, contributeErr})
+	}
+
 	// make sure to update the latest status even if there is an error with non-nil currentStatus
 	statusErr := ssc.updateStatefulSetStatus(ctx, set, currentStatus)
 	if statusErr == nil {
@@ -175,10 +207,13 @@ func (ssc *defaultStatefulSetControl) ListRevisions(set *xstsappv1.XStatefulSet)
 }
 
 func (ssc *defaultStatefulSetControl) AdoptOrphanRevisions(
+	ctx context.Context,
 	set *xstsappv1.XStatefulSet,
 	revisions []*apps.ControllerRevision) error {
+	ctx = withAuditReason(ctx, auditReasonOrphanRevision)
 	for i := range revisions {
 		adopted, err := ssc.controllerHistory.AdoptControllerRevision(set, controllerKind, revisions[i])
+		ssc.podControl.audit.record(ctx, set, "ControllerRevision", revisions[i].Name, "adopt", revisions[i].Name, err)
 		if err != nil {
 			return err
 		}
@@ -193,6 +228,7 @@ func (ssc *defaultStatefulSetControl) AdoptOrphanRevisions(
 // only RevisionHistoryLimit revisions remain. If the returned error is nil the operation was successful. This method
 // expects that revisions is sorted when supplied.
 func (ssc *defaultStatefulSetControl) truncateHistory(
+	ctx context.Context,
 	set *xstsappv1.XStatefulSet,
 	pods []*v1.Pod,
 	revisions []*apps.ControllerRevision,
@@ -226,8 +262,11 @@ func (ssc *defaultStatefulSetControl) truncateHistory(
 	}
 	// delete any non-live history to maintain the revision limit.
 	history = history[:(historyLen - historyLimit)]
+	ctx = withAuditReason(ctx, auditReasonHistoryLimit)
 	for i := 0; i < len(history); i++ {
-		if err := ssc.controllerHistory.DeleteControllerRevision(history[i]); err != nil {
+		err := ssc.controllerHistory.DeleteControllerRevision(history[i])
+		ssc.podControl.audit.record(ctx, set, "ControllerRevision", history[i].Name, "delete", history[i].Name, err)
+		if err != nil {
 			return err
 		}
 	}
@@ -235,18 +274,20 @@ func (ssc *defaultStatefulSetControl) truncateHistory(
 }
 
 // maxRevisionEqualityCacheEntries is the size of the memory cache for equal set/controllerrevisions.
-// Allowing up to 10,000 entries takes ~1MB. Each entry consumes up to ~111 bytes:
-// - 40 bytes for the cache key (revisionEqualityKey{})
+// Allowing up to 10,000 entries takes ~1.4MB. Each entry consumes up to ~137 bytes:
+// - 56 bytes for the cache key (revisionEqualityKey{})
 // - 16 for the cache value (interface{} --> struct{}{})
 // - 36 bytes for the setUID string
+// - 10 bytes for the setConfigHash string
 // - 19 bytes for the revisionResourceVersion string
 const maxRevisionEqualityCacheEntries = 10_000
 
 // revisionEqualityKey is the cache key for remembering a particular revision RV
-// is equal to the revision that results from a particular set UID at a particular set generation.
+// is equal to the revision that results from a particular set UID at a particular set generation and config hash.
 type revisionEqualityKey struct {
 	setUID                  types.UID
 	setGeneration           int64
+	setConfigHash           string
 	revisionResourceVersion string
 }
 
@@ -255,7 +296,12 @@ func setMatchesLatestExistingRevision(set *xstsappv1.XStatefulSet, proposedRevis
 	if !utilfeature.DefaultFeatureGate.Enabled(feature.StatefulSetSemanticRevisionComparison) {
 		return false
 	}
-	equalityCacheKey := revisionEqualityKey{setUID: set.UID, setGeneration: set.Generation, revisionResourceVersion: latestExistingRevision.ResourceVersion}
+	equalityCacheKey := revisionEqualityKey{
+		setUID:                  set.UID,
+		setGeneration:           set.Generation,
+		setConfigHash:           set.Spec.Template.Annotations[xstsappv1.ConfigHashAnnotation],
+		revisionResourceVersion: latestExistingRevision.ResourceVersion,
+	}
 	if _, ok := memory.Get(equalityCacheKey); ok {
 		return true
 	}
@@ -284,6 +330,7 @@ func setMatchesLatestExistingRevision(set *xstsappv1.XStatefulSet, proposedRevis
 // a new revision, or modify the Revision of an existing revision if an update to set is detected.
 // This method expects that revisions is sorted when supplied.
 func (ssc *defaultStatefulSetControl) getStatefulSetRevisions(
+	ctx context.Context,
 	set *xstsappv1.XStatefulSet,
 	revisions []*apps.ControllerRevision) (*apps.ControllerRevision, *apps.ControllerRevision, int32, error) {
 	var currentRevision, updateRevision *apps.ControllerRevision
@@ -317,6 +364,8 @@ func (ssc *defaultStatefulSetControl) getStatefulSetRevisions(
 		updateRevision, err = ssc.controllerHistory.UpdateControllerRevision(
 			equalRevisions[equalCount-1],
 			updateRevision.Revision)
+		ssc.podControl.audit.record(withAuditReason(ctx, auditReasonRollback), set, "ControllerRevision", equalRevisions[equalCount-1].Name,
+			"update", equalRevisions[equalCount-1].Name, err)
 		if err != nil {
 			return nil, nil, collisionCount, err
 		}
@@ -325,10 +374,21 @@ func (ssc *defaultStatefulSetControl) getStatefulSetRevisions(
 		updateRevision = revisions[revisionCount-1]
 	} else {
 		//if there is no equivalent revision we create a new one
-		updateRevision, err = ssc.controllerHistory.CreateControllerRevision(set, updateRevision, &collisionCount)
+		revision := updateRevision
+		var created bool
+		updateRevision, created, err = ssc.controllerHistory.CreateControllerRevision(set, revision, &collisionCount)
 		if err != nil {
+			// the revision is named after the collision count its creation failed with
+			name := history.ControllerRevisionName(set.Name, history.HashControllerRevision(revision, &collisionCount))
+			ssc.podControl.audit.record(withAuditReason(ctx, auditReasonNewRevision), set, "ControllerRevision", name,
+				"create", name, err)
 			return nil, nil, collisionCount, err
 		}
+		// an equal revision that already existed was not written
+		if created {
+			ssc.podControl.audit.record(withAuditReason(ctx, auditReasonNewRevision), set, "ControllerRevision", updateRevision.Name,
+				"create", updateRevision.Name, nil)
+		}
 	}
 
 	// attempt to find the revision that corresponds to the current revision
@@ -379,6 +439,52 @@ func slowStartBatch(initialBatchSize int, remaining int, fn func(int) (bool, err
 	return successes, nil
 }
 
+// podOperationBudget bounds the number of Pod creations and deletions that are started during a single sync of a set
+// that uses the BoundedParallel PodManagementPolicy. A nil budget is unlimited. It is safe for concurrent use.
+type podOperationBudget struct {
+	remaining atomic.Int64
+}
+
+// newPodOperationBudget returns the budget of Pod operations that may be started for set, or nil if set's
+// PodManagementPolicy does not bound concurrent operations. Pods in podLists that are in flight at now count against
+// the budget.
+func newPodOperationBudget(set *xstsappv1.XStatefulSet, now time.Time, podLists ...[]*v1.Pod) (*podOperationBudget, error) {
+	if !isBoundedParallel(set) {
+		return nil, nil
+	}
+	maxConcurrent, err := getStatefulSetMaxConcurrentPodOperations(set.Spec.MaxConcurrentPodOperations, int(*set.Spec.Replicas))
+	if err != nil {
+		return nil, err
+	}
+	inFlight := 0
+	for _, list := range podLists {
+		for _, pod := range list {
+			if pod != nil && isInFlight(pod, set.Spec.MinReadySeconds, now) {
+				inFlight++
+			}
+		}
+	}
+	budget := &podOperationBudget{}
+	budget.remaining.Store(int64(maxConcurrent - inFlight))
+	return budget, nil
+}
+
+// tryAcquire reserves one Pod operation, returning false if the budget is exhausted.
+func (b *podOperationBudget) tryAcquire() bool {
+	if b == nil {
+		return true
+	}
+	for {
+		remaining := b.remaining.Load()
+		if remaining <= 0 {
+			return false
+		}
+		if b.remaining.CompareAndSwap(remaining, remaining-1) {
+			return true
+		}
+	}
+}
+
 type replicaStatus struct {
 	replicas          int32
 	readyReplicas     int32
@@ -387,7 +493,7 @@ type replicaStatus struct {
 	updatedReplicas   int32
 }
 
-func computeReplicaStatus(pods []*v1.Pod, minReadySeconds int32, currentRevision, updateRevision *apps.ControllerRevision) replicaStatus {
+func computeReplicaStatus(pods []*v1.Pod, minReadySeconds int32, now time.Time, currentRevision, updateRevision *apps.ControllerRevision) replicaStatus {
 	status := replicaStatus{}
 	for _, pod := range pods {
 		if isCreated(pod) {
@@ -398,7 +504,7 @@ func computeReplicaStatus(pods []*v1.Pod, minReadySeconds int32, currentRevision
 		if isRunningAndReady(pod) {
 			status.readyReplicas++
 			// count the number of running and available replicas
-			if isRunningAndAvailable(pod, minReadySeconds) {
+			if isRunningAndAvailable(pod, minReadySeconds, now) {
 				status.availableReplicas++
 			}
 
@@ -418,14 +524,14 @@ func computeReplicaStatus(pods []*v1.Pod, minReadySeconds int32, currentRevision
 	return status
 }
 
-func updateStatus(status *xstsappv1.XStatefulSetStatus, minReadySeconds int32, currentRevision, updateRevision *apps.ControllerRevision, podLists ...[]*v1.Pod) {
+func updateStatus(status *xstsappv1.XStatefulSetStatus, minReadySeconds int32, now time.Time, currentRevision, updateRevision *apps.ControllerRevision, podLists ...[]*v1.Pod) {
 	status.Replicas = 0
 	status.ReadyReplicas = 0
 	status.AvailableReplicas = 0
 	status.CurrentReplicas = 0
 	status.UpdatedReplicas = 0
 	for _, list := range podLists {
-		replicaStatus := computeReplicaStatus(list, minReadySeconds, currentRevision, updateRevision)
+		replicaStatus := computeReplicaStatus(list, minReadySeconds, now, currentRevision, updateRevision)
 		status.Replicas += replicaStatus.replicas
 		status.ReadyReplicas += replicaStatus.readyReplicas
 		status.AvailableReplicas += replicaStatus.availableReplicas
@@ -439,10 +545,17 @@ func (ssc *defaultStatefulSetControl) processReplica(
 	set *xstsappv1.XStatefulSet,
 	updateSet *xstsappv1.XStatefulSet,
 	monotonic bool,
+	budget *podOperationBudget,
 	replicas []*v1.Pod,
 	i int) (bool, error) {
 	logger := klog.FromContext(ctx)
 
+	// A Pod stuck terminating on an unreachable Node would otherwise block progress forever.
+	if deleted, err := ssc.forceDeleteUnreachablePod(ctx, set, replicas[i]); err != nil || deleted {
+		// New pod should be generated on the next sync after the current pod is removed from etcd.
+		return true, err
+	}
+
 	// Note that pods with phase Succeeded will also trigger this event. This is
 	// because final pod phase of evicted or otherwise forcibly stopped pods
 	// (e.g. terminated on node reboot) is determined by the exit code of the
@@ -450,7 +563,16 @@ func (ssc *defaultStatefulSetControl) processReplica(
 	// regardless of the exit code.
 	if isFailed(replicas[i]) || isSucceeded(replicas[i]) {
 		if replicas[i].DeletionTimestamp == nil {
-			if err := ssc.podControl.DeleteStatefulPod(set, replicas[i]); err != nil {
+			if gated, err := ssc.deletionGated(ctx, set, replicas[i]); gated || err != nil {
+				return true, err
+			}
+			if !budget.tryAcquire() {
+				logger.V(4).Info("StatefulSet is waiting for in-flight Pods before replacing Pod",
+					"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
+				ssc.recordSyncBlocked(set, blockedInFlightPods)
+				return true, nil
+			}
+			if err := ssc.podControl.DeleteStatefulPod(withAuditReason(ctx, auditReasonFailedReplica), set, replicas[i]); err != nil {
 				return true, err
 			}
 		}
@@ -463,9 +585,16 @@ func (ssc *defaultStatefulSetControl) processReplica(
 			return true, err
 		} else if isStale {
 			// If a pod has a stale PVC, no more work can be done this round.
+			ssc.recordSyncBlocked(set, blockedStaleClaim)
 			return true, err
 		}
-		if err := ssc.podControl.CreateStatefulPod(ctx, set, replicas[i]); err != nil {
+		if !budget.tryAcquire() {
+			logger.V(4).Info("StatefulSet is waiting for in-flight Pods before creating Pod",
+				"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
+			ssc.recordSyncBlocked(set, blockedInFlightPods)
+			return true, nil
+		}
+		if err := ssc.podControl.CreateStatefulPod(withAuditReason(ctx, auditReasonMissingReplica), set, replicas[i]); err != nil {
 			return true, err
 		}
 		if monotonic {
@@ -479,7 +608,7 @@ func (ssc *defaultStatefulSetControl) processReplica(
 		logger.V(4).Info(
 			"StatefulSet is triggering PVC creation for pending Pod",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
-		if err := ssc.podControl.createMissingPersistentVolumeClaims(ctx, set, replicas[i]); err != nil {
+		if err := ssc.podControl.createMissingPersistentVolumeClaims(withAuditReason(ctx, auditReasonMissingReplica), set, replicas[i]); err != nil {
 			return true, err
 		}
 	}
@@ -489,6 +618,7 @@ func (ssc *defaultStatefulSetControl) processReplica(
 	if isTerminating(replicas[i]) && monotonic {
 		logger.V(4).Info("StatefulSet is waiting for Pod to Terminate",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
+		ssc.recordSyncBlocked(set, blockedTerminating)
 		return true, nil
 	}
 
@@ -498,15 +628,17 @@ func (ssc *defaultStatefulSetControl) processReplica(
 	if !isRunningAndReady(replicas[i]) && monotonic {
 		logger.V(4).Info("StatefulSet is waiting for Pod to be Running and Ready",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
+		ssc.recordSyncBlocked(set, blockedNotReady)
 		return true, nil
 	}
 
 	// If we have a Pod that has been created but is not available we can not make progress.
 	// We must ensure that all for each Pod, when we create it, all of its predecessors, with respect to its
 	// ordinal, are Available.
-	if !isRunningAndAvailable(replicas[i], set.Spec.MinReadySeconds) && monotonic {
+	if !isRunningAndAvailable(replicas[i], set.Spec.MinReadySeconds, ssc.clock.Now()) && monotonic {
 		logger.V(4).Info("StatefulSet is waiting for Pod to be Available",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[i]))
+		ssc.recordSyncBlocked(set, blockedNotAvailable)
 		return true, nil
 	}
 
@@ -523,20 +655,24 @@ func (ssc *defaultStatefulSetControl) processReplica(
 
 	// Make a deep copy so we don't mutate the shared cache
 	replica := replicas[i].DeepCopy()
-	if err := ssc.podControl.UpdateStatefulPod(ctx, updateSet, replica); err != nil {
+	if err := ssc.podControl.UpdateStatefulPod(withAuditReason(ctx, auditReasonInconsistentPod), updateSet, replica); err != nil {
 		return true, err
 	}
 
 	return false, nil
 }
 
-func (ssc *defaultStatefulSetControl) processCondemned(ctx context.Context, set *xstsappv1.XStatefulSet, firstUnhealthyPod *v1.Pod, monotonic bool, condemned []*v1.Pod, i int) (bool, error) {
+func (ssc *defaultStatefulSetControl) processCondemned(ctx context.Context, set *xstsappv1.XStatefulSet, firstUnhealthyPod *v1.Pod, monotonic bool, budget *podOperationBudget, condemned []*v1.Pod, i int) (bool, error) {
 	logger := klog.FromContext(ctx)
 	if isTerminating(condemned[i]) {
+		if deleted, err := ssc.forceDeleteUnreachablePod(ctx, set, condemned[i]); err != nil || deleted {
+			return true, err
+		}
 		// if we are in monotonic mode, block and wait for terminating pods to expire
 		if monotonic {
 			logger.V(4).Info("StatefulSet is waiting for Pod to Terminate prior to scale down",
 				"statefulSet", klog.KObj(set), "pod", klog.KObj(condemned[i]))
+			ssc.recordSyncBlocked(set, blockedTerminating)
 			return true, nil
 		}
 		return false, nil
@@ -545,18 +681,68 @@ func (ssc *defaultStatefulSetControl) processCondemned(ctx context.Context, set
 	if !isRunningAndReady(condemned[i]) && monotonic && condemned[i] != firstUnhealthyPod {
 		logger.V(4).Info("StatefulSet is waiting for Pod to be Running and Ready prior to scale down",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(firstUnhealthyPod))
+		ssc.recordSyncBlocked(set, blockedNotReady)
 		return true, nil
 	}
 	// if we are in monotonic mode and the condemned target is not the first unhealthy Pod, block.
-	if !isRunningAndAvailable(condemned[i], set.Spec.MinReadySeconds) && monotonic && condemned[i] != firstUnhealthyPod {
+	if !isRunningAndAvailable(condemned[i], set.Spec.MinReadySeconds, ssc.clock.Now()) && monotonic && condemned[i] != firstUnhealthyPod {
 		logger.V(4).Info("StatefulSet is waiting for Pod to be Available prior to scale down",
 			"statefulSet", klog.KObj(set), "pod", klog.KObj(firstUnhealthyPod))
+		ssc.recordSyncBlocked(set, blockedNotAvailable)
+		return true, nil
+	}
+	if gated, err := ssc.deletionGated(ctx, set, condemned[i]); gated || err != nil {
+		return true, err
+	}
+	if !budget.tryAcquire() {
+		logger.V(4).Info("StatefulSet is waiting for in-flight Pods prior to scale down",
+			"statefulSet", klog.KObj(set), "pod", klog.KObj(condemned[i]))
+		ssc.recordSyncBlocked(set, blockedInFlightPods)
 		return true, nil
 	}
 
 	logger.V(2).Info("Pod of StatefulSet is terminating for scale down",
 		"statefulSet", klog.KObj(set), "pod", klog.KObj(condemned[i]))
-	return true, ssc.podControl.DeleteStatefulPod(set, condemned[i])
+	return true, ssc.podControl.DeleteStatefulPod(withAuditReason(ctx, auditReasonScaleDown), set, condemned[i])
+}
+
+// forceDeleteUnreachablePod force deletes pod if it has been terminating on an unreachable Node for longer than set's
+// UnreachableNodePolicy allows. It returns true if the Pod was force deleted.
+func (ssc *defaultStatefulSetControl) forceDeleteUnreachablePod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) (bool, error) {
+	logger := klog.FromContext(ctx)
+	forceDeleteTime, ok, err := ssc.podControl.UnreachablePodForceDeleteTime(set, pod)
+	if err != nil || !ok {
+		return false, err
+	}
+	if ssc.clock.Now().Before(forceDeleteTime) {
+		logger.V(4).Info("StatefulSet is waiting to force delete Pod on unreachable Node",
+			"statefulSet", klog.KObj(set), "pod", klog.KObj(pod), "node", pod.Spec.NodeName, "forceDeleteTime", forceDeleteTime)
+		return false, nil
+	}
+	logger.V(2).Info("Pod of StatefulSet is terminating on unreachable Node, force deleting",
+		"statefulSet", klog.KObj(set), "pod", klog.KObj(pod), "node", pod.Spec.NodeName)
+	if err := ssc.podControl.ForceDeleteStatefulPod(withAuditReason(ctx, auditReasonUnreachableNode), set, pod); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// applyScalingSchedules evaluates the scaling schedules of set and, once one of them has fired, overrides the desired
+// number of replicas of set with the replicas of that schedule. set must be a copy that is not shared with the cache.
+// It returns the scaling schedule status to record for set.
+func (ssc *defaultStatefulSetControl) applyScalingSchedules(ctx context.Context, set *xstsappv1.XStatefulSet) *xstsappv1.ScalingScheduleStatus {
+	logger := klog.FromContext(ctx)
+	status, errs := getScalingScheduleStatus(set, ssc.clock.Now())
+	for _, err := range errs {
+		logger.Error(err, "Skipping invalid scaling schedule", "statefulSet", klog.KObj(set))
+		ssc.podControl.recorder.Event(set, v1.EventTypeWarning, "InvalidScalingSchedule", err.Error())
+	}
+	if replicas, ok := getScheduledReplicas(set, status); ok && replicas != *set.Spec.Replicas {
+		logger.V(4).Info("Scaling schedule overrides the replicas of StatefulSet", "statefulSet", klog.KObj(set),
+			"schedule", status.ActiveSchedule, "replicas", *set.Spec.Replicas, "scheduledReplicas", replicas)
+		set.Spec.Replicas = ptr.To(replicas)
+	}
+	return status
 }
 
 func runForAll(pods []*v1.Pod, fn func(i int) (bool, error), monotonic bool) (bool, error) {
@@ -591,6 +777,11 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	collisionCount int32,
 	pods []*v1.Pod) (*xstsappv1.XStatefulSetStatus, error) {
 	logger := klog.FromContext(ctx)
+	// availability is evaluated at the same time for all Pods of the set.
+	now := ssc.clock.Now()
+	// apply the scaling schedules of the set to its desired number of replicas.
+	scalingSchedule := ssc.applyScalingSchedules(ctx, set)
+
 	// get the current and update revisions of the set.
 	currentSet, err := ApplyRevision(set, currentRevision)
 	if err != nil {
@@ -608,6 +799,15 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	status.UpdateRevision = updateRevision.Name
 	status.CollisionCount = new(int32)
 	*status.CollisionCount = collisionCount
+	status.ScalingSchedule = scalingSchedule
+	// the controller computes no conditions of its own, so keep the conditions set by StatusContributors and other
+	// writers of the status
+	if set.Status.Conditions != nil {
+		status.Conditions = make([]apps.StatefulSetCondition, len(set.Status.Conditions))
+		for i := range set.Status.Conditions {
+			set.Status.Conditions[i].DeepCopyInto(&status.Conditions[i])
+		}
+	}
 
 	// Convert the LabelSelector to string for the scale subresource
 	if set.Spec.Selector != nil {
@@ -617,19 +817,26 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 		}
 	}
 
-	updateStatus(&status, set.Spec.MinReadySeconds, currentRevision, updateRevision, pods)
+	updateStatus(&status, set.Spec.MinReadySeconds, now, currentRevision, updateRevision, pods)
 
 	replicaCount := int(*set.Spec.Replicas)
+	// A suspended set condemns all of its Pods. Its replicas are left untouched, so the claims of Pods within the
+	// ordinal range are retained regardless of the whenScaled policy and the same ordinals are restored on resume.
+	suspended := isSuspended(set)
+	if suspended {
+		replicaCount = 0
+	}
 	// slice that will contain all Pods such that getStartOrdinal(set) <= getOrdinal(pod) <= getEndOrdinal(set)
 	replicas := make([]*v1.Pod, replicaCount)
 	// slice that will contain all Pods such that getOrdinal(pod) < getStartOrdinal(set) OR getOrdinal(pod) > getEndOrdinal(set)
 	condemned := make([]*v1.Pod, 0, len(pods))
 	unavailable := 0
+	unavailableReplicas := 0
 	var firstUnavailablePod *v1.Pod
 
 	// First we partition pods into two lists valid replicas and condemned Pods
 	for _, pod := range pods {
-		if podInOrdinalRange(pod, set) {
+		if !suspended && podInOrdinalRange(pod, set) {
 			// if the ordinal of the pod is within the range of the current number of replicas,
 			// insert it at the indirection of its ordinal
 			replicas[getOrdinal(pod)-getStartOrdinal(set)] = pod
@@ -641,15 +848,19 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	}
 
 	// for any empty indices in the sequence [0,set.Spec.Replicas) create a new Pod at the correct revision
-	start, end := getStartOrdinal(set), getEndOrdinal(set)
+	start, end := getStartOrdinal(set), getStartOrdinal(set)+replicaCount-1
 	for ord := start; ord <= end; ord++ {
 		replicaIdx := ord - start
 		if replicas[replicaIdx] == nil {
-			replicas[replicaIdx] = newVersionedStatefulSetPod(
+			pod := newVersionedStatefulSetPod(
 				currentSet,
 				updateSet,
 				currentRevision.Name,
 				updateRevision.Name, ord)
+			if err := ssc.extensions.mutatePod(ctx, set, pod); err != nil {
+				return &status, err
+			}
+			replicas[replicaIdx] = pod
 		}
 	}
 
@@ -658,8 +869,9 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 
 	// find the first unhealthy Pod
 	for i := range replicas {
-		if isUnavailable(replicas[i], set.Spec.MinReadySeconds) {
+		if isUnavailable(replicas[i], set.Spec.MinReadySeconds, now) {
 			unavailable++
+			unavailableReplicas++
 			if firstUnavailablePod == nil {
 				firstUnavailablePod = replicas[i]
 			}
@@ -668,7 +880,7 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 
 	// or the first unhealthy condemned Pod (condemned are sorted in descending order for ease of use)
 	for i := len(condemned) - 1; i >= 0; i-- {
-		if isUnavailable(condemned[i], set.Spec.MinReadySeconds) {
+		if isUnavailable(condemned[i], set.Spec.MinReadySeconds, now) {
 			unavailable++
 			if firstUnavailablePod == nil {
 				firstUnavailablePod = condemned[i]
@@ -676,6 +888,9 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 		}
 	}
 
+	if !ssc.podControl.dryRun {
+		updateUnavailableReplicasMetric(set, unavailableReplicas)
+	}
 	if unavailable > 0 {
 		logger.V(4).Info("StatefulSet has unavailable Pods", "statefulSet", klog.KObj(set), "unavailableReplicas", unavailable, "pod", klog.KObj(firstUnavailablePod))
 	}
@@ -687,13 +902,23 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	}
 
 	monotonic := !allowsBurst(set)
+	// budget bounds the number of Pods in flight for the BoundedParallel policy, it is nil otherwise.
+	budget, err := newPodOperationBudget(set, now, replicas, condemned)
+	if err != nil {
+		return &status, err
+	}
 
 	// First, process each living replica. Exit if we run into an error or something blocking in monotonic mode.
-	processReplicaFn := func(i int) (bool, error) {
-		return ssc.processReplica(ctx, set, updateSet, monotonic, replicas, i)
+	processReplicaFn := func(i int) (shouldExit bool, err error) {
+		ctx, span := startSpan(ctx, "processReplica", attribute.String("pod", replicas[i].Name))
+		defer func() {
+			span.SetAttributes(attribute.Bool("shouldExit", shouldExit))
+			endSpan(span, err)
+		}()
+		return ssc.processReplica(ctx, set, updateSet, monotonic, budget, replicas, i)
 	}
 	if shouldExit, err := runForAll(replicas, processReplicaFn, monotonic); shouldExit || err != nil {
-		updateStatus(&status, set.Spec.MinReadySeconds, currentRevision, updateRevision, replicas, condemned)
+		updateStatus(&status, set.Spec.MinReadySeconds, now, currentRevision, updateRevision, replicas, condemned)
 		return &status, err
 	}
 
@@ -702,14 +927,14 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 		if matchPolicy, err := ssc.podControl.ClaimsMatchRetentionPolicy(ctx, updateSet, condemned[i]); err != nil {
 			return true, err
 		} else if !matchPolicy {
-			if err := ssc.podControl.UpdatePodClaimForRetentionPolicy(ctx, updateSet, condemned[i]); err != nil {
+			if err := ssc.podControl.UpdatePodClaimForRetentionPolicy(withAuditReason(ctx, auditReasonRetentionPolicy), updateSet, condemned[i]); err != nil {
 				return true, err
 			}
 		}
 		return false, nil
 	}
 	if shouldExit, err := runForAll(condemned, fixPodClaim, monotonic); shouldExit || err != nil {
-		updateStatus(&status, set.Spec.MinReadySeconds, currentRevision, updateRevision, replicas, condemned)
+		updateStatus(&status, set.Spec.MinReadySeconds, now, currentRevision, updateRevision, replicas, condemned)
 		return &status, err
 	}
 
@@ -719,15 +944,20 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 	// We will terminate Pods in a monotonically decreasing order.
 	// Note that we do not resurrect Pods in this interval. Also note that scaling will take precedence over
 	// updates.
-	processCondemnedFn := func(i int) (bool, error) {
-		return ssc.processCondemned(ctx, set, firstUnavailablePod, monotonic, condemned, i)
+	processCondemnedFn := func(i int) (shouldExit bool, err error) {
+		ctx, span := startSpan(ctx, "processCondemned", attribute.String("pod", condemned[i].Name))
+		defer func() {
+			span.SetAttributes(attribute.Bool("shouldExit", shouldExit))
+			endSpan(span, err)
+		}()
+		return ssc.processCondemned(ctx, set, firstUnavailablePod, monotonic, budget, condemned, i)
 	}
 	if shouldExit, err := runForAll(condemned, processCondemnedFn, monotonic); shouldExit || err != nil {
-		updateStatus(&status, set.Spec.MinReadySeconds, currentRevision, updateRevision, replicas, condemned)
+		updateStatus(&status, set.Spec.MinReadySeconds, now, currentRevision, updateRevision, replicas, condemned)
 		return &status, err
 	}
 
-	updateStatus(&status, set.Spec.MinReadySeconds, currentRevision, updateRevision, replicas, condemned)
+	updateStatus(&status, set.Spec.MinReadySeconds, now, currentRevision, updateRevision, replicas, condemned)
 
 	// for the OnDelete strategy we short circuit. Pods will be updated when they are manually deleted.
 	if set.Spec.UpdateStrategy.Type == apps.OnDeleteStatefulSetStrategyType {
@@ -740,6 +970,8 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 			set,
 			replicas,
 			updateRevision,
+			budget,
+			now,
 			status,
 		)
 	}
@@ -750,13 +982,22 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 		updateMin = int(*set.Spec.UpdateStrategy.RollingUpdate.Partition)
 	}
 	// we terminate the Pod with the largest ordinal that does not match the update revision.
-	for target := len(replicas) - 1; target >= updateMin; target-- {
+	for _, target := range ssc.updateTargets(ctx, set, replicas, updateMin) {
 
 		// delete the Pod if it is not already terminating and does not match the update revision.
-		if getPodRevision(replicas[target]) != updateRevision.Name && !isTerminating(replicas[target]) {
+		if getPodRevision(target) != updateRevision.Name && !isTerminating(target) {
+			if gated, err := ssc.deletionGated(ctx, set, target); gated || err != nil {
+				return &status, err
+			}
+			if !budget.tryAcquire() {
+				logger.V(4).Info("StatefulSet is waiting for in-flight Pods to update",
+					"statefulSet", klog.KObj(set), "pod", klog.KObj(target))
+				ssc.recordSyncBlocked(set, blockedInFlightPods)
+				return &status, nil
+			}
 			logger.V(2).Info("Pod of StatefulSet is terminating for update",
-				"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[target]))
-			if err := ssc.podControl.DeleteStatefulPod(set, replicas[target]); err != nil {
+				"statefulSet", klog.KObj(set), "pod", klog.KObj(target))
+			if err := ssc.podControl.DeleteStatefulPod(withAuditReason(ctx, auditReasonRollingUpdate), set, target); err != nil {
 				if !errors.IsNotFound(err) {
 					return &status, err
 				}
@@ -766,9 +1007,10 @@ func (ssc *defaultStatefulSetControl) updateStatefulSet(
 		}
 
 		// wait for unavailable Pods on update
-		if isUnavailable(replicas[target], set.Spec.MinReadySeconds) {
+		if isUnavailable(target, set.Spec.MinReadySeconds, now) {
 			logger.V(4).Info("StatefulSet is waiting for Pod to update",
-				"statefulSet", klog.KObj(set), "pod", klog.KObj(replicas[target]))
+				"statefulSet", klog.KObj(set), "pod", klog.KObj(target))
+			ssc.recordSyncBlocked(set, blockedNotAvailable)
 			return &status, nil
 		}
 
@@ -782,12 +1024,13 @@ func updateStatefulSetAfterInvariantEstablished(
 	set *xstsappv1.XStatefulSet,
 	replicas []*v1.Pod,
 	updateRevision *apps.ControllerRevision,
+	budget *podOperationBudget,
+	now time.Time,
 	status xstsappv1.XStatefulSetStatus,
 ) (*xstsappv1.XStatefulSetStatus, error) {
 
 	logger := klog.FromContext(ctx)
 	replicaCount := int(*set.Spec.Replicas)
-	podManagementPolicy := string(set.Spec.PodManagementPolicy)
 	// we compute the minimum ordinal of the target sequence for a destructive update based on the strategy.
 	updateMin := 0
 	maxUnavailable := 1
@@ -811,13 +1054,15 @@ func updateStatefulSetAfterInvariantEstablished(
 	unavailablePods := 0
 
 	for target := len(replicas) - 1; target >= 0; target-- {
-		if isUnavailable(replicas[target], set.Spec.MinReadySeconds) {
+		if isUnavailable(replicas[target], set.Spec.MinReadySeconds, now) {
 			unavailablePods++
 		}
 	}
-	metrics.UnavailableReplicas.WithLabelValues(set.Namespace, set.Name, podManagementPolicy).Set(float64(unavailablePods))
 
 	if unavailablePods >= maxUnavailable {
+		if status.CurrentRevision != status.UpdateRevision {
+			ssc.recordSyncBlocked(set, blockedMaxUnavailable)
+		}
 		// log only when a true violation occurs.
 		if unavailablePods > maxUnavailable {
 			logger.V(4).Info("StatefulSet found unavailablePods, more than the allowed maxUnavailable",
@@ -834,15 +1079,27 @@ func updateStatefulSetAfterInvariantEstablished(
 	podsToDelete := maxUnavailable - unavailablePods
 
 	deletedPods := 0
-	for target := len(replicas) - 1; target >= updateMin && deletedPods < podsToDelete; target-- {
+	for _, target := range ssc.updateTargets(ctx, set, replicas, updateMin) {
+		if deletedPods >= podsToDelete {
+			break
+		}
 
 		// delete the Pod if it is healthy and the revision does not match the target
-		if getPodRevision(replicas[target]) != updateRevision.Name && !isTerminating(replicas[target]) {
+		if getPodRevision(target) != updateRevision.Name && !isTerminating(target) {
+			if gated, err := ssc.deletionGated(ctx, set, target); gated || err != nil {
+				return &status, err
+			}
+			if !budget.tryAcquire() {
+				logger.V(4).Info("StatefulSet is waiting for in-flight Pods to update",
+					"statefulSet", klog.KObj(set), "pod", klog.KObj(target))
+				ssc.recordSyncBlocked(set, blockedInFlightPods)
+				break
+			}
 			// delete the Pod if it is healthy and the revision does not match the target
 			logger.V(2).Info("StatefulSet terminating Pod for update",
 				"statefulSet", klog.KObj(set),
-				"pod", klog.KObj(replicas[target]))
-			if err := ssc.podControl.DeleteStatefulPod(set, replicas[target]); err != nil {
+				"pod", klog.KObj(target))
+			if err := ssc.podControl.DeleteStatefulPod(withAuditReason(ctx, auditReasonRollingUpdate), set, target); err != nil {
 				if !errors.IsNotFound(err) {
 					return &status, err
 				}
@@ -864,21 +1121,19 @@ func (ssc *defaultStatefulSetControl) updateStatefulSetStatus(
 	// complete any in progress rolling update if necessary
 	completeRollingUpdate(set, status)
 
-	if utilfeature.DefaultFeatureGate.Enabled(feature.MaxUnavailableStatefulSet) {
-		// Update metrics - this ensures metrics are always updated regardless of update strategy
-		podManagementPolicy := string(set.Spec.PodManagementPolicy)
-		replicaCount := int(*set.Spec.Replicas)
-
+	// update metrics - this ensures metrics are always updated regardless of update strategy
+	maxUnavailable := 1
+	if set.Spec.UpdateStrategy.RollingUpdate != nil && utilfeature.DefaultFeatureGate.Enabled(feature.MaxUnavailableStatefulSet) {
 		var err error
-		maxUnavailable := 1
-		if set.Spec.UpdateStrategy.RollingUpdate != nil {
-			maxUnavailable, err = getStatefulSetMaxUnavailable(set.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable, replicaCount)
-			if err != nil {
-				return err
-			}
+		maxUnavailable, err = getStatefulSetMaxUnavailable(set.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable, int(*set.Spec.Replicas))
+		if err != nil {
+			return err
 		}
-		metrics.MaxUnavailable.WithLabelValues(set.Namespace, set.Name, podManagementPolicy).Set(float64(maxUnavailable))
 	}
+	if !ssc.podControl.dryRun {
+		updateStatusMetrics(set, status, maxUnavailable)
+	}
+
 	// if the status is not inconsistent do not perform an update
 	if !inconsistentStatus(set, status) {
 		return nil
@@ -886,11 +1141,9 @@ func (ssc *defaultStatefulSetControl) updateStatefulSetStatus(
 
 	// copy set and update its status
 	set = set.DeepCopy()
-	if err := ssc.statusUpdater.UpdateStatefulSetStatus(ctx, set, status); err != nil {
-		return err
-	}
-
-	return nil
+	err := ssc.statusUpdater.UpdateStatefulSetStatus(ctx, set, status)
+	ssc.podControl.audit.record(withAuditReason(ctx, auditReasonStatusChanged), set, "XStatefulSet", set.Name, "updateStatus", status.UpdateRevision, err)
+	return err
 }
 
 var _ StatefulSetControlInterface = &defaultStatefulSetControl{}
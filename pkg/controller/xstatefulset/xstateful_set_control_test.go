@@ -0,0 +1,254 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	appslisters "github.com/xsts-sh/xstatefulset/client-go/listers/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+)
+
+func TestPodOperationBudget(t *testing.T) {
+	readyPod := &v1.Pod{Status: v1.PodStatus{
+		Phase:      v1.PodRunning,
+		Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+	}}
+	pendingPod := &v1.Pod{Status: v1.PodStatus{Phase: v1.PodPending}}
+	terminatingPod := readyPod.DeepCopy()
+	terminatingPod.DeletionTimestamp = ptr.To(metav1.Now())
+	notCreatedPod := &v1.Pod{}
+	neverReadyPod := &v1.Pod{Status: v1.PodStatus{
+		Phase:      v1.PodRunning,
+		Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}},
+	}}
+	crashLoopingPod := neverReadyPod.DeepCopy()
+	crashLoopingPod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:         "nginx",
+		RestartCount: 5,
+		State:        v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+	justReadyPod := readyPod.DeepCopy()
+	justReadyPod.Status.Conditions[0].LastTransitionTime = metav1.Now()
+
+	tests := []struct {
+		name            string
+		policy          apps.PodManagementPolicyType
+		maxConcurrent   *intstr.IntOrString
+		minReadySeconds int32
+		pods            []*v1.Pod
+		expectUnlimited bool
+		expectAcquired  int
+	}{
+		{
+			name:            "parallel is unlimited",
+			policy:          apps.ParallelPodManagement,
+			pods:            []*v1.Pod{pendingPod, pendingPod},
+			expectUnlimited: true,
+		},
+		{
+			name:           "in-flight pods consume the budget",
+			policy:         xstsappv1.BoundedParallelPodManagement,
+			maxConcurrent:  ptr.To(intstr.FromInt32(3)),
+			pods:           []*v1.Pod{readyPod, pendingPod, terminatingPod, notCreatedPod},
+			expectAcquired: 1,
+		},
+		{
+			name:           "never ready pods do not hold the budget",
+			policy:         xstsappv1.BoundedParallelPodManagement,
+			maxConcurrent:  ptr.To(intstr.FromInt32(2)),
+			pods:           []*v1.Pod{neverReadyPod, crashLoopingPod},
+			expectAcquired: 2,
+		},
+		{
+			name:            "pods within minReadySeconds hold the budget",
+			policy:          xstsappv1.BoundedParallelPodManagement,
+			maxConcurrent:   ptr.To(intstr.FromInt32(2)),
+			minReadySeconds: 60,
+			pods:            []*v1.Pod{justReadyPod},
+			expectAcquired:  1,
+		},
+		{
+			name:           "exhausted budget",
+			policy:         xstsappv1.BoundedParallelPodManagement,
+			maxConcurrent:  ptr.To(intstr.FromInt32(1)),
+			pods:           []*v1.Pod{pendingPod, pendingPod},
+			expectAcquired: 0,
+		},
+		{
+			name:           "percentage is never below one",
+			policy:         xstsappv1.BoundedParallelPodManagement,
+			maxConcurrent:  ptr.To(intstr.FromString("1%")),
+			pods:           []*v1.Pod{readyPod},
+			expectAcquired: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &xstsappv1.XStatefulSet{Spec: xstsappv1.XStatefulSetSpec{
+				Replicas:                   ptr.To[int32](10),
+				PodManagementPolicy:        tt.policy,
+				MaxConcurrentPodOperations: tt.maxConcurrent,
+				MinReadySeconds:            tt.minReadySeconds,
+			}}
+			budget, err := newPodOperationBudget(set, time.Now(), tt.pods)
+			if err != nil {
+				t.Fatalf("newPodOperationBudget() error = %v", err)
+			}
+			if tt.expectUnlimited {
+				if budget != nil {
+					t.Fatalf("expected an unlimited budget")
+				}
+				return
+			}
+			acquired := 0
+			for budget.tryAcquire() {
+				acquired++
+			}
+			if acquired != tt.expectAcquired {
+				t.Errorf("expected %d operations, got %d", tt.expectAcquired, acquired)
+			}
+		})
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	ctx := context.Background()
+	labels := map[string]string{"app": "web"}
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: "web-uid"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas:             ptr.To[int32](3),
+			PodManagementPolicy:  apps.ParallelPodManagement,
+			RevisionHistoryLimit: ptr.To[int32](10),
+			Selector:             &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+			// claims of pods that are scaled down are deleted, those of suspended pods are not
+			PersistentVolumeClaimRetentionPolicy: &apps.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenScaled:  apps.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenDeleted: apps.RetainPersistentVolumeClaimRetentionPolicyType,
+			},
+			Suspend: ptr.To(true),
+		},
+	}
+	var objects []runtime.Object
+	var pods []*v1.Pod
+	for ordinal := range 3 {
+		pod := newStatefulSetPod(set, ordinal)
+		pod.Status = v1.PodStatus{
+			Phase:      v1.PodRunning,
+			Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+		}
+		pods = append(pods, pod)
+		objects = append(objects, pod)
+		for _, claim := range getPersistentVolumeClaims(set, pod) {
+			objects = append(objects, claim.DeepCopy())
+		}
+	}
+	kubeClient := fake.NewClientset(objects...)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	for _, object := range objects {
+		switch object := object.(type) {
+		case *v1.Pod:
+			kubeInformers.Core().V1().Pods().Informer().GetIndexer().Add(object)
+		case *v1.PersistentVolumeClaim:
+			kubeInformers.Core().V1().PersistentVolumeClaims().Informer().GetIndexer().Add(object)
+		}
+	}
+	xstatefulsetClient := xstatefulsetfake.NewClientset(set)
+	setIndexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	setIndexer.Add(set)
+	ssc := NewDefaultStatefulSetControl(
+		NewStatefulPodControl(kubeClient,
+			kubeInformers.Core().V1().Pods().Lister(),
+			kubeInformers.Core().V1().PersistentVolumeClaims().Lister(),
+			kubeInformers.Core().V1().Nodes().Lister(),
+			kubeInformers.Core().V1().ConfigMaps().Lister(),
+			kubeInformers.Core().V1().Secrets().Lister(),
+			record.NewFakeRecorder(10),
+			controller.NewUIDTrackingControllerExpectations(controller.NewControllerExpectations()),
+			PodOperationLimits{}),
+		NewRealStatefulSetStatusUpdater(xstatefulsetClient, appslisters.NewXStatefulSetLister(setIndexer)),
+		history.NewFakeHistory(kubeInformers.Apps().V1().ControllerRevisions()),
+		nil,
+		clock.RealClock{})
+
+	// suspending condemns every pod, but keeps the replicas and the claims of the pods
+	if _, err := ssc.UpdateStatefulSet(ctx, set, pods); err != nil {
+		t.Fatalf("UpdateStatefulSet() error = %v", err)
+	}
+	var deleted []string
+	for _, action := range kubeClient.Actions() {
+		switch {
+		case action.GetResource().Resource == "persistentvolumeclaims" && action.GetVerb() != "get" && action.GetVerb() != "list":
+			t.Errorf("expected the claims of suspended pods to be left alone, got %s", action.GetVerb())
+		case action.GetResource().Resource == "pods" && action.GetVerb() == "delete":
+			deleted = append(deleted, action.(k8stesting.DeleteAction).GetName())
+		}
+	}
+	sort.Strings(deleted)
+	if want := []string{"web-0", "web-1", "web-2"}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("expected suspending to delete %v, got %v", want, deleted)
+	}
+	if replicas := *set.Spec.Replicas; replicas != 3 {
+		t.Errorf("expected suspending to leave the replicas at 3, got %d", replicas)
+	}
+
+	// resuming restores the same ordinals, with the claims they had
+	kubeClient.ClearActions()
+	set.Spec.Suspend = nil
+	if _, err := ssc.UpdateStatefulSet(ctx, set, nil); err != nil {
+		t.Fatalf("UpdateStatefulSet() error = %v", err)
+	}
+	var created []string
+	for _, action := range kubeClient.Actions() {
+		switch {
+		case action.GetResource().Resource == "persistentvolumeclaims" && action.GetVerb() == "create":
+			t.Errorf("expected the claims of the suspended pods to be reused, got a new claim")
+		case action.GetResource().Resource == "pods" && action.GetVerb() == "create":
+			created = append(created, action.(k8stesting.CreateAction).GetObject().(*v1.Pod).Name)
+		}
+	}
+	sort.Strings(created)
+	if want := []string{"web-0", "web-1", "web-2"}; !reflect.DeepEqual(created, want) {
+		t.Errorf("expected resuming to create %v, got %v", want, created)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+)
+
+// gatedDeletion is the operation of the throttledError returned for a Pod deletion that a DeletionGate delays.
+const gatedDeletion = "gated pod deletion"
+
+// PodMutator mutates the Pods the controller constructs from the template of a StatefulSet before they are created.
+type PodMutator interface {
+	// MutatePod mutates pod, a Pod of set that has not been created yet. If it returns an error the Pod is not
+	// created and the sync of set is retried.
+	MutatePod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error
+}
+
+// DeletionDecision is the decision of a DeletionGate on the deletion of a Pod.
+type DeletionDecision struct {
+	// Allowed is true if the Pod may be deleted.
+	Allowed bool
+	// RetryAfter is the delay after which a deletion that is not allowed is reconsidered. If it is 0 the deletion is
+	// reconsidered on the next sync of the StatefulSet.
+	RetryAfter time.Duration
+	// Reason explains why the deletion is not allowed.
+	Reason string
+}
+
+// DeletionGate vetoes or delays the deletions of Pods the controller issues to scale down, update or replace the
+// Pods of a StatefulSet.
+type DeletionGate interface {
+	// AllowDeletion decides whether pod of set may be deleted. If it returns an error the Pod is not deleted and the
+	// sync of set is retried.
+	AllowDeletion(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) (DeletionDecision, error)
+}
+
+// UpdateTargetSelector chooses the order in which the Pods of a StatefulSet are updated by a rolling update.
+type UpdateTargetSelector interface {
+	// SelectUpdateTargets returns the Pods of candidates to consider for the update, in the order they are to be
+	// updated. candidates are the replicas of set from the partition of the rolling update on, in descending ordinal
+	// order, whether they are at the update revision or not. Pods that are left out are not updated by the current
+	// sync of set. Pods that are not candidates, and candidates that are returned more than once, are ignored.
+	SelectUpdateTargets(ctx context.Context, set *xstsappv1.XStatefulSet, candidates []*v1.Pod) []*v1.Pod
+}
+
+// StatusContributor contributes to the status of a StatefulSet, e.g. with conditions of its own.
+type StatusContributor interface {
+	// ContributeStatus updates status, the status computed for set from its pods by the current sync, before it is
+	// written. The conditions of status are a copy of the conditions of set, so a contributor updates or removes the
+	// conditions it set in previous syncs instead of appending them again. If it returns an error the sync of set is
+	// retried.
+	ContributeStatus(ctx context.Context, set *xstsappv1.XStatefulSet, pods []*v1.Pod, status *xstsappv1.XStatefulSetStatus) error
+}
+
+// Extensions is a registry of the extensions that the controller calls while syncing StatefulSets, so that site
+// specific logic can be plugged into it. Extensions of the same kind are called in the order they were registered.
+// Extensions must be registered before the controller is run. A nil Extensions has no extensions.
+//
+// Extensions are also called by syncs that do not write Pods, so that a Plan reflects their decisions. Extensions
+// with side effects of their own, such as reserving resources in MutatePod, should check IsDryRun and skip them.
+type Extensions struct {
+	podMutators           []PodMutator
+	deletionGates         []DeletionGate
+	updateTargetSelectors []UpdateTargetSelector
+	statusContributors    []StatusContributor
+}
+
+// NewExtensions returns an empty registry of extensions.
+func NewExtensions() *Extensions {
+	return &Extensions{}
+}
+
+// RegisterPodMutator registers mutator to mutate the Pods the controller creates.
+func (e *Extensions) RegisterPodMutator(mutator PodMutator) *Extensions {
+	e.podMutators = append(e.podMutators, mutator)
+	return e
+}
+
+// RegisterDeletionGate registers gate to decide on the Pod deletions of the controller. A Pod is only deleted if all
+// gates allow it.
+func (e *Extensions) RegisterDeletionGate(gate DeletionGate) *Extensions {
+	e.deletionGates = append(e.deletionGates, gate)
+	return e
+}
+
+// RegisterUpdateTargetSelector registers selector to choose the update targets of rolling updates. Each selector
+// selects from the targets the previous one returned.
+func (e *Extensions) RegisterUpdateTargetSelector(selector UpdateTargetSelector) *Extensions {
+	e.updateTargetSelectors = append(e.updateTargetSelectors, selector)
+	return e
+}
+
+// RegisterStatusContributor registers contributor to contribute to the status of StatefulSets.
+func (e *Extensions) RegisterStatusContributor(contributor StatusContributor) *Extensions {
+	e.statusContributors = append(e.statusContributors, contributor)
+	return e
+}
+
+type dryRunKey struct{}
+
+// withDryRun returns a copy of ctx for a sync that does not write Pods.
+func withDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// IsDryRun returns true if ctx is the context of a sync that does not write Pods or PersistentVolumeClaims, e.g. of
+// PlanStatefulSet, of the controller in dry-run mode, or of a sync that only updates the status while the Pod writes
+// of the previous sync are not observed yet. The status is only written by the latter.
+func IsDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	return dryRun
+}
+
+// mutatePod mutates pod with the registered PodMutators.
+func (e *Extensions) mutatePod(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) error {
+	if e == nil {
+		return nil
+	}
+	for _, mutator := range e.podMutators {
+		if err := mutator.MutatePod(ctx, set, pod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowDeletion combines the decisions of the registered DeletionGates on the deletion of pod. A deletion that is not
+// allowed is reconsidered after the longest delay of the gates that did not allow it.
+func (e *Extensions) allowDeletion(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) (DeletionDecision, error) {
+	decision := DeletionDecision{Allowed: true}
+	if e == nil {
+		return decision, nil
+	}
+	for _, gate := range e.deletionGates {
+		gateDecision, err := gate.AllowDeletion(ctx, set, pod)
+		if err != nil {
+			return DeletionDecision{}, err
+		}
+		if gateDecision.Allowed {
+			continue
+		}
+		if decision.Allowed {
+			decision = gateDecision
+		} else if gateDecision.RetryAfter > decision.RetryAfter {
+			decision.RetryAfter = gateDecision.RetryAfter
+		}
+	}
+	return decision, nil
+}
+
+// selectUpdateTargets orders candidates with the registered UpdateTargetSelectors.
+func (e *Extensions) selectUpdateTargets(ctx context.Context, set *xstsappv1.XStatefulSet, candidates []*v1.Pod) []*v1.Pod {
+	if e == nil {
+		return candidates
+	}
+	for _, selector := range e.updateTargetSelectors {
+		candidates = selector.SelectUpdateTargets(ctx, set, candidates)
+	}
+	return candidates
+}
+
+// contributeStatus updates status with the registered StatusContributors.
+func (e *Extensions) contributeStatus(ctx context.Context, set *xstsappv1.XStatefulSet, pods []*v1.Pod, status *xstsappv1.XStatefulSetStatus) error {
+	if e == nil {
+		return nil
+	}
+	for _, contributor := range e.statusContributors {
+		if err := contributor.ContributeStatus(ctx, set, pods, status); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deletionGated returns true if the DeletionGates of the controller hold the deletion of pod back. A deletion that
+// is delayed is returned as a throttledError, so that set is synced again once it may be reconsidered.
+func (ssc *defaultStatefulSetControl) deletionGated(ctx context.Context, set *xstsappv1.XStatefulSet, pod *v1.Pod) (bool, error) {
+	decision, err := ssc.extensions.allowDeletion(ctx, set, pod)
+	if err != nil {
+		return true, err
+	}
+	if decision.Allowed {
+		return false, nil
+	}
+	klog.FromContext(ctx).V(4).Info("StatefulSet is waiting for a deletion gate to allow deleting Pod",
+		"statefulSet", klog.KObj(set), "pod", klog.KObj(pod), "reason", decision.Reason, "retryAfter", decision.RetryAfter)
+	ssc.recordSyncBlocked(set, blockedDeletionGated)
+	if decision.RetryAfter > 0 {
+		return true, &throttledError{operation: gatedDeletion, after: decision.RetryAfter}
+	}
+	return true, nil
+}
+
+// updateTargets returns the replicas from the partition updateMin on in the order the rolling update of set
+// considers them, descending ordinal order unless the UpdateTargetSelectors of the controller choose otherwise. The
+// selection is restricted to the candidates, so that a selector can not update Pods below the partition or Pods the
+// controller does not own.
+func (ssc *defaultStatefulSetControl) updateTargets(ctx context.Context, set *xstsappv1.XStatefulSet, replicas []*v1.Pod, updateMin int) []*v1.Pod {
+	candidates := make([]*v1.Pod, 0, max(len(replicas)-updateMin, 0))
+	for target := len(replicas) - 1; target >= updateMin; target-- {
+		candidates = append(candidates, replicas[target])
+	}
+	selected := ssc.extensions.selectUpdateTargets(ctx, set, candidates)
+	pending := make(map[string]*v1.Pod, len(candidates))
+	for _, candidate := range candidates {
+		pending[candidate.Name] = candidate
+	}
+	targets := make([]*v1.Pod, 0, len(selected))
+	for _, pod := range selected {
+		candidate, ok := pending[pod.Name]
+		if !ok {
+			continue
+		}
+		delete(pending, pod.Name)
+		targets = append(targets, candidate)
+	}
+	return targets
+}
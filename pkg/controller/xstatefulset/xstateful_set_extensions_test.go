@@ -0,0 +1,86 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeDeletionGate DeletionDecision
+
+func (g fakeDeletionGate) AllowDeletion(context.Context, *xstsappv1.XStatefulSet, *v1.Pod) (DeletionDecision, error) {
+	return DeletionDecision(g), nil
+}
+
+type ascendingUpdateTargets struct{}
+
+func (ascendingUpdateTargets) SelectUpdateTargets(_ context.Context, _ *xstsappv1.XStatefulSet, candidates []*v1.Pod) []*v1.Pod {
+	targets := make([]*v1.Pod, 0, len(candidates))
+	for i := len(candidates) - 1; i >= 0; i-- {
+		targets = append(targets, candidates[i])
+	}
+	return targets
+}
+
+func TestExtensions(t *testing.T) {
+	ctx := context.Background()
+	set := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	replicas := []*v1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-0"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-2"}},
+	}
+	podNames := func(pods []*v1.Pod) []string {
+		names := make([]string, 0, len(pods))
+		for _, pod := range pods {
+			names = append(names, pod.Name)
+		}
+		return names
+	}
+
+	ssc := &defaultStatefulSetControl{podControl: &StatefulPodControl{}}
+	if gated, err := ssc.deletionGated(ctx, set, replicas[0]); gated || err != nil {
+		t.Errorf("expected deletions to be allowed without extensions, got %v, %v", gated, err)
+	}
+	if targets := podNames(ssc.updateTargets(ctx, set, replicas, 1)); len(targets) != 2 || targets[0] != "web-2" || targets[1] != "web-1" {
+		t.Errorf("expected the targets from the partition on in descending ordinal order, got %v", targets)
+	}
+
+	ssc.extensions = NewExtensions().
+		RegisterDeletionGate(fakeDeletionGate{Allowed: true}).
+		RegisterDeletionGate(fakeDeletionGate{RetryAfter: time.Second, Reason: "license"}).
+		RegisterDeletionGate(fakeDeletionGate{RetryAfter: time.Minute, Reason: "maintenance"}).
+		RegisterUpdateTargetSelector(ascendingUpdateTargets{})
+	gated, err := ssc.deletionGated(ctx, set, replicas[0])
+	if after, throttled := throttledAfter(err); !gated || !throttled || after != time.Minute {
+		t.Errorf("expected the deletion to be delayed by the longest delay of the gates, got %v, %v", gated, err)
+	}
+	if targets := podNames(ssc.updateTargets(ctx, set, replicas, 0)); len(targets) != 3 || targets[0] != "web-0" || targets[2] != "web-2" {
+		t.Errorf("expected the selector to order the targets, got %v", targets)
+	}
+
+	ssc.extensions = NewExtensions().RegisterDeletionGate(fakeDeletionGate{Reason: "veto"})
+	if gated, err := ssc.deletionGated(ctx, set, replicas[0]); !gated || err != nil {
+		t.Errorf("expected the deletion to be vetoed until the next sync, got %v, %v", gated, err)
+	}
+}
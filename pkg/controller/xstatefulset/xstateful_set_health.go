@@ -0,0 +1,75 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// cacheSyncs returns the functions that report whether the informers of the controller have synced.
+func (ssc *StatefulSetController) cacheSyncs() []cache.InformerSynced {
+	synced := []cache.InformerSynced{
+		ssc.podListerSynced,
+		ssc.setListerSynced,
+		ssc.pvcListerSynced,
+		ssc.revListerSynced,
+		ssc.namespaceFilter.HasSynced,
+	}
+	// Nodes are only watched if Pods on unreachable Nodes may be force deleted
+	if ssc.nodeListerSynced != nil {
+		synced = append(synced, ssc.nodeListerSynced)
+	}
+	// ConfigMaps and Secrets are only watched if StatefulSets roll out on their changes
+	if ssc.configMapListerSynced != nil {
+		synced = append(synced, ssc.configMapListerSynced, ssc.secretListerSynced)
+	}
+	return synced
+}
+
+// CheckReady returns an error until the informers of the controller have synced and its workers have been started.
+func (ssc *StatefulSetController) CheckReady() error {
+	for _, synced := range ssc.cacheSyncs() {
+		if !synced() {
+			return fmt.Errorf("informers have not synced")
+		}
+	}
+	if !ssc.workersStarted.Load() {
+		return fmt.Errorf("workers have not been started")
+	}
+	return nil
+}
+
+// CheckHealth returns an error if the workers of the controller have stopped, or if none of them took a key from
+// the queue for longer than stallTimeout while keys were waiting to be synced. An idle controller with an empty
+// queue is healthy.
+func (ssc *StatefulSetController) CheckHealth(stallTimeout time.Duration) error {
+	if !ssc.workersStarted.Load() {
+		return nil
+	}
+	if ssc.runningWorkers.Load() == 0 {
+		return fmt.Errorf("no workers are running")
+	}
+	if queued := ssc.queue.Len(); queued > 0 {
+		if idle := ssc.clock.Since(time.Unix(0, ssc.lastDequeueTime.Load())); idle > stallTimeout {
+			return fmt.Errorf("no key has been taken from the queue for %v, %d keys are waiting", idle.Round(time.Second), queued)
+		}
+	}
+	return nil
+}
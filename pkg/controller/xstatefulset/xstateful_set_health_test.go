@@ -0,0 +1,90 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestCheckHealth(t *testing.T) {
+	tests := []struct {
+		name        string
+		started     bool
+		workers     int32
+		queued      []string
+		lastDequeue time.Duration
+		expectErr   bool
+	}{
+		{
+			name:    "workers not started yet",
+			started: false,
+			queued:  []string{"default/web"},
+		},
+		{
+			name:      "no workers running",
+			started:   true,
+			expectErr: true,
+		},
+		{
+			name:        "idle with an empty queue",
+			started:     true,
+			workers:     2,
+			lastDequeue: time.Hour,
+		},
+		{
+			name:        "making progress",
+			started:     true,
+			workers:     2,
+			queued:      []string{"default/web"},
+			lastDequeue: time.Second,
+		},
+		{
+			name:        "stalled",
+			started:     true,
+			workers:     2,
+			queued:      []string{"default/web", "default/db"},
+			lastDequeue: 10 * time.Minute,
+			expectErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the controller clock is far from the wall clock, so that only it can tell the workers are making progress
+			fakeClock := testingclock.NewFakePassiveClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			ssc := &StatefulSetController{
+				queue: workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()),
+				clock: fakeClock,
+			}
+			defer ssc.queue.ShutDown()
+			for _, key := range tt.queued {
+				ssc.queue.Add(key)
+			}
+			ssc.workersStarted.Store(tt.started)
+			ssc.runningWorkers.Store(tt.workers)
+			ssc.lastDequeueTime.Store(fakeClock.Now().Add(-tt.lastDequeue).UnixNano())
+
+			if err := ssc.CheckHealth(5 * time.Minute); (err != nil) != tt.expectErr {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"strings"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+var conditionStatuses = []v1.ConditionStatus{v1.ConditionTrue, v1.ConditionFalse, v1.ConditionUnknown}
+
+var podManagementPolicies = []appsv1.PodManagementPolicyType{
+	appsv1.OrderedReadyPodManagement,
+	appsv1.ParallelPodManagement,
+	xstsappv1.BoundedParallelPodManagement,
+}
+
+// Reasons a sync of a StatefulSet waits before making further progress, recorded by the SyncBlocked metric.
+const (
+	// blockedInFlightPods means the pod operations a sync may have in flight are exhausted.
+	blockedInFlightPods = "in_flight_pods"
+	// blockedStaleClaim means a pod cannot be created because its PersistentVolumeClaim still belongs to a
+	// previous pod.
+	blockedStaleClaim = "stale_claim"
+	// blockedTerminating means a pod has to finish terminating first.
+	blockedTerminating = "terminating"
+	// blockedNotReady means a pod has to be Running and Ready first.
+	blockedNotReady = "not_ready"
+	// blockedNotAvailable means a pod has to be available for .spec.minReadySeconds first.
+	blockedNotAvailable = "not_available"
+	// blockedRateLimited means the pod and claim operations of the set exceed the limits of the controller.
+	blockedRateLimited = "rate_limited"
+	// blockedDeletionGated means a DeletionGate vetoes or delays the deletion of a pod.
+	blockedDeletionGated = "deletion_gated"
+	// blockedMaxUnavailable means a rolling update cannot take down more pods than .spec.updateStrategy.rollingUpdate.maxUnavailable.
+	blockedMaxUnavailable = "max_unavailable"
+)
+
+var blockedReasons = []string{
+	blockedInFlightPods,
+	blockedStaleClaim,
+	blockedTerminating,
+	blockedNotReady,
+	blockedNotAvailable,
+	blockedMaxUnavailable,
+	blockedRateLimited,
+	blockedDeletionGated,
+}
+
+// operationResult returns the value of the result label for an operation that returned err.
+func operationResult(err error) string {
+	if err != nil {
+		return metrics.ResultError
+	}
+	return metrics.ResultSuccess
+}
+
+// recordSyncBlocked records that the sync of set waits for reason before making further progress.
+func recordSyncBlocked(set *xstsappv1.XStatefulSet, reason string) {
+	metrics.SyncBlocked.WithLabelValues(set.Namespace, set.Name, reason).Inc()
+}
+
+// recordSyncBlocked records that the sync of set waits for reason, unless the sync is only planned.
+func (ssc *defaultStatefulSetControl) recordSyncBlocked(set *xstsappv1.XStatefulSet, reason string) {
+	if ssc.podControl.dryRun {
+		return
+	}
+	recordSyncBlocked(set, reason)
+}
+
+// updateStatusMetrics records the per set metrics of set from status, the status computed for set by the current
+// sync. Series of the revisions that set's Status was at before status, and of the pod management policies set had
+// before, are removed.
+func updateStatusMetrics(set *xstsappv1.XStatefulSet, status *xstsappv1.XStatefulSetStatus, maxUnavailable int) {
+	namespace, name := set.Namespace, set.Name
+	podManagementPolicy := string(set.Spec.PodManagementPolicy)
+	replicas := int32(0)
+	if set.Spec.Replicas != nil {
+		replicas = *set.Spec.Replicas
+	}
+
+	metrics.Replicas.WithLabelValues(namespace, name).Set(float64(replicas))
+	metrics.StatusReplicas.WithLabelValues(namespace, name).Set(float64(status.Replicas))
+	metrics.StatusCurrentReplicas.WithLabelValues(namespace, name).Set(float64(status.CurrentReplicas))
+	metrics.StatusReadyReplicas.WithLabelValues(namespace, name).Set(float64(status.ReadyReplicas))
+	metrics.StatusAvailableReplicas.WithLabelValues(namespace, name).Set(float64(status.AvailableReplicas))
+	metrics.StatusUpdatedReplicas.WithLabelValues(namespace, name).Set(float64(status.UpdatedReplicas))
+	metrics.MaxUnavailable.WithLabelValues(namespace, name, podManagementPolicy).Set(float64(maxUnavailable))
+	for _, policy := range podManagementPolicies {
+		if string(policy) != podManagementPolicy {
+			metrics.MaxUnavailable.Delete(policyLabels(namespace, name, string(policy)))
+			metrics.UnavailableReplicas.Delete(policyLabels(namespace, name, string(policy)))
+		}
+	}
+
+	if set.Status.CurrentRevision != status.CurrentRevision {
+		metrics.StatusCurrentRevision.Delete(revisionLabels(namespace, name, set.Status.CurrentRevision))
+	}
+	if status.CurrentRevision != "" {
+		metrics.StatusCurrentRevision.WithLabelValues(namespace, name, status.CurrentRevision).Set(1)
+	}
+	if set.Status.UpdateRevision != status.UpdateRevision {
+		metrics.StatusUpdateRevision.Delete(revisionLabels(namespace, name, set.Status.UpdateRevision))
+	}
+	if status.UpdateRevision != "" {
+		metrics.StatusUpdateRevision.WithLabelValues(namespace, name, status.UpdateRevision).Set(1)
+	}
+
+	conditionTypes := sets.New[appsv1.StatefulSetConditionType]()
+	for _, condition := range status.Conditions {
+		conditionTypes.Insert(condition.Type)
+		for _, conditionStatus := range conditionStatuses {
+			value := 0.0
+			if condition.Status == conditionStatus {
+				value = 1
+			}
+			metrics.StatusCondition.WithLabelValues(namespace, name, string(condition.Type), strings.ToLower(string(conditionStatus))).Set(value)
+		}
+	}
+	for _, condition := range set.Status.Conditions {
+		if !conditionTypes.Has(condition.Type) {
+			deleteConditionMetrics(namespace, name, condition.Type)
+		}
+	}
+}
+
+// updateUnavailableReplicasMetric records the number of replicas of set, the Pods in its ordinal range, that are
+// missing or not available.
+func updateUnavailableReplicasMetric(set *xstsappv1.XStatefulSet, unavailable int) {
+	metrics.UnavailableReplicas.WithLabelValues(set.Namespace, set.Name, string(set.Spec.PodManagementPolicy)).Set(float64(unavailable))
+}
+
+// updateGenerationLagMetric records how many generations of set the controller has not observed yet.
+func updateGenerationLagMetric(set *xstsappv1.XStatefulSet) {
+	metrics.ObservedGenerationLag.WithLabelValues(set.Namespace, set.Name).Set(float64(max(set.Generation-set.Status.ObservedGeneration, 0)))
+}
+
+// deleteStatefulSetMetrics removes all series of the per set metrics of set, which has been deleted.
+func deleteStatefulSetMetrics(set *xstsappv1.XStatefulSet) {
+	namespace, name := set.Namespace, set.Name
+	labels := map[string]string{"statefulset_namespace": namespace, "statefulset_name": name}
+	metrics.Replicas.Delete(labels)
+	metrics.StatusReplicas.Delete(labels)
+	metrics.StatusCurrentReplicas.Delete(labels)
+	metrics.StatusReadyReplicas.Delete(labels)
+	metrics.StatusAvailableReplicas.Delete(labels)
+	metrics.StatusUpdatedReplicas.Delete(labels)
+	metrics.ObservedGenerationLag.Delete(labels)
+
+	for _, policy := range podManagementPolicies {
+		metrics.MaxUnavailable.Delete(policyLabels(namespace, name, string(policy)))
+		metrics.UnavailableReplicas.Delete(policyLabels(namespace, name, string(policy)))
+	}
+
+	metrics.StatusCurrentRevision.Delete(revisionLabels(namespace, name, set.Status.CurrentRevision))
+	metrics.StatusUpdateRevision.Delete(revisionLabels(namespace, name, set.Status.UpdateRevision))
+	for _, condition := range set.Status.Conditions {
+		deleteConditionMetrics(namespace, name, condition.Type)
+	}
+	for _, reason := range blockedReasons {
+		metrics.SyncBlocked.Delete(map[string]string{"statefulset_namespace": namespace, "statefulset_name": name, "reason": reason})
+	}
+}
+
+func policyLabels(namespace, name, policy string) map[string]string {
+	return map[string]string{"statefulset_namespace": namespace, "statefulset_name": name, "pod_management_policy": policy}
+}
+
+func revisionLabels(namespace, name, revision string) map[string]string {
+	return map[string]string{"statefulset_namespace": namespace, "statefulset_name": name, "revision": revision}
+}
+
+func deleteConditionMetrics(namespace, name string, conditionType appsv1.StatefulSetConditionType) {
+	for _, conditionStatus := range conditionStatuses {
+		metrics.StatusCondition.Delete(map[string]string{
+			"statefulset_namespace": namespace,
+			"statefulset_name":      name,
+			"condition":             string(conditionType),
+			"status":                strings.ToLower(string(conditionStatus)),
+		})
+	}
+}
@@ -0,0 +1,294 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	componentmetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/utils/clock"
+	"k8s.io/utils/ptr"
+)
+
+// newMetricsRegistry returns a registry with the metrics of the controller, without the series of other tests.
+func newMetricsRegistry() componentmetrics.KubeRegistry {
+	for _, gauge := range []*componentmetrics.GaugeVec{
+		metrics.MaxUnavailable,
+		metrics.UnavailableReplicas,
+		metrics.Replicas,
+		metrics.StatusReplicas,
+		metrics.StatusCurrentReplicas,
+		metrics.StatusReadyReplicas,
+		metrics.StatusAvailableReplicas,
+		metrics.StatusUpdatedReplicas,
+		metrics.StatusCurrentRevision,
+		metrics.StatusUpdateRevision,
+		metrics.StatusCondition,
+	} {
+		gauge.Reset()
+	}
+	for _, counter := range []*componentmetrics.CounterVec{metrics.PodOperations, metrics.ClaimOperations, metrics.SyncBlocked} {
+		counter.Reset()
+	}
+	metrics.SyncDuration.Reset()
+	registry := componentmetrics.NewKubeRegistry()
+	metrics.RegisterTo(registry)
+	return registry
+}
+
+var statusMetricNames = []string{
+	"statefulset_controller_statefulset_max_unavailable",
+	"statefulset_controller_statefulset_unavailable_replicas",
+	"statefulset_controller_statefulset_replicas",
+	"statefulset_controller_statefulset_status_available_replicas",
+	"statefulset_controller_statefulset_status_current_revision",
+	"statefulset_controller_statefulset_status_update_revision",
+	"statefulset_controller_statefulset_status_condition",
+}
+
+func TestStatusMetrics(t *testing.T) {
+	registry := newMetricsRegistry()
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas:            ptr.To[int32](3),
+			PodManagementPolicy: appsv1.OrderedReadyPodManagement,
+		},
+	}
+	status := &xstsappv1.XStatefulSetStatus{
+		Replicas:          3,
+		AvailableReplicas: 2,
+		CurrentRevision:   "web-1",
+		UpdateRevision:    "web-2",
+		Conditions:        []appsv1.StatefulSetCondition{{Type: "Paused", Status: v1.ConditionTrue}},
+	}
+	updateStatusMetrics(set, status, 1)
+	updateUnavailableReplicasMetric(set, 1)
+	expected := `
+# HELP statefulset_controller_statefulset_max_unavailable [ALPHA] Maximum number of unavailable pods allowed during StatefulSet rolling updates
+# TYPE statefulset_controller_statefulset_max_unavailable gauge
+statefulset_controller_statefulset_max_unavailable{pod_management_policy="OrderedReady",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_unavailable_replicas [ALPHA] Current number of unavailable pods in StatefulSet
+# TYPE statefulset_controller_statefulset_unavailable_replicas gauge
+statefulset_controller_statefulset_unavailable_replicas{pod_management_policy="OrderedReady",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_replicas [ALPHA] Desired number of replicas of the StatefulSet
+# TYPE statefulset_controller_statefulset_replicas gauge
+statefulset_controller_statefulset_replicas{statefulset_name="web",statefulset_namespace="default"} 3
+# HELP statefulset_controller_statefulset_status_available_replicas [ALPHA] Number of available pods of the StatefulSet
+# TYPE statefulset_controller_statefulset_status_available_replicas gauge
+statefulset_controller_statefulset_status_available_replicas{statefulset_name="web",statefulset_namespace="default"} 2
+# HELP statefulset_controller_statefulset_status_current_revision [ALPHA] Current revision of the StatefulSet, the value is always 1
+# TYPE statefulset_controller_statefulset_status_current_revision gauge
+statefulset_controller_statefulset_status_current_revision{revision="web-1",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_status_update_revision [ALPHA] Update revision of the StatefulSet, the value is always 1
+# TYPE statefulset_controller_statefulset_status_update_revision gauge
+statefulset_controller_statefulset_status_update_revision{revision="web-2",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_status_condition [ALPHA] Status of the conditions of the StatefulSet
+# TYPE statefulset_controller_statefulset_status_condition gauge
+statefulset_controller_statefulset_status_condition{condition="Paused",statefulset_name="web",statefulset_namespace="default",status="false"} 0
+statefulset_controller_statefulset_status_condition{condition="Paused",statefulset_name="web",statefulset_namespace="default",status="true"} 1
+statefulset_controller_statefulset_status_condition{condition="Paused",statefulset_name="web",statefulset_namespace="default",status="unknown"} 0
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), statusMetricNames...); err != nil {
+		t.Fatalf("after the first sync: %v", err)
+	}
+
+	// the rollout completes, the condition is cleared and the set becomes Parallel: the series of the previous
+	// revision, condition and policy are removed
+	set.Status = *status
+	set.Spec.PodManagementPolicy = appsv1.ParallelPodManagement
+	status = &xstsappv1.XStatefulSetStatus{
+		Replicas:          3,
+		AvailableReplicas: 3,
+		CurrentRevision:   "web-2",
+		UpdateRevision:    "web-2",
+	}
+	updateStatusMetrics(set, status, 1)
+	updateUnavailableReplicasMetric(set, 0)
+	expected = `
+# HELP statefulset_controller_statefulset_max_unavailable [ALPHA] Maximum number of unavailable pods allowed during StatefulSet rolling updates
+# TYPE statefulset_controller_statefulset_max_unavailable gauge
+statefulset_controller_statefulset_max_unavailable{pod_management_policy="Parallel",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_unavailable_replicas [ALPHA] Current number of unavailable pods in StatefulSet
+# TYPE statefulset_controller_statefulset_unavailable_replicas gauge
+statefulset_controller_statefulset_unavailable_replicas{pod_management_policy="Parallel",statefulset_name="web",statefulset_namespace="default"} 0
+# HELP statefulset_controller_statefulset_replicas [ALPHA] Desired number of replicas of the StatefulSet
+# TYPE statefulset_controller_statefulset_replicas gauge
+statefulset_controller_statefulset_replicas{statefulset_name="web",statefulset_namespace="default"} 3
+# HELP statefulset_controller_statefulset_status_available_replicas [ALPHA] Number of available pods of the StatefulSet
+# TYPE statefulset_controller_statefulset_status_available_replicas gauge
+statefulset_controller_statefulset_status_available_replicas{statefulset_name="web",statefulset_namespace="default"} 3
+# HELP statefulset_controller_statefulset_status_current_revision [ALPHA] Current revision of the StatefulSet, the value is always 1
+# TYPE statefulset_controller_statefulset_status_current_revision gauge
+statefulset_controller_statefulset_status_current_revision{revision="web-2",statefulset_name="web",statefulset_namespace="default"} 1
+# HELP statefulset_controller_statefulset_status_update_revision [ALPHA] Update revision of the StatefulSet, the value is always 1
+# TYPE statefulset_controller_statefulset_status_update_revision gauge
+statefulset_controller_statefulset_status_update_revision{revision="web-2",statefulset_name="web",statefulset_namespace="default"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), statusMetricNames...); err != nil {
+		t.Fatalf("after the rollout: %v", err)
+	}
+
+	set.Status = *status
+	deleteStatefulSetMetrics(set)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(""), statusMetricNames...); err != nil {
+		t.Fatalf("after the deletion: %v", err)
+	}
+}
+
+func TestSyncDurationMetric(t *testing.T) {
+	newMetricsRegistry()
+	ctx := context.Background()
+	kubeClient := fake.NewClientset()
+	xstatefulsetClient := xstatefulsetfake.NewClientset()
+	ssc := NewController(ctx, kubeClient, xstatefulsetClient,
+		informers.NewSharedInformerFactory(kubeClient, 0),
+		xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0),
+		WithEventRecorder(record.NewFakeRecorder(10)),
+		WithMetricsRegistry(componentmetrics.NewKubeRegistry()))
+	defer ssc.queue.ShutDown()
+
+	// a deleted set is synced successfully, a malformed key fails
+	ssc.queue.Add("default/deleted")
+	ssc.queue.Add("default/malformed/key")
+	for range 2 {
+		ssc.processNextWorkItem(ctx)
+	}
+	for result, want := range map[string]uint64{metrics.ResultSuccess: 1, metrics.ResultError: 1} {
+		count, err := testutil.GetHistogramMetricCount(metrics.SyncDuration.WithLabelValues(result))
+		if err != nil {
+			t.Fatalf("GetHistogramMetricCount() error = %v", err)
+		}
+		if count != want {
+			t.Errorf("expected %d syncs with result %s, got %d", want, result, count)
+		}
+	}
+}
+
+func TestOperationMetrics(t *testing.T) {
+	registry := newMetricsRegistry()
+	ctx := context.Background()
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas: ptr.To[int32](2),
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+	}
+	client := fake.NewClientset()
+	spc := &StatefulPodControl{
+		objectMgr: &realStatefulPodControlObjectManager{
+			client:      client,
+			claimLister: corelisters.NewPersistentVolumeClaimLister(cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})),
+		},
+		recorder: record.NewFakeRecorder(10),
+		limiter:  newPodOperationLimiter(PodOperationLimits{PodDeletionsPerMinute: 1, Burst: 1}, clock.RealClock{}),
+	}
+
+	web0 := newStatefulSetPod(set, 0)
+	if err := spc.CreateStatefulPod(ctx, set, web0); err != nil {
+		t.Fatalf("CreateStatefulPod() error = %v", err)
+	}
+	client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("unavailable")
+	})
+	if err := spc.CreateStatefulPod(ctx, set, newStatefulSetPod(set, 1)); err == nil {
+		t.Fatalf("expected the creation of the claim of web-1 to fail")
+	}
+	if err := spc.DeleteStatefulPod(ctx, set, web0); err != nil {
+		t.Fatalf("DeleteStatefulPod() error = %v", err)
+	}
+	// the second deletion is throttled, it is neither made nor counted
+	if _, throttled := throttledAfter(spc.DeleteStatefulPod(ctx, set, web0)); !throttled {
+		t.Fatalf("expected the second deletion to be throttled")
+	}
+
+	expected := `
+# HELP statefulset_controller_pod_operations_total [ALPHA] Number of pod create, update and delete requests made by the StatefulSet controller
+# TYPE statefulset_controller_pod_operations_total counter
+statefulset_controller_pod_operations_total{operation="create",result="error"} 1
+statefulset_controller_pod_operations_total{operation="create",result="success"} 1
+statefulset_controller_pod_operations_total{operation="delete",result="success"} 1
+# HELP statefulset_controller_pvc_operations_total [ALPHA] Number of PersistentVolumeClaim requests made by the StatefulSet controller
+# TYPE statefulset_controller_pvc_operations_total counter
+statefulset_controller_pvc_operations_total{operation="create",result="error"} 1
+statefulset_controller_pvc_operations_total{operation="create",result="success"} 1
+# HELP statefulset_controller_sync_blocked_total [ALPHA] Number of StatefulSet syncs that waited before making further progress, by reason
+# TYPE statefulset_controller_sync_blocked_total counter
+statefulset_controller_sync_blocked_total{reason="rate_limited",statefulset_name="web",statefulset_namespace="default"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"statefulset_controller_pod_operations_total",
+		"statefulset_controller_pvc_operations_total",
+		"statefulset_controller_sync_blocked_total"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyncBlockedMetric(t *testing.T) {
+	registry := newMetricsRegistry()
+	set := &xstsappv1.XStatefulSet{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"}}
+	ssc := &defaultStatefulSetControl{podControl: &StatefulPodControl{}}
+	planner := &defaultStatefulSetControl{podControl: &StatefulPodControl{dryRun: true}}
+	var expected strings.Builder
+	expected.WriteString(`
+# HELP statefulset_controller_sync_blocked_total [ALPHA] Number of StatefulSet syncs that waited before making further progress, by reason
+# TYPE statefulset_controller_sync_blocked_total counter
+`)
+	reasons := append([]string(nil), blockedReasons...)
+	sort.Strings(reasons)
+	for i, reason := range reasons {
+		for range i + 1 {
+			ssc.recordSyncBlocked(set, reason)
+		}
+		// planned syncs are not counted
+		planner.recordSyncBlocked(set, reason)
+		fmt.Fprintf(&expected, "statefulset_controller_sync_blocked_total{reason=%q,statefulset_name=\"web\",statefulset_namespace=\"default\"} %d\n", reason, i+1)
+	}
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected.String()), "statefulset_controller_sync_blocked_total"); err != nil {
+		t.Fatalf("blocked syncs: %v", err)
+	}
+
+	deleteStatefulSetMetrics(set)
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(""), "statefulset_controller_sync_blocked_total"); err != nil {
+		t.Errorf("after the deletion: %v", err)
+	}
+}
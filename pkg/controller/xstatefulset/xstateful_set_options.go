@@ -0,0 +1,194 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+
+	kthenaclientset "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/sharding"
+	"k8s.io/client-go/informers"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/component-base/metrics"
+	"k8s.io/utils/clock"
+)
+
+// Option configures a StatefulSetController created by NewController.
+type Option func(*controllerOptions)
+
+// controllerOptions are the components of a StatefulSetController. Components that are nil are created from the
+// clients and informers of the controller.
+type controllerOptions struct {
+	recorder           record.EventRecorder
+	objectManager      StatefulPodControlObjectManager
+	statusUpdater      StatefulSetStatusUpdaterInterface
+	history            history.Interface
+	namespaceFilter    *controller.NamespaceFilter
+	sharder            *sharding.Sharder
+	queueOptions       controller.QueueOptions
+	podOperationLimits PodOperationLimits
+	metricsRegistry    metrics.KubeRegistry
+	extensions         *Extensions
+	clock              clock.PassiveClock
+	dryRun             bool
+	auditLog           *AuditLog
+}
+
+// WithEventRecorder records the events of the controller with recorder. By default the controller records them to
+// the API server itself.
+func WithEventRecorder(recorder record.EventRecorder) Option {
+	return func(o *controllerOptions) {
+		o.recorder = recorder
+	}
+}
+
+// WithPodControlObjectManager creates, updates and deletes Pods and PersistentVolumeClaims, and reads the objects
+// the controller depends on, with objectManager instead of the clients and listers of the controller.
+func WithPodControlObjectManager(objectManager StatefulPodControlObjectManager) Option {
+	return func(o *controllerOptions) {
+		o.objectManager = objectManager
+	}
+}
+
+// WithStatusUpdater updates the status of StatefulSets with statusUpdater.
+func WithStatusUpdater(statusUpdater StatefulSetStatusUpdaterInterface) Option {
+	return func(o *controllerOptions) {
+		o.statusUpdater = statusUpdater
+	}
+}
+
+// WithHistory manages the ControllerRevisions of StatefulSets with history.
+func WithHistory(history history.Interface) Option {
+	return func(o *controllerOptions) {
+		o.history = history
+	}
+}
+
+// WithNamespaceFilter only syncs the StatefulSets in the namespaces namespaceFilter watches.
+func WithNamespaceFilter(namespaceFilter *controller.NamespaceFilter) Option {
+	return func(o *controllerOptions) {
+		o.namespaceFilter = namespaceFilter
+	}
+}
+
+// WithSharder only syncs the StatefulSets of the shards this replica owns. The caller runs sharder.
+func WithSharder(sharder *sharding.Sharder) Option {
+	return func(o *controllerOptions) {
+		o.sharder = sharder
+	}
+}
+
+// WithQueueOptions configures the work queue of the controller. It defaults to controller.DefaultQueueOptions.
+func WithQueueOptions(queueOptions controller.QueueOptions) Option {
+	return func(o *controllerOptions) {
+		o.queueOptions = queueOptions
+	}
+}
+
+// WithRateLimiter retries the StatefulSets that failed to sync as rateLimiter allows.
+func WithRateLimiter(rateLimiter workqueue.TypedRateLimiter[string]) Option {
+	return func(o *controllerOptions) {
+		o.queueOptions.RateLimiter = rateLimiter
+	}
+}
+
+// WithPodOperationLimits limits the rate of the Pod and PersistentVolumeClaim operations of each StatefulSet.
+func WithPodOperationLimits(limits PodOperationLimits) Option {
+	return func(o *controllerOptions) {
+		o.podOperationLimits = limits
+	}
+}
+
+// WithMetricsRegistry registers the metrics of the controller with registry instead of the legacy registry.
+func WithMetricsRegistry(registry metrics.KubeRegistry) Option {
+	return func(o *controllerOptions) {
+		o.metricsRegistry = registry
+	}
+}
+
+// WithExtensions calls the extensions registered with extensions while syncing StatefulSets.
+func WithExtensions(extensions *Extensions) Option {
+	return func(o *controllerOptions) {
+		o.extensions = extensions
+	}
+}
+
+// WithClock evaluates the availability of Pods, force deletions, scaling schedules, rate limits and expectations at
+// the time of clock instead of the wall clock. If clock also implements clock.WithTicker and the queue options do not
+// set a clock, the retries of the work queue are timed by clock too.
+func WithClock(clock clock.PassiveClock) Option {
+	return func(o *controllerOptions) {
+		o.clock = clock
+	}
+}
+
+// WithDryRun logs the Pod, PersistentVolumeClaim, ControllerRevision and status writes that each sync would make,
+// including the adoption and release of Pods and ControllerRevisions, instead of making them.
+func WithDryRun() Option {
+	return func(o *controllerOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithAuditLog records every Pod, PersistentVolumeClaim, ControllerRevision and status write of the controller,
+// with the reason it was made and the sync it was made in, to auditLog.
+func WithAuditLog(auditLog *AuditLog) Option {
+	return func(o *controllerOptions) {
+		o.auditLog = auditLog
+	}
+}
+
+// NewController creates a xstatefulset controller that watches the objects it needs with the informers of the
+// given factories, so that it can be embedded in other controller managers. The caller starts the factories after
+// NewController returns, and runs the controller with Run. The cluster-scoped Nodes are only watched while the
+// UnreachableNodeForceDelete feature gate is enabled, and the ConfigMaps and Secrets only while the
+// RolloutOnConfigChange feature gate is enabled.
+func NewController(
+	ctx context.Context,
+	kubeClient clientset.Interface,
+	xstatefulsetClient kthenaclientset.Interface,
+	kubeInformers informers.SharedInformerFactory,
+	xstatefulsetInformers xstatefulsetinformers.SharedInformerFactory,
+	opts ...Option,
+) *StatefulSetController {
+	return newStatefulSetController(
+		ctx,
+		kubeInformers.Core().V1().Pods(),
+		xstatefulsetInformers.Apps().V1().XStatefulSets(),
+		kubeInformers.Core().V1().PersistentVolumeClaims(),
+		kubeInformers.Apps().V1().ControllerRevisions(),
+		kubeInformers.Core().V1().Nodes(),
+		kubeInformers.Core().V1().ConfigMaps(),
+		kubeInformers.Core().V1().Secrets(),
+		kubeClient,
+		xstatefulsetClient,
+		newControllerOptions(opts...))
+}
+
+// newControllerOptions returns the default components of a controller configured by opts.
+func newControllerOptions(opts ...Option) *controllerOptions {
+	o := &controllerOptions{queueOptions: controller.DefaultQueueOptions()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
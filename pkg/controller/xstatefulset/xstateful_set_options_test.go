@@ -0,0 +1,213 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
+	"github.com/xsts-sh/xstatefulset/pkg/feature"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	featuregatetesting "k8s.io/component-base/featuregate/testing"
+	componentmetrics "k8s.io/component-base/metrics"
+	"k8s.io/klog/v2"
+	testingclock "k8s.io/utils/clock/testing"
+)
+
+func TestNewController(t *testing.T) {
+	kubeClient := fake.NewClientset()
+	xstatefulsetClient := xstatefulsetfake.NewClientset()
+	recorder := record.NewFakeRecorder(10)
+	objectManager := &realStatefulPodControlObjectManager{client: kubeClient}
+	registry := componentmetrics.NewKubeRegistry()
+
+	ssc := NewController(context.Background(), kubeClient, xstatefulsetClient,
+		informers.NewSharedInformerFactory(kubeClient, 0),
+		xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0),
+		WithEventRecorder(recorder),
+		WithPodControlObjectManager(objectManager),
+		WithMetricsRegistry(registry))
+
+	if ssc.eventBroadcaster != nil {
+		t.Errorf("expected no event broadcaster with the recorder of the caller")
+	}
+	podControl := ssc.control.(*defaultStatefulSetControl).podControl
+	if podControl.objectMgr != objectManager || podControl.recorder != recorder {
+		t.Errorf("expected the pod control to use the object manager and recorder of the caller")
+	}
+	if podControl.limiter != nil {
+		t.Errorf("expected pod operations not to be limited by default")
+	}
+
+	metrics.SyncDuration.WithLabelValues(metrics.ResultSuccess).Observe(1)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	found := false
+	for _, family := range families {
+		found = found || strings.HasSuffix(family.GetName(), "sync_duration_seconds")
+	}
+	if !found {
+		t.Errorf("expected the metrics of the controller to be registered with the registry of the caller")
+	}
+}
+
+func TestNewStatefulSetController(t *testing.T) {
+	kubeClient := fake.NewClientset()
+	xstatefulsetClient := xstatefulsetfake.NewClientset()
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	xstatefulsetInformers := xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0)
+	limits := PodOperationLimits{PodCreationsPerMinute: 1, Burst: 1}
+
+	ssc := NewStatefulSetController(context.Background(),
+		kubeInformers.Core().V1().Pods(),
+		xstatefulsetInformers.Apps().V1().XStatefulSets(),
+		kubeInformers.Core().V1().PersistentVolumeClaims(),
+		kubeInformers.Apps().V1().ControllerRevisions(),
+		kubeInformers.Core().V1().Nodes(),
+		kubeInformers.Core().V1().ConfigMaps(),
+		kubeInformers.Core().V1().Secrets(),
+		kubeClient,
+		xstatefulsetClient,
+		nil,
+		nil,
+		controller.QueueOptions{},
+		limits)
+
+	if ssc.eventBroadcaster == nil {
+		t.Errorf("expected the controller to record events to the API server")
+	}
+	if ssc.podOperationLimiter == nil || ssc.podOperationLimiter.limits != limits {
+		t.Errorf("expected pod operations to be limited by %+v", limits)
+	}
+	if _, ok := ssc.control.(*defaultStatefulSetControl).podControl.objectMgr.(*realStatefulPodControlObjectManager); !ok {
+		t.Errorf("expected the pod control to use the clients and listers of the controller")
+	}
+}
+
+func TestConfigInformers(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("RolloutOnConfigChange=%v", enabled), func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, feature.RolloutOnConfigChange, enabled)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			kubeClient := fake.NewClientset()
+			xstatefulsetClient := xstatefulsetfake.NewClientset()
+			kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+			ssc := NewController(ctx, kubeClient, xstatefulsetClient, kubeInformers,
+				xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0),
+				WithEventRecorder(record.NewFakeRecorder(10)))
+
+			kubeInformers.Start(ctx.Done())
+			synced := kubeInformers.WaitForCacheSync(ctx.Done())
+			for _, obj := range []interface{}{&v1.ConfigMap{}, &v1.Secret{}} {
+				if _, started := synced[reflect.TypeOf(obj)]; started != enabled {
+					t.Errorf("expected the %T informer to be started %v, got %v", obj, enabled, started)
+				}
+			}
+			if waited := len(ssc.cacheSyncs()) == 8; waited != enabled {
+				t.Errorf("expected the controller to wait for the ConfigMap and Secret informers %v, got %v", enabled, waited)
+			}
+		})
+	}
+}
+
+func TestNodeInformer(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("UnreachableNodeForceDelete=%v", enabled), func(t *testing.T) {
+			featuregatetesting.SetFeatureGateDuringTest(t, utilfeature.DefaultFeatureGate, feature.UnreachableNodeForceDelete, enabled)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			kubeClient := fake.NewClientset()
+			xstatefulsetClient := xstatefulsetfake.NewClientset()
+			kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+			ssc := NewController(ctx, kubeClient, xstatefulsetClient, kubeInformers,
+				xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0),
+				WithEventRecorder(record.NewFakeRecorder(10)))
+
+			kubeInformers.Start(ctx.Done())
+			synced := kubeInformers.WaitForCacheSync(ctx.Done())
+			if _, started := synced[reflect.TypeOf(&v1.Node{})]; started != enabled {
+				t.Errorf("expected the Node informer to be started %v, got %v", enabled, started)
+			}
+			if waited := len(ssc.cacheSyncs()) == 6; waited != enabled {
+				t.Errorf("expected the controller to wait for the Node informer %v, got %v", enabled, waited)
+			}
+		})
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	kubeClient := fake.NewClientset()
+	xstatefulsetClient := xstatefulsetfake.NewClientset()
+	fakeClock := testingclock.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	ssc := NewController(context.Background(), kubeClient, xstatefulsetClient,
+		informers.NewSharedInformerFactory(kubeClient, 0),
+		xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0),
+		WithEventRecorder(record.NewFakeRecorder(10)),
+		WithMetricsRegistry(componentmetrics.NewKubeRegistry()),
+		WithPodOperationLimits(PodOperationLimits{PodCreationsPerMinute: 1, Burst: 1}),
+		WithClock(fakeClock))
+
+	if ssc.control.(*defaultStatefulSetControl).clock != fakeClock || ssc.podOperationLimiter.clock != fakeClock {
+		t.Errorf("expected the controller to use the clock of the caller")
+	}
+
+	pod := &v1.Pod{Status: v1.PodStatus{
+		Phase: v1.PodRunning,
+		Conditions: []v1.PodCondition{{
+			Type:               v1.PodReady,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(fakeClock.Now()),
+		}},
+	}}
+	if isRunningAndAvailable(pod, 10, ssc.clock.Now()) {
+		t.Errorf("expected the pod not to be available before minReadySeconds passed")
+	}
+	fakeClock.Step(10 * time.Second)
+	if !isRunningAndAvailable(pod, 10, ssc.clock.Now()) {
+		t.Errorf("expected the pod to be available once minReadySeconds passed")
+	}
+
+	logger := klog.Background()
+	const key = "default/web"
+	if err := ssc.expectations.ExpectDeletions(logger, key, []string{"web-0-uid"}); err != nil {
+		t.Fatalf("ExpectDeletions() error = %v", err)
+	}
+	if ssc.expectations.SatisfiedExpectations(logger, key) {
+		t.Fatalf("expected pending deletions not to satisfy the expectations")
+	}
+	fakeClock.Step(controller.ExpectationsTimeout + time.Second)
+	if !ssc.expectations.SatisfiedExpectations(logger, key) {
+		t.Errorf("expected the expectations to expire as timed by the clock")
+	}
+}
@@ -0,0 +1,310 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	"github.com/xsts-sh/xstatefulset/pkg/controller"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
+	apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+)
+
+// ActionType is the kind of a write that a sync of a StatefulSet makes.
+type ActionType string
+
+const (
+	ActionAdoptPod        ActionType = "AdoptPod"
+	ActionReleasePod      ActionType = "ReleasePod"
+	ActionCreatePod       ActionType = "CreatePod"
+	ActionUpdatePod       ActionType = "UpdatePod"
+	ActionDeletePod       ActionType = "DeletePod"
+	ActionForceDeletePod  ActionType = "ForceDeletePod"
+	ActionCreateClaim     ActionType = "CreateClaim"
+	ActionUpdateClaim     ActionType = "UpdateClaim"
+	ActionAdoptRevision   ActionType = "AdoptRevision"
+	ActionReleaseRevision ActionType = "ReleaseRevision"
+	ActionCreateRevision  ActionType = "CreateRevision"
+	ActionUpdateRevision  ActionType = "UpdateRevision"
+	ActionDeleteRevision  ActionType = "DeleteRevision"
+	ActionUpdateStatus    ActionType = "UpdateStatus"
+)
+
+// Action is a write that a sync of a StatefulSet makes to the object Namespace/Name.
+type Action struct {
+	Type      ActionType
+	Namespace string
+	Name      string
+}
+
+func (a Action) String() string {
+	return fmt.Sprintf("%s %s/%s", a.Type, a.Namespace, a.Name)
+}
+
+// Plan is the list of writes that a single sync of a StatefulSet makes, in the order it makes them. As none of them
+// is made, a plan covers the first step of the sync only, e.g. a single Pod creation for the OrderedReady policy.
+type Plan struct {
+	mu sync.Mutex
+	// Actions are the writes of the sync.
+	Actions []Action
+	// Status is the status the sync writes, it is nil if the status is left unchanged.
+	Status *xstsappv1.XStatefulSetStatus
+}
+
+// record appends an action to p. Pods are written concurrently unless the set is OrderedReady.
+func (p *Plan) record(actionType ActionType, namespace, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Actions = append(p.Actions, Action{Type: actionType, Namespace: namespace, Name: name})
+}
+
+// Deletions returns the actions of p that delete Pods or ControllerRevisions.
+func (p *Plan) Deletions() []Action {
+	var deletions []Action
+	for _, action := range p.Actions {
+		switch action.Type {
+		case ActionDeletePod, ActionForceDeletePod, ActionDeleteRevision:
+			deletions = append(deletions, action)
+		}
+	}
+	return deletions
+}
+
+func (p *Plan) String() string {
+	actions := make([]string, 0, len(p.Actions))
+	for _, action := range p.Actions {
+		actions = append(actions, action.String())
+	}
+	return strings.Join(actions, ", ")
+}
+
+// PlanStatefulSet returns the writes that a sync of set and its pods by control would make, without making them.
+// control must have been created by NewDefaultStatefulSetControl, its objects and history are only read. The
+// extensions of control are called with a context for which IsDryRun returns true. The returned plan holds the writes
+// planned before any error.
+func PlanStatefulSet(ctx context.Context, control StatefulSetControlInterface, set *xstsappv1.XStatefulSet, pods []*v1.Pod) (*Plan, error) {
+	ssc, ok := control.(*defaultStatefulSetControl)
+	if !ok {
+		return nil, fmt.Errorf("planning requires the default StatefulSet control, got %T", control)
+	}
+	plan := &Plan{}
+	_, err := ssc.planner(plan).UpdateStatefulSet(ctx, set, pods)
+	return plan, err
+}
+
+// planner returns a copy of ssc that records its writes to plan instead of making them. It neither records events
+// and metrics nor takes the tokens of rate limited operations.
+func (ssc *defaultStatefulSetControl) planner(plan *Plan) *defaultStatefulSetControl {
+	return &defaultStatefulSetControl{
+		podControl: &StatefulPodControl{
+			objectMgr: &planObjectManager{StatefulPodControlObjectManager: ssc.podControl.objectMgr, plan: plan},
+			// a FakeRecorder without a channel discards events
+			recorder: &record.FakeRecorder{},
+			dryRun:   true,
+		},
+		statusUpdater:         &planStatusUpdater{plan: plan},
+		controllerHistory:     &planHistory{Interface: ssc.controllerHistory, plan: plan},
+		extensions:            ssc.extensions,
+		clock:                 ssc.clock,
+		revisionEqualityCache: ssc.revisionEqualityCache,
+	}
+}
+
+// statusSyncer returns a copy of ssc that updates the status of StatefulSets and their ControllerRevisions, but
+// discards its writes to Pods and PersistentVolumeClaims. Like a planner, it neither records events and metrics nor
+// takes the tokens of rate limited operations.
+func (ssc *defaultStatefulSetControl) statusSyncer() *defaultStatefulSetControl {
+	return &defaultStatefulSetControl{
+		podControl: &StatefulPodControl{
+			objectMgr: &planObjectManager{StatefulPodControlObjectManager: ssc.podControl.objectMgr, plan: &Plan{}},
+			recorder:  &record.FakeRecorder{},
+			audit:     ssc.podControl.audit,
+			dryRun:    true,
+		},
+		statusUpdater:         ssc.statusUpdater,
+		controllerHistory:     ssc.controllerHistory,
+		extensions:            ssc.extensions,
+		clock:                 ssc.clock,
+		revisionEqualityCache: ssc.revisionEqualityCache,
+	}
+}
+
+// Plan returns the writes that the next sync of the StatefulSet namespace/name would make, including the adoption
+// and release of its Pods and ControllerRevisions, without making them.
+func (ssc *StatefulSetController) Plan(ctx context.Context, namespace, name string) (*Plan, error) {
+	set, err := ssc.setLister.XStatefulSets(namespace).Get(name)
+	if err != nil {
+		return nil, err
+	}
+	set = set.DeepCopy()
+	legacyscheme.Scheme.Default(set)
+	selector, err := metav1.LabelSelectorAsSelector(set.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	return ssc.plan(ctx, set, selector)
+}
+
+// plan returns the writes that a sync of set would make.
+func (ssc *StatefulSetController) plan(ctx context.Context, set *xstsappv1.XStatefulSet, selector labels.Selector) (*Plan, error) {
+	control, ok := ssc.control.(*defaultStatefulSetControl)
+	if !ok {
+		return nil, fmt.Errorf("planning requires the default StatefulSet control, got %T", ssc.control)
+	}
+	plan := &Plan{}
+	planner := control.planner(plan)
+	if err := ssc.adoptOrphanRevisions(ctx, set, planner); err != nil {
+		return plan, err
+	}
+	pods, err := ssc.getPodsForStatefulSet(ctx, set, selector, &planPodControl{plan: plan})
+	if err != nil {
+		return plan, err
+	}
+	_, err = planner.UpdateStatefulSet(ctx, set, pods)
+	return plan, err
+}
+
+// planObjectManager reads objects with the embedded StatefulPodControlObjectManager, and records the writes to plan.
+type planObjectManager struct {
+	StatefulPodControlObjectManager
+	plan *Plan
+}
+
+func (om *planObjectManager) CreatePod(ctx context.Context, pod *v1.Pod) error {
+	om.plan.record(ActionCreatePod, pod.Namespace, pod.Name)
+	return nil
+}
+
+func (om *planObjectManager) UpdatePod(ctx context.Context, pod *v1.Pod) error {
+	om.plan.record(ActionUpdatePod, pod.Namespace, pod.Name)
+	return nil
+}
+
+func (om *planObjectManager) DeletePod(ctx context.Context, pod *v1.Pod) error {
+	om.plan.record(ActionDeletePod, pod.Namespace, pod.Name)
+	return nil
+}
+
+func (om *planObjectManager) ForceDeletePod(ctx context.Context, pod *v1.Pod) error {
+	om.plan.record(ActionForceDeletePod, pod.Namespace, pod.Name)
+	return nil
+}
+
+func (om *planObjectManager) CreateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error {
+	om.plan.record(ActionCreateClaim, claim.Namespace, claim.Name)
+	return nil
+}
+
+func (om *planObjectManager) UpdateClaim(ctx context.Context, claim *v1.PersistentVolumeClaim) error {
+	om.plan.record(ActionUpdateClaim, claim.Namespace, claim.Name)
+	return nil
+}
+
+// planStatusUpdater records the status written to plan.
+type planStatusUpdater struct {
+	plan *Plan
+}
+
+func (su *planStatusUpdater) UpdateStatefulSetStatus(ctx context.Context, set *xstsappv1.XStatefulSet, status *xstsappv1.XStatefulSetStatus) error {
+	su.plan.record(ActionUpdateStatus, set.Namespace, set.Name)
+	su.plan.Status = status.DeepCopy()
+	return nil
+}
+
+// planHistory lists ControllerRevisions with the embedded history.Interface, and records the writes to plan. The
+// written revisions are returned as they would be stored.
+type planHistory struct {
+	history.Interface
+	plan *Plan
+}
+
+func (h *planHistory) CreateControllerRevision(parent metav1.Object, revision *apps.ControllerRevision, collisionCount *int32) (*apps.ControllerRevision, bool, error) {
+	if collisionCount == nil {
+		return nil, false, fmt.Errorf("collisionCount should not be nil")
+	}
+	clone := revision.DeepCopy()
+	clone.Namespace = parent.GetNamespace()
+	clone.Name = history.ControllerRevisionName(parent.GetName(), history.HashControllerRevision(revision, collisionCount))
+	h.plan.record(ActionCreateRevision, clone.Namespace, clone.Name)
+	return clone, true, nil
+}
+
+func (h *planHistory) DeleteControllerRevision(revision *apps.ControllerRevision) error {
+	h.plan.record(ActionDeleteRevision, revision.Namespace, revision.Name)
+	return nil
+}
+
+func (h *planHistory) UpdateControllerRevision(revision *apps.ControllerRevision, newRevision int64) (*apps.ControllerRevision, error) {
+	h.plan.record(ActionUpdateRevision, revision.Namespace, revision.Name)
+	clone := revision.DeepCopy()
+	clone.Revision = newRevision
+	return clone, nil
+}
+
+func (h *planHistory) AdoptControllerRevision(parent metav1.Object, parentKind schema.GroupVersionKind, revision *apps.ControllerRevision) (*apps.ControllerRevision, error) {
+	h.plan.record(ActionAdoptRevision, revision.Namespace, revision.Name)
+	clone := revision.DeepCopy()
+	clone.OwnerReferences = append(clone.OwnerReferences, *metav1.NewControllerRef(parent, parentKind))
+	return clone, nil
+}
+
+func (h *planHistory) ReleaseControllerRevision(parent metav1.Object, revision *apps.ControllerRevision) (*apps.ControllerRevision, error) {
+	h.plan.record(ActionReleaseRevision, revision.Namespace, revision.Name)
+	return revision.DeepCopy(), nil
+}
+
+// planPodControl records the adoptions and releases of Pods by a PodControllerRefManager to plan.
+type planPodControl struct {
+	plan *Plan
+}
+
+var _ controller.PodControlInterface = &planPodControl{}
+
+func (pc *planPodControl) CreatePods(ctx context.Context, namespace string, template *v1.PodTemplateSpec, object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	return fmt.Errorf("unexpected pod creation from a template while planning")
+}
+
+func (pc *planPodControl) CreatePodsWithGenerateName(ctx context.Context, namespace string, template *v1.PodTemplateSpec, object runtime.Object, controllerRef *metav1.OwnerReference, generateName string) error {
+	return fmt.Errorf("unexpected pod creation from a template while planning")
+}
+
+func (pc *planPodControl) DeletePod(ctx context.Context, namespace string, podID string, object runtime.Object) error {
+	pc.plan.record(ActionDeletePod, namespace, podID)
+	return nil
+}
+
+// PatchPod records an owner reference patch as the release of the Pod if it deletes the owner reference, and as its
+// adoption otherwise.
+func (pc *planPodControl) PatchPod(ctx context.Context, namespace, name string, data []byte) error {
+	if isReleasePatch(data) {
+		pc.plan.record(ActionReleasePod, namespace, name)
+	} else {
+		pc.plan.record(ActionAdoptPod, namespace, name)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	"github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset/metrics"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	componentmetrics "k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/testutil"
+	"k8s.io/utils/ptr"
+)
+
+// writes returns the actions that wrote objects.
+func writes(actions []k8stesting.Action) []k8stesting.Action {
+	var written []k8stesting.Action
+	for _, action := range actions {
+		switch action.GetVerb() {
+		case "create", "update", "patch", "delete":
+			written = append(written, action)
+		}
+	}
+	return written
+}
+
+func TestDryRunSync(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	labels := map[string]string{"app": "dry-run"}
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dry-run", UID: types.UID("dry-run")},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas: ptr.To[int32](2),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+	}
+	// dry-run-0 matches the selector but has no owner, the sync adopts it
+	orphan := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "dry-run-0", Labels: labels},
+		Status:     v1.PodStatus{Phase: v1.PodPending},
+	}
+	kubeClient := fake.NewClientset(orphan)
+	xstatefulsetClient := xstatefulsetfake.NewClientset(set)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	xstatefulsetInformers := xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0)
+	recorder := record.NewFakeRecorder(10)
+	ssc := NewController(ctx, kubeClient, xstatefulsetClient, kubeInformers, xstatefulsetInformers,
+		WithEventRecorder(recorder),
+		WithMetricsRegistry(componentmetrics.NewKubeRegistry()),
+		WithDryRun())
+	kubeInformers.Start(ctx.Done())
+	xstatefulsetInformers.Start(ctx.Done())
+	kubeInformers.WaitForCacheSync(ctx.Done())
+	xstatefulsetInformers.WaitForCacheSync(ctx.Done())
+
+	counters := func() []float64 {
+		var values []float64
+		for _, counter := range []componentmetrics.CounterMetric{
+			metrics.PodOperations.WithLabelValues("create", metrics.ResultSuccess),
+			metrics.PodOperations.WithLabelValues("update", metrics.ResultSuccess),
+			metrics.ClaimOperations.WithLabelValues("create", metrics.ResultSuccess),
+			metrics.ClaimOperations.WithLabelValues("update", metrics.ResultSuccess),
+			metrics.SyncBlocked.WithLabelValues(set.Namespace, set.Name, blockedNotReady),
+		} {
+			value, err := testutil.GetCounterMetricValue(counter)
+			if err != nil {
+				t.Fatalf("GetCounterMetricValue() error = %v", err)
+			}
+			values = append(values, value)
+		}
+		return values
+	}
+	before := counters()
+	kubeClient.ClearActions()
+	xstatefulsetClient.ClearActions()
+
+	if err := ssc.sync(ctx, "default/dry-run"); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if written := append(writes(kubeClient.Actions()), writes(xstatefulsetClient.Actions())...); len(written) > 0 {
+		t.Errorf("expected a dry-run sync not to write, got %v", written)
+	}
+	if after := counters(); !reflect.DeepEqual(before, after) {
+		t.Errorf("expected a dry-run sync not to count operations, got %v before and %v after", before, after)
+	}
+	if len(recorder.Events) > 0 {
+		t.Errorf("expected a dry-run sync not to record events, got %s", <-recorder.Events)
+	}
+
+	plan, err := ssc.Plan(ctx, "default", "dry-run")
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	adopted, created := false, false
+	for _, action := range plan.Actions {
+		adopted = adopted || action == Action{Type: ActionAdoptPod, Namespace: "default", Name: "dry-run-0"}
+		created = created || action == Action{Type: ActionCreatePod, Namespace: "default", Name: "dry-run-0"}
+	}
+	if !adopted || created {
+		t.Errorf("expected the plan to adopt dry-run-0 instead of creating it, got %v", plan)
+	}
+}
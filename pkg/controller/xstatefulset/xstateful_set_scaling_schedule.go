@@ -0,0 +1,123 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+// parseScalingSchedule parses the cron expression of schedule in its time zone.
+func parseScalingSchedule(schedule *xstsappv1.ScalingSchedule) (cron.Schedule, error) {
+	spec := schedule.Schedule
+	if schedule.TimeZone != nil {
+		if _, err := time.LoadLocation(*schedule.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time zone %q: %w", *schedule.TimeZone, err)
+		}
+		spec = fmt.Sprintf("CRON_TZ=%s %s", *schedule.TimeZone, spec)
+	}
+	return cron.ParseStandard(spec)
+}
+
+// getScalingScheduleStatus evaluates the scaling schedules of set at now. The returned status carries over the
+// schedule that fired last from set's status, unless another schedule fired since, and describes the next scheduled
+// change. Schedules that fail to parse are skipped and returned as errors. The status is nil if set has no schedules.
+func getScalingScheduleStatus(set *xstsappv1.XStatefulSet, now time.Time) (*xstsappv1.ScalingScheduleStatus, []error) {
+	if len(set.Spec.ScalingSchedules) == 0 {
+		return nil, nil
+	}
+	status := &xstsappv1.ScalingScheduleStatus{}
+	// schedules are only evaluated from the last time one fired, or from the creation of the set.
+	from := set.CreationTimestamp.Time
+	if last := set.Status.ScalingSchedule; last != nil && last.LastScheduleTime != nil {
+		status.ActiveSchedule = last.ActiveSchedule
+		status.LastScheduleTime = last.LastScheduleTime.DeepCopy()
+		from = last.LastScheduleTime.Time
+	}
+
+	var errs []error
+	for i := range set.Spec.ScalingSchedules {
+		schedule := &set.Spec.ScalingSchedules[i]
+		sched, err := parseScalingSchedule(schedule)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("scaling schedule %q: %w", schedule.Name, err))
+			continue
+		}
+
+		fired := lastScheduleTime(sched, from, now)
+		if !fired.IsZero() && (status.LastScheduleTime == nil || fired.After(status.LastScheduleTime.Time)) {
+			status.ActiveSchedule = schedule.Name
+			status.LastScheduleTime = &metav1.Time{Time: fired}
+		}
+
+		next := sched.Next(now)
+		if !next.IsZero() && (status.NextScheduleTime == nil || next.Before(status.NextScheduleTime.Time)) {
+			status.NextSchedule = schedule.Name
+			status.NextScheduleTime = &metav1.Time{Time: next}
+			status.NextReplicas = ptr.To(schedule.Replicas)
+		}
+	}
+	return status, errs
+}
+
+// lastScheduleTime returns the most recent time in (from, now] that sched fired at, or the zero time if it did not
+// fire. Instead of walking every fire time since from, which is slow for frequent schedules of old sets, the window
+// before now that is walked doubles until it holds a fire time.
+func lastScheduleTime(sched cron.Schedule, from, now time.Time) time.Time {
+	if !now.After(from) {
+		return time.Time{}
+	}
+	span := now.Sub(from)
+	for window := time.Minute; ; {
+		start := from
+		if window < span {
+			start = now.Add(-window)
+		}
+		var fired time.Time
+		for t := sched.Next(start); !t.IsZero() && !t.After(now); t = sched.Next(t) {
+			fired = t
+		}
+		if !fired.IsZero() || start.Equal(from) {
+			return fired
+		}
+		// the span is bounded by the largest duration, doubling the window must not overflow
+		if window > span/2 {
+			window = span
+		} else {
+			window *= 2
+		}
+	}
+}
+
+// getScheduledReplicas returns the replicas of the active schedule in status. The second return value is false if no
+// schedule has fired yet, or if the schedule that fired last is no longer part of set's spec.
+func getScheduledReplicas(set *xstsappv1.XStatefulSet, status *xstsappv1.ScalingScheduleStatus) (int32, bool) {
+	if status == nil || status.LastScheduleTime == nil {
+		return 0, false
+	}
+	for i := range set.Spec.ScalingSchedules {
+		if set.Spec.ScalingSchedules[i].Name == status.ActiveSchedule {
+			return set.Spec.ScalingSchedules[i].Replicas, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,158 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetScalingScheduleStatus(t *testing.T) {
+	created := metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	businessHours := []xstsappv1.ScalingSchedule{
+		{Name: "scale-up", Schedule: "0 8 * * *", TimeZone: ptr.To("UTC"), Replicas: 5},
+		{Name: "scale-down", Schedule: "0 20 * * *", TimeZone: ptr.To("UTC"), Replicas: 1},
+	}
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name            string
+		schedules       []xstsappv1.ScalingSchedule
+		lastStatus      *xstsappv1.ScalingScheduleStatus
+		now             time.Time
+		expectErrs      int
+		expectActive    string
+		expectReplicas  int32
+		expectScheduled bool
+		expectNext      string
+		expectNextTime  time.Time
+	}{
+		{
+			name:           "no schedule fired yet",
+			schedules:      businessHours,
+			now:            at(1, 6),
+			expectNext:     "scale-up",
+			expectNextTime: at(1, 8),
+		},
+		{
+			name:            "latest fired schedule is active",
+			schedules:       businessHours,
+			now:             at(3, 10),
+			expectActive:    "scale-up",
+			expectReplicas:  5,
+			expectScheduled: true,
+			expectNext:      "scale-down",
+			expectNextTime:  at(3, 20),
+		},
+		{
+			name:      "carries over the last fired schedule",
+			schedules: businessHours,
+			lastStatus: &xstsappv1.ScalingScheduleStatus{
+				ActiveSchedule:   "scale-down",
+				LastScheduleTime: ptr.To(metav1.NewTime(at(3, 20))),
+			},
+			now:             at(3, 22),
+			expectActive:    "scale-down",
+			expectReplicas:  1,
+			expectScheduled: true,
+			expectNext:      "scale-up",
+			expectNextTime:  at(4, 8),
+		},
+		{
+			name:      "removed schedule is not applied",
+			schedules: businessHours[:1],
+			lastStatus: &xstsappv1.ScalingScheduleStatus{
+				ActiveSchedule:   "scale-down",
+				LastScheduleTime: ptr.To(metav1.NewTime(at(3, 20))),
+			},
+			now:            at(3, 22),
+			expectActive:   "scale-down",
+			expectNext:     "scale-up",
+			expectNextTime: at(4, 8),
+		},
+		{
+			name: "frequent schedule of an old set fired last",
+			schedules: []xstsappv1.ScalingSchedule{
+				{Name: "every-minute", Schedule: "* * * * *", TimeZone: ptr.To("UTC"), Replicas: 3},
+				{Name: "daily", Schedule: "0 3 * * *", TimeZone: ptr.To("UTC"), Replicas: 1},
+			},
+			now:             at(31, 3).Add(10*time.Minute + 30*time.Second),
+			expectActive:    "every-minute",
+			expectReplicas:  3,
+			expectScheduled: true,
+			expectNext:      "every-minute",
+			expectNextTime:  at(31, 3).Add(11 * time.Minute),
+		},
+		{
+			name: "daily schedule of an old set fired after a frequent one",
+			schedules: []xstsappv1.ScalingSchedule{
+				{Name: "every-minute-at-one", Schedule: "* 1 * * *", TimeZone: ptr.To("UTC"), Replicas: 3},
+				{Name: "daily", Schedule: "0 3 * * *", TimeZone: ptr.To("UTC"), Replicas: 1},
+			},
+			now:             at(31, 3).Add(10 * time.Minute),
+			expectActive:    "daily",
+			expectReplicas:  1,
+			expectScheduled: true,
+			expectNext:      "every-minute-at-one",
+			expectNextTime:  time.Date(2024, 2, 1, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "invalid schedules are skipped",
+			schedules: append([]xstsappv1.ScalingSchedule{
+				{Name: "bad-cron", Schedule: "not a cron", Replicas: 3},
+				{Name: "bad-zone", Schedule: "0 * * * *", TimeZone: ptr.To("Nowhere/Nothing"), Replicas: 3},
+			}, businessHours...),
+			now:             at(3, 10),
+			expectErrs:      2,
+			expectActive:    "scale-up",
+			expectReplicas:  5,
+			expectScheduled: true,
+			expectNext:      "scale-down",
+			expectNextTime:  at(3, 20),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &xstsappv1.XStatefulSet{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: created},
+				Spec:       xstsappv1.XStatefulSetSpec{ScalingSchedules: tt.schedules},
+				Status:     xstsappv1.XStatefulSetStatus{ScalingSchedule: tt.lastStatus},
+			}
+			status, errs := getScalingScheduleStatus(set, tt.now)
+			if len(errs) != tt.expectErrs {
+				t.Fatalf("expected %d errors, got %v", tt.expectErrs, errs)
+			}
+			if status.ActiveSchedule != tt.expectActive {
+				t.Errorf("expected active schedule %q, got %q", tt.expectActive, status.ActiveSchedule)
+			}
+			if status.NextSchedule != tt.expectNext || !status.NextScheduleTime.Time.Equal(tt.expectNextTime) {
+				t.Errorf("expected next schedule %q at %v, got %q at %v", tt.expectNext, tt.expectNextTime, status.NextSchedule, status.NextScheduleTime)
+			}
+			replicas, ok := getScheduledReplicas(set, status)
+			if ok != tt.expectScheduled || replicas != tt.expectReplicas {
+				t.Errorf("expected scheduled replicas %d (%v), got %d (%v)", tt.expectReplicas, tt.expectScheduled, replicas, ok)
+			}
+		})
+	}
+}
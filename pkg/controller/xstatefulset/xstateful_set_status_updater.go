@@ -38,7 +38,9 @@ import (
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
 	clientset "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned"
 	appslisters "github.com/xsts-sh/xstatefulset/client-go/listers/apps/v1"
+	"go.opentelemetry.io/otel/attribute"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/util/retry"
 	"k8s.io/klog/v2"
@@ -68,13 +70,16 @@ type realStatefulSetStatusUpdater struct {
 func (ssu *realStatefulSetStatusUpdater) UpdateStatefulSetStatus(
 	ctx context.Context,
 	set *xstsappv1.XStatefulSet,
-	status *xstsappv1.XStatefulSetStatus) error {
+	status *xstsappv1.XStatefulSetStatus) (err error) {
 	logger := klog.FromContext(ctx)
+	ctx, span := startSpan(ctx, "UpdateStatefulSetStatus", objectAttributes("XStatefulSet", types.NamespacedName{Namespace: set.Namespace, Name: set.Name})...)
+	defer func() { endSpan(span, err) }()
 	// don't wait due to limited number of clients, but backoff after the default number of steps
 	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		set.Status = *status
-		// TODO: This context.TODO should use a real context once we have RetryOnConflictWithContext
-		_, updateErr := ssu.client.AppsV1().XStatefulSets(set.Namespace).UpdateStatus(context.TODO(), set, metav1.UpdateOptions{})
+		attemptCtx, attemptSpan := startSpan(ctx, "UpdateStatus", attribute.String("resourceVersion", set.ResourceVersion))
+		_, updateErr := ssu.client.AppsV1().XStatefulSets(set.Namespace).UpdateStatus(attemptCtx, set, metav1.UpdateOptions{})
+		endSpan(attemptSpan, updateErr)
 		if updateErr == nil {
 			return nil
 		}
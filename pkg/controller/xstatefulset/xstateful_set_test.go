@@ -0,0 +1,228 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	xstatefulsetfake "github.com/xsts-sh/xstatefulset/client-go/clientset/versioned/fake"
+	xstatefulsetinformers "github.com/xsts-sh/xstatefulset/client-go/informers/externalversions"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	componentmetrics "k8s.io/component-base/metrics"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/ptr"
+)
+
+// queuedKeys drains the queue of ssc and returns the keys it held in sorted order.
+func queuedKeys(ssc *StatefulSetController) []string {
+	var keys []string
+	for ssc.queue.Len() > 0 {
+		key, _ := ssc.queue.Get()
+		ssc.queue.Done(key)
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestClaimHandlers(t *testing.T) {
+	newSet := func(name, template string) *xstsappv1.XStatefulSet {
+		return &xstsappv1.XStatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID("uid-" + name)},
+			Spec: xstsappv1.XStatefulSetSpec{
+				Selector:             &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: template}}},
+			},
+		}
+	}
+	// data-a-web-0 is named like a claim of both sets
+	web, aWeb := newSet("web", "data-a"), newSet("a-web", "data")
+	newClaim := func(app, resourceVersion string) *v1.PersistentVolumeClaim {
+		return &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            "data-a-web-0",
+			Labels:          map[string]string{"app": app},
+			ResourceVersion: resourceVersion,
+		}}
+	}
+	ownedClaim := newClaim("", "1")
+	ownedClaim.Labels = nil
+	ownedClaim.OwnerReferences = []metav1.OwnerReference{*metav1.NewControllerRef(aWeb, controllerKind)}
+
+	tests := []struct {
+		name   string
+		handle func(ssc *StatefulSetController, logger klog.Logger)
+		want   []string
+	}{
+		{
+			name: "add",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.enqueueStatefulSetsForClaim(logger, newClaim("web", "1"))
+			},
+			want: []string{"default/web"},
+		},
+		{
+			name: "add without the labels of a set",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.enqueueStatefulSetsForClaim(logger, newClaim("db", "1"))
+			},
+		},
+		{
+			name: "update",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.updateClaim(logger, newClaim("a-web", "1"), newClaim("a-web", "2"))
+			},
+			want: []string{"default/a-web"},
+		},
+		{
+			name: "update of the owner",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.updateClaim(logger, ownedClaim, newClaim("web", "2"))
+			},
+			want: []string{"default/a-web", "default/web"},
+		},
+		{
+			name: "resync",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.updateClaim(logger, newClaim("web", "1"), newClaim("web", "1"))
+			},
+		},
+		{
+			name: "delete",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.enqueueStatefulSetsForClaim(logger, ownedClaim)
+			},
+			want: []string{"default/a-web"},
+		},
+		{
+			name: "delete tombstone",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.enqueueStatefulSetsForClaim(logger, cache.DeletedFinalStateUnknown{
+					Key: "default/data-a-web-0",
+					Obj: newClaim("a-web", "1"),
+				})
+			},
+			want: []string{"default/a-web"},
+		},
+		{
+			name: "delete tombstone of another kind",
+			handle: func(ssc *StatefulSetController, logger klog.Logger) {
+				ssc.enqueueStatefulSetsForClaim(logger, cache.DeletedFinalStateUnknown{Key: "default/web-0", Obj: &v1.Pod{}})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kubeClient := fake.NewClientset()
+			xstatefulsetClient := xstatefulsetfake.NewClientset()
+			xstatefulsetInformers := xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0)
+			ssc := NewController(context.Background(), kubeClient, xstatefulsetClient,
+				informers.NewSharedInformerFactory(kubeClient, 0), xstatefulsetInformers,
+				WithEventRecorder(record.NewFakeRecorder(10)),
+				WithMetricsRegistry(componentmetrics.NewKubeRegistry()))
+			defer ssc.queue.ShutDown()
+			for _, set := range []*xstsappv1.XStatefulSet{web, aWeb} {
+				if err := xstatefulsetInformers.Apps().V1().XStatefulSets().Informer().GetIndexer().Add(set); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			tt.handle(ssc, klog.Background())
+			if got := queuedKeys(ssc); !slices.Equal(got, tt.want) {
+				t.Errorf("expected %v to be enqueued, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSyncWithUnobservedPodWrites(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	labels := map[string]string{"app": "web"}
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", UID: types.UID("web"), Generation: 2},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Replicas: ptr.To[int32](2),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: v1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "web", Image: "web:v1"}}},
+			},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{{ObjectMeta: metav1.ObjectMeta{Name: "data"}}},
+		},
+		Status: xstsappv1.XStatefulSetStatus{ObservedGeneration: 1},
+	}
+	pod := newStatefulSetPod(set, 0)
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+	kubeClient := fake.NewClientset(pod)
+	xstatefulsetClient := xstatefulsetfake.NewClientset(set)
+	kubeInformers := informers.NewSharedInformerFactory(kubeClient, 0)
+	xstatefulsetInformers := xstatefulsetinformers.NewSharedInformerFactory(xstatefulsetClient, 0)
+	ssc := NewController(ctx, kubeClient, xstatefulsetClient, kubeInformers, xstatefulsetInformers,
+		WithEventRecorder(record.NewFakeRecorder(10)),
+		WithMetricsRegistry(componentmetrics.NewKubeRegistry()))
+	kubeInformers.Start(ctx.Done())
+	xstatefulsetInformers.Start(ctx.Done())
+	kubeInformers.WaitForCacheSync(ctx.Done())
+	xstatefulsetInformers.WaitForCacheSync(ctx.Done())
+
+	// web-1 was created by the previous sync, but is not observed yet
+	key := "default/web"
+	ssc.expectations.ExpectCreations(klog.FromContext(ctx), key, 1)
+	kubeClient.ClearActions()
+	xstatefulsetClient.ClearActions()
+
+	if err := ssc.sync(ctx, key); err != errUnobservedPodWrites {
+		t.Fatalf("sync() error = %v, want %v", err, errUnobservedPodWrites)
+	}
+	for _, action := range writes(kubeClient.Actions()) {
+		if resource := action.GetResource().Resource; resource == "pods" || resource == "persistentvolumeclaims" {
+			t.Errorf("expected no Pod or PersistentVolumeClaim writes, got %v", action)
+		}
+	}
+	var status *xstsappv1.XStatefulSetStatus
+	for _, action := range writes(xstatefulsetClient.Actions()) {
+		if update, ok := action.(k8stesting.UpdateAction); ok && action.GetSubresource() == "status" {
+			status = &update.GetObject().(*xstsappv1.XStatefulSet).Status
+		}
+	}
+	if status == nil {
+		t.Fatalf("expected the status to be updated")
+	}
+	if status.ObservedGeneration != 2 || status.Replicas != 1 || status.ReadyReplicas != 1 {
+		t.Errorf("expected the status to observe generation 2 and the ready web-0, got %+v", status)
+	}
+
+	// the set is retried with backoff instead of after the expectations expire
+	ssc.queue.Add(key)
+	ssc.processNextWorkItem(ctx)
+	if requeues := ssc.queue.NumRequeues(key); requeues != 1 {
+		t.Errorf("expected the set to be requeued with backoff once, got %d requeues", requeues)
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// instrumentationScope is the name of the tracer that records the spans of the controller.
+const instrumentationScope = "github.com/xsts-sh/xstatefulset/pkg/controller/xstatefulset"
+
+// startSyncSpan starts the root span of the sync of the StatefulSet key with the global TracerProvider. Spans are
+// not recorded unless a TracerProvider has been installed with otel.SetTracerProvider.
+func startSyncSpan(ctx context.Context, key string) (context.Context, trace.Span) {
+	return otel.GetTracerProvider().Tracer(instrumentationScope).Start(ctx, "sync",
+		trace.WithSpanKind(trace.SpanKindInternal),
+		trace.WithAttributes(attribute.String("key", key)))
+}
+
+// startSpan starts a child of the span in ctx. It uses the TracerProvider of that span, so a span is only recorded
+// if the sync it is part of is recorded.
+func startSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return trace.SpanFromContext(ctx).TracerProvider().Tracer(instrumentationScope).Start(ctx, name,
+		trace.WithAttributes(attributes...))
+}
+
+// endSpan records err on span, if it is not nil, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// objectAttributes returns the span attributes that identify the object namespace/name.
+func objectAttributes(kind string, object types.NamespacedName) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("kind", kind),
+		attribute.String("namespace", object.Namespace),
+		attribute.String("name", object.Name),
+	}
+}
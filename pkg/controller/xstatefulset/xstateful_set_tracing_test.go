@@ -0,0 +1,72 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestObjectManagerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	om := &realStatefulPodControlObjectManager{client: fake.NewClientset()}
+	pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web-0"}}
+
+	// client calls outside of a recorded sync are not traced
+	if err := om.CreatePod(context.Background(), pod); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("expected no spans outside of a sync, got %d", len(spans))
+	}
+
+	ctx, sync := tp.Tracer(instrumentationScope).Start(context.Background(), "sync")
+	if err := om.DeletePod(ctx, pod); err != nil {
+		t.Fatalf("DeletePod() error = %v", err)
+	}
+	if err := om.DeletePod(ctx, pod); err == nil {
+		t.Fatalf("expected DeletePod() of a deleted pod to fail")
+	}
+	sync.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	for i, span := range spans[:2] {
+		if span.Name != "DeletePod" {
+			t.Errorf("expected span %d to be DeletePod, got %s", i, span.Name)
+		}
+		if span.Parent.SpanID() != sync.SpanContext().SpanID() {
+			t.Errorf("expected span %d to be a child of the sync span", i)
+		}
+	}
+	if spans[0].Status.Code != codes.Unset {
+		t.Errorf("expected successful DeletePod span, got status %v", spans[0].Status)
+	}
+	if spans[1].Status.Code != codes.Error {
+		t.Errorf("expected failed DeletePod span, got status %v", spans[1].Status)
+	}
+}
@@ -37,19 +37,25 @@ import (
 	"fmt"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
 	"github.com/xsts-sh/xstatefulset/pkg/controller"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/history"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
 	podutil "github.com/xsts-sh/xstatefulset/pkg/controller/utils"
+	"github.com/xsts-sh/xstatefulset/pkg/feature"
 	apps "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	utilfeature "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/klog/v2"
 )
 
@@ -135,6 +141,30 @@ func getPersistentVolumeClaimName(set *xstsappv1.XStatefulSet, claim *v1.Persist
 	return fmt.Sprintf("%s-%s-%d", claim.Name, set.Name, ordinal)
 }
 
+// isClaimOf tests if claim is named like a PersistentVolumeClaim created from one of set's VolumeClaimTemplates, that
+// is, <template>-<set>-<ordinal>, and has the labels that set copies from its selector to its claims. Names alone are
+// ambiguous: data-a-web-0 is named like a claim of the data template of set a-web and of the data-a template of set
+// web.
+func isClaimOf(set *xstsappv1.XStatefulSet, claim *v1.PersistentVolumeClaim) bool {
+	if set.Spec.Selector != nil {
+		for key, value := range set.Spec.Selector.MatchLabels {
+			if claimValue, ok := claim.Labels[key]; !ok || claimValue != value {
+				return false
+			}
+		}
+	}
+	for i := range set.Spec.VolumeClaimTemplates {
+		prefix := set.Spec.VolumeClaimTemplates[i].Name + "-" + set.Name + "-"
+		if !strings.HasPrefix(claim.Name, prefix) {
+			continue
+		}
+		if _, err := strconv.ParseUint(strings.TrimPrefix(claim.Name, prefix), 10, 32); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
 // isMemberOf tests if pod is a member of set.
 func isMemberOf(set *xstsappv1.XStatefulSet, pod *v1.Pod) bool {
 	return getParentName(pod) == set.Name
@@ -474,8 +504,9 @@ func isRunningAndReady(pod *v1.Pod) bool {
 	return pod.Status.Phase == v1.PodRunning && podutil.IsPodReady(pod)
 }
 
-func isRunningAndAvailable(pod *v1.Pod, minReadySeconds int32) bool {
-	return podutil.IsPodAvailable(pod, minReadySeconds, metav1.Now())
+// isRunningAndAvailable returns true if pod has been Ready for at least minReadySeconds at now.
+func isRunningAndAvailable(pod *v1.Pod, minReadySeconds int32, now time.Time) bool {
+	return podutil.IsPodAvailable(pod, minReadySeconds, metav1.NewTime(now))
 }
 
 // isCreated returns true if pod has been created and is maintained by the API server
@@ -503,14 +534,137 @@ func isTerminating(pod *v1.Pod) bool {
 	return pod.DeletionTimestamp != nil
 }
 
-// isUnavailable returns true if pod is not available or if it is terminating
-func isUnavailable(pod *v1.Pod, minReadySeconds int32) bool {
-	return !isRunningAndAvailable(pod, minReadySeconds) || isTerminating(pod)
+// isUnavailable returns true if pod is not available at now or if it is terminating
+func isUnavailable(pod *v1.Pod, minReadySeconds int32, now time.Time) bool {
+	return !isRunningAndAvailable(pod, minReadySeconds, now) || isTerminating(pod)
+}
+
+// getUnreachableNodeForceDeleteTimeout returns how long a Pod of set may be terminating on an unreachable Node
+// before it is force deleted. The second return value is false if set's UnreachableNodePolicy does not force
+// delete Pods, or if the UnreachableNodeForceDelete feature gate is disabled.
+func getUnreachableNodeForceDeleteTimeout(set *xstsappv1.XStatefulSet) (time.Duration, bool) {
+	policy := set.Spec.UnreachableNodePolicy
+	if policy == nil || policy.Type != xstsappv1.ForceDeleteUnreachableNodePolicyType ||
+		!utilfeature.DefaultFeatureGate.Enabled(feature.UnreachableNodeForceDelete) {
+		return 0, false
+	}
+	seconds := int32(300)
+	if policy.ForceDeleteAfterSeconds != nil {
+		seconds = *policy.ForceDeleteAfterSeconds
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+// getUnreachableTaint returns the NoExecute unreachable taint of node, or nil if node is not tainted as unreachable.
+func getUnreachableTaint(node *v1.Node) *v1.Taint {
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Key == v1.TaintNodeUnreachable && taint.Effect == v1.TaintEffectNoExecute {
+			return taint
+		}
+	}
+	return nil
+}
+
+// getUnreachablePodForceDeleteTime returns the time at which pod, which is terminating on node, may be force deleted
+// according to set's UnreachableNodePolicy. The second return value is false if pod is not terminating, node is not
+// tainted as unreachable, or the policy does not force delete Pods.
+func getUnreachablePodForceDeleteTime(set *xstsappv1.XStatefulSet, pod *v1.Pod, node *v1.Node) (time.Time, bool) {
+	timeout, ok := getUnreachableNodeForceDeleteTimeout(set)
+	if !ok || !isTerminating(pod) {
+		return time.Time{}, false
+	}
+	taint := getUnreachableTaint(node)
+	if taint == nil {
+		return time.Time{}, false
+	}
+	// DeletionTimestamp already accounts for the grace period, so start counting once both the grace
+	// period has expired and the node has been unreachable.
+	start := pod.DeletionTimestamp.Time
+	if taint.TimeAdded != nil && taint.TimeAdded.After(start) {
+		start = taint.TimeAdded.Time
+	}
+	return start.Add(timeout), true
 }
 
 // allowsBurst is true if the alpha burst annotation is set.
 func allowsBurst(set *xstsappv1.XStatefulSet) bool {
-	return set.Spec.PodManagementPolicy == apps.ParallelPodManagement
+	return set.Spec.PodManagementPolicy == apps.ParallelPodManagement || isBoundedParallel(set)
+}
+
+// isBoundedParallel is true if set limits the number of Pods that may be in flight at the same time.
+func isBoundedParallel(set *xstsappv1.XStatefulSet) bool {
+	return set.Spec.PodManagementPolicy == xstsappv1.BoundedParallelPodManagement
+}
+
+// rollsOutOnConfigChange is true if set rolls out its Pods when the ConfigMaps and Secrets referenced by its
+// template change. It is false for all sets unless the RolloutOnConfigChange feature gate is enabled.
+func rollsOutOnConfigChange(set *xstsappv1.XStatefulSet) bool {
+	return set.Spec.RolloutOnConfigChange != nil && *set.Spec.RolloutOnConfigChange &&
+		utilfeature.DefaultFeatureGate.Enabled(feature.RolloutOnConfigChange)
+}
+
+// getReferencedConfig returns the names of the ConfigMaps and Secrets that are mounted as volumes by template, or
+// that are consumed by the environment of its containers.
+func getReferencedConfig(template *v1.PodTemplateSpec) (configMaps sets.Set[string], secrets sets.Set[string]) {
+	configMaps, secrets = sets.New[string](), sets.New[string]()
+	for _, volume := range template.Spec.Volumes {
+		switch {
+		case volume.ConfigMap != nil:
+			configMaps.Insert(volume.ConfigMap.Name)
+		case volume.Secret != nil:
+			secrets.Insert(volume.Secret.SecretName)
+		case volume.Projected != nil:
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps.Insert(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					secrets.Insert(source.Secret.Name)
+				}
+			}
+		}
+	}
+	containers := append(template.Spec.InitContainers[:len(template.Spec.InitContainers):len(template.Spec.InitContainers)],
+		template.Spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMaps.Insert(envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				secrets.Insert(envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps.Insert(env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets.Insert(env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	return configMaps, secrets
+}
+
+// isSuspended is true if all Pods of set should be deleted while its replicas are kept.
+func isSuspended(set *xstsappv1.XStatefulSet) bool {
+	return set.Spec.Suspend != nil && *set.Spec.Suspend
+}
+
+// isInFlight returns true if pod has been created and is pending, terminating, or Ready but not yet available at now.
+// Pods that are running but not Ready, e.g. because they never become Ready or crash loop, are not in flight, so
+// that they cannot hold the operations of the other Pods of their set back forever.
+func isInFlight(pod *v1.Pod, minReadySeconds int32, now time.Time) bool {
+	if !isCreated(pod) {
+		return false
+	}
+	return isPending(pod) || isTerminating(pod) ||
+		(isRunningAndReady(pod) && !isRunningAndAvailable(pod, minReadySeconds, now))
 }
 
 // setPodRevision sets the revision of Pod to revision by adding the StatefulSetRevisionLabel
@@ -645,7 +799,9 @@ func inconsistentStatus(set *xstsappv1.XStatefulSet, status *xstsappv1.XStateful
 		status.UpdatedReplicas != set.Status.UpdatedReplicas ||
 		status.CurrentRevision != set.Status.CurrentRevision ||
 		status.AvailableReplicas != set.Status.AvailableReplicas ||
-		status.UpdateRevision != set.Status.UpdateRevision
+		status.UpdateRevision != set.Status.UpdateRevision ||
+		!apiequality.Semantic.DeepEqual(status.ScalingSchedule, set.Status.ScalingSchedule) ||
+		!apiequality.Semantic.DeepEqual(status.Conditions, set.Status.Conditions)
 }
 
 // completeRollingUpdate completes a rolling update when all of set's replica Pods have been updated
@@ -713,3 +869,17 @@ func getStatefulSetMaxUnavailable(maxUnavailable *intstr.IntOrString, replicaCou
 	}
 	return maxUnavailableNum, nil
 }
+
+// getStatefulSetMaxConcurrentPodOperations calculates the real number of Pods that may be in flight at the same time
+// according to the replica count and maxConcurrentPodOperations. The number defaults to 10 if the field is not set,
+// and it is never less than 1 if the value is a percentage that rounds down to 0.
+func getStatefulSetMaxConcurrentPodOperations(maxConcurrent *intstr.IntOrString, replicaCount int) (int, error) {
+	maxConcurrentNum, err := intstr.GetScaledValueFromIntOrPercent(intstr.ValueOrDefault(maxConcurrent, intstr.FromInt32(10)), replicaCount, false)
+	if err != nil {
+		return 0, err
+	}
+	if maxConcurrentNum < 1 {
+		maxConcurrentNum = 1
+	}
+	return maxConcurrentNum, nil
+}
@@ -0,0 +1,200 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package xstatefulset
+
+import (
+	"testing"
+	"time"
+
+	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/ptr"
+)
+
+func TestGetUnreachablePodForceDeleteTime(t *testing.T) {
+	deletion := metav1.NewTime(time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC))
+	taintedLater := metav1.NewTime(deletion.Add(2 * time.Minute))
+	taintedEarlier := metav1.NewTime(deletion.Add(-2 * time.Minute))
+
+	forceDelete := &xstsappv1.UnreachableNodePolicy{
+		Type:                    xstsappv1.ForceDeleteUnreachableNodePolicyType,
+		ForceDeleteAfterSeconds: ptr.To[int32](60),
+	}
+	unreachableNode := func(timeAdded *metav1.Time) *v1.Node {
+		return &v1.Node{Spec: v1.NodeSpec{Taints: []v1.Taint{{
+			Key:       v1.TaintNodeUnreachable,
+			Effect:    v1.TaintEffectNoExecute,
+			TimeAdded: timeAdded,
+		}}}}
+	}
+
+	tests := []struct {
+		name       string
+		policy     *xstsappv1.UnreachableNodePolicy
+		deletion   *metav1.Time
+		node       *v1.Node
+		expectOK   bool
+		expectTime time.Time
+	}{
+		{
+			name:     "no policy waits",
+			deletion: &deletion,
+			node:     unreachableNode(&taintedEarlier),
+		},
+		{
+			name:     "wait policy waits",
+			policy:   &xstsappv1.UnreachableNodePolicy{Type: xstsappv1.WaitUnreachableNodePolicyType},
+			deletion: &deletion,
+			node:     unreachableNode(&taintedEarlier),
+		},
+		{
+			name:   "pod not terminating",
+			policy: forceDelete,
+			node:   unreachableNode(&taintedEarlier),
+		},
+		{
+			name:     "node reachable",
+			policy:   forceDelete,
+			deletion: &deletion,
+			node:     &v1.Node{},
+		},
+		{
+			name:       "counts from deletion timestamp when tainted before deletion",
+			policy:     forceDelete,
+			deletion:   &deletion,
+			node:       unreachableNode(&taintedEarlier),
+			expectOK:   true,
+			expectTime: deletion.Add(time.Minute),
+		},
+		{
+			name:       "counts from taint when tainted after deletion",
+			policy:     forceDelete,
+			deletion:   &deletion,
+			node:       unreachableNode(&taintedLater),
+			expectOK:   true,
+			expectTime: taintedLater.Add(time.Minute),
+		},
+		{
+			name:       "defaults timeout",
+			policy:     &xstsappv1.UnreachableNodePolicy{Type: xstsappv1.ForceDeleteUnreachableNodePolicyType},
+			deletion:   &deletion,
+			node:       unreachableNode(nil),
+			expectOK:   true,
+			expectTime: deletion.Add(5 * time.Minute),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := &xstsappv1.XStatefulSet{Spec: xstsappv1.XStatefulSetSpec{UnreachableNodePolicy: tt.policy}}
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: tt.deletion}}
+
+			forceDeleteTime, ok := getUnreachablePodForceDeleteTime(set, pod, tt.node)
+			if ok != tt.expectOK {
+				t.Fatalf("expected ok=%v, got %v", tt.expectOK, ok)
+			}
+			if ok && !forceDeleteTime.Equal(tt.expectTime) {
+				t.Errorf("expected force delete time %v, got %v", tt.expectTime, forceDeleteTime)
+			}
+		})
+	}
+}
+
+func TestGetReferencedConfig(t *testing.T) {
+	template := &v1.PodTemplateSpec{Spec: v1.PodSpec{
+		Volumes: []v1.Volume{
+			{Name: "config", VolumeSource: v1.VolumeSource{ConfigMap: &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: "volume-config"}}}},
+			{Name: "certs", VolumeSource: v1.VolumeSource{Secret: &v1.SecretVolumeSource{SecretName: "volume-secret"}}},
+			{Name: "projected", VolumeSource: v1.VolumeSource{Projected: &v1.ProjectedVolumeSource{Sources: []v1.VolumeProjection{
+				{ConfigMap: &v1.ConfigMapProjection{LocalObjectReference: v1.LocalObjectReference{Name: "projected-config"}}},
+				{Secret: &v1.SecretProjection{LocalObjectReference: v1.LocalObjectReference{Name: "projected-secret"}}},
+			}}}},
+			{Name: "scratch", VolumeSource: v1.VolumeSource{EmptyDir: &v1.EmptyDirVolumeSource{}}},
+		},
+		InitContainers: []v1.Container{{
+			Name: "init",
+			EnvFrom: []v1.EnvFromSource{
+				{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "init-config"}}},
+			},
+		}},
+		Containers: []v1.Container{{
+			Name: "app",
+			EnvFrom: []v1.EnvFromSource{
+				{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "env-secret"}}},
+			},
+			Env: []v1.EnvVar{
+				{Name: "PLAIN", Value: "value"},
+				{Name: "FROM_CONFIG", ValueFrom: &v1.EnvVarSource{ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "volume-config"}, Key: "key"}}},
+				{Name: "FROM_SECRET", ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "key-secret"}, Key: "key"}}},
+			},
+		}},
+	}}
+
+	configMaps, secrets := getReferencedConfig(template)
+	if expected := sets.New("volume-config", "projected-config", "init-config"); !configMaps.Equal(expected) {
+		t.Errorf("expected ConfigMaps %v, got %v", sets.List(expected), sets.List(configMaps))
+	}
+	if expected := sets.New("volume-secret", "projected-secret", "env-secret", "key-secret"); !secrets.Equal(expected) {
+		t.Errorf("expected Secrets %v, got %v", sets.List(expected), sets.List(secrets))
+	}
+	if len(template.Spec.InitContainers) != 1 {
+		t.Errorf("expected the template to be left unchanged")
+	}
+}
+
+func TestIsClaimOf(t *testing.T) {
+	set := &xstsappv1.XStatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec: xstsappv1.XStatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			VolumeClaimTemplates: []v1.PersistentVolumeClaim{
+				{ObjectMeta: metav1.ObjectMeta{Name: "data"}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "logs"}},
+			},
+		},
+	}
+	webLabels := map[string]string{"app": "web", "tier": "storage"}
+
+	tests := []struct {
+		claim    string
+		labels   map[string]string
+		expected bool
+	}{
+		{claim: "data-web-0", labels: webLabels, expected: true},
+		{claim: "logs-web-12", labels: webLabels, expected: true},
+		{claim: "data-web", labels: webLabels, expected: false},
+		{claim: "data-web-", labels: webLabels, expected: false},
+		{claim: "data-web-x", labels: webLabels, expected: false},
+		{claim: "data-web-1-0", labels: webLabels, expected: false},
+		{claim: "cache-web-0", labels: webLabels, expected: false},
+		{claim: "data-webapp-0", labels: webLabels, expected: false},
+		{claim: "data-web-0", labels: map[string]string{"app": "a-web"}, expected: false},
+		{claim: "data-web-0", expected: false},
+	}
+
+	for _, tt := range tests {
+		claim := &v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: tt.claim, Labels: tt.labels}}
+		if got := isClaimOf(set, claim); got != tt.expected {
+			t.Errorf("isClaimOf(%s) = %v, expected %v", tt.claim, got, tt.expected)
+		}
+	}
+}
@@ -17,6 +17,9 @@ limitations under the License.
 package feature
 
 import (
+	"maps"
+	"slices"
+
 	"k8s.io/apimachinery/pkg/util/runtime"
 	feature2 "k8s.io/apiserver/pkg/util/feature"
 	"k8s.io/component-base/featuregate"
@@ -34,11 +37,29 @@ const (
 	//
 	// Enables maxUnavailable for StatefulSet
 	MaxUnavailableStatefulSet featuregate.Feature = "MaxUnavailableStatefulSet"
+	// Rolls out the Pods of StatefulSets that set rolloutOnConfigChange when their ConfigMaps or Secrets change. The
+	// controller only watches, and caches, the ConfigMaps and Secrets of the cluster if it is enabled.
+	RolloutOnConfigChange featuregate.Feature = "RolloutOnConfigChange"
+	// Force deletes the Pods of StatefulSets whose unreachableNodePolicy is ForceDelete once they have been terminating
+	// on an unreachable Node for longer than the policy allows. The controller only watches, and caches, the Nodes of
+	// the cluster if it is enabled, so it needs to be allowed to list and watch Nodes.
+	UnreachableNodeForceDelete featuregate.Feature = "UnreachableNodeForceDelete"
 )
 
+// defaultFeatureGates are the feature gates of the controller. DefaultMutableFeatureGate also knows the gates of the
+// Kubernetes libraries the controller is built with.
+var defaultFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	MaxUnavailableStatefulSet:             {Default: true, PreRelease: featuregate.Beta},
+	StatefulSetSemanticRevisionComparison: {Default: true, PreRelease: featuregate.Beta},
+	RolloutOnConfigChange:                 {Default: false, PreRelease: featuregate.Alpha},
+	UnreachableNodeForceDelete:            {Default: true, PreRelease: featuregate.Beta},
+}
+
 func init() {
-	runtime.Must(feature2.DefaultMutableFeatureGate.Add(map[featuregate.Feature]featuregate.FeatureSpec{
-		MaxUnavailableStatefulSet:             {Default: true, PreRelease: featuregate.Beta},
-		StatefulSetSemanticRevisionComparison: {Default: true, PreRelease: featuregate.Beta},
-	}))
+	runtime.Must(feature2.DefaultMutableFeatureGate.Add(defaultFeatureGates))
+}
+
+// Features returns the feature gates of the controller sorted by name.
+func Features() []featuregate.Feature {
+	return slices.Sorted(maps.Keys(defaultFeatureGates))
 }
@@ -0,0 +1,65 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics serves the metrics of the controller manager.
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	dto "github.com/prometheus/client_model/go"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/component-base/metrics/legacyregistry"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// registry is a controller-runtime registry that also gathers the metrics registered with the Kubernetes legacy
+// registry, which the controller metrics are registered with. Metric families registered with both registries,
+// like the Go and process collectors, are taken from controller-runtime.
+type registry struct {
+	ctrlmetrics.RegistererGatherer
+}
+
+func (r *registry) Gather() ([]*dto.MetricFamily, error) {
+	families, err := r.RegistererGatherer.Gather()
+	legacyFamilies, legacyErr := legacyregistry.DefaultGatherer.Gather()
+
+	names := sets.New[string]()
+	for _, family := range families {
+		names.Insert(family.GetName())
+	}
+	for _, family := range legacyFamilies {
+		if !names.Has(family.GetName()) {
+			families = append(families, family)
+		}
+	}
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].GetName() < families[j].GetName()
+	})
+	return families, utilerrors.NewAggregate([]error{err, legacyErr})
+}
+
+var installRegistry sync.Once
+
+// InstallLegacyRegistry makes the controller-runtime metrics server, which serves ctrlmetrics.Registry, serve the
+// metrics of the Kubernetes legacy registry as well. It must be called before the metrics server is started.
+func InstallLegacyRegistry() {
+	installRegistry.Do(func() {
+		ctrlmetrics.Registry = &registry{ctrlmetrics.Registry}
+	})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright The XSTS-SH Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+func TestRegistryGather(t *testing.T) {
+	legacyGauge := metrics.NewGauge(&metrics.GaugeOpts{
+		Name:           "xstatefulset_registry_test_legacy",
+		Help:           "Gauge registered with the legacy registry.",
+		StabilityLevel: metrics.ALPHA,
+	})
+	legacyregistry.MustRegister(legacyGauge)
+	legacyGauge.Set(1)
+
+	ctrlRegistry := prometheus.NewRegistry()
+	// the legacy registry registers the Go collector as well, it must only be gathered once.
+	ctrlRegistry.MustRegister(collectors.NewGoCollector())
+	ctrlGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "xstatefulset_registry_test_ctrl",
+		Help: "Gauge registered with the controller-runtime registry.",
+	})
+	ctrlRegistry.MustRegister(ctrlGauge)
+
+	families, err := (&registry{ctrlRegistry}).Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	counts := map[string]int{}
+	for i, family := range families {
+		counts[family.GetName()]++
+		if i > 0 && families[i-1].GetName() > family.GetName() {
+			t.Errorf("expected metric families sorted by name, got %s before %s", families[i-1].GetName(), family.GetName())
+		}
+	}
+	for _, name := range []string{"xstatefulset_registry_test_legacy", "xstatefulset_registry_test_ctrl", "go_goroutines"} {
+		if counts[name] != 1 {
+			t.Errorf("expected metric family %s to be gathered once, got %d", name, counts[name])
+		}
+	}
+}
@@ -18,12 +18,18 @@ package webhook
 
 import (
 	"context"
+	"fmt"
 
 	xstsappv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
 	"github.com/xsts-sh/xstatefulset/pkg/controller/legacyscheme"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
 // +kubebuilder:webhook:path=/mutate-apps-x-k8s-io-v1-xstatefulset,mutating=true,failurePolicy=fail,sideEffects=None,groups=apps.x-k8s.io,resources=xstatefulsets,verbs=create;update,versions=v1,name=mxstatefulset.kb.io,admissionReviewVersions=v1
@@ -53,3 +59,57 @@ func (w *XStatefulSetDefaulter) Default(ctx context.Context, obj runtime.Object)
 }
 
 var _ webhook.CustomDefaulter = &XStatefulSetDefaulter{}
+
+// +kubebuilder:webhook:path=/validate-apps-x-k8s-io-v1-xstatefulset,mutating=false,failurePolicy=fail,sideEffects=None,groups=apps.x-k8s.io,resources=xstatefulsets,verbs=create;update,versions=v1,name=vxstatefulset.kb.io,admissionReviewVersions=v1
+
+// XStatefulSetValidator implements a validating webhook for XStatefulSet
+type XStatefulSetValidator struct{}
+
+// SetupWebhookWithManager registers the webhook with the manager
+func (v *XStatefulSetValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&xstsappv1.XStatefulSet{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements webhook.CustomValidator
+func (v *XStatefulSetValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validateXStatefulSet(obj)
+}
+
+// ValidateUpdate implements webhook.CustomValidator
+func (v *XStatefulSetValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	return nil, validateXStatefulSet(newObj)
+}
+
+// ValidateDelete implements webhook.CustomValidator
+func (v *XStatefulSetValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateXStatefulSet returns an Invalid error listing the fields of obj that the controller cannot act on.
+func validateXStatefulSet(obj runtime.Object) error {
+	set, ok := obj.(*xstsappv1.XStatefulSet)
+	if !ok {
+		return fmt.Errorf("expected a XStatefulSet but got a %T", obj)
+	}
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if policy := set.Spec.UnreachableNodePolicy; policy != nil && policy.ForceDeleteAfterSeconds != nil &&
+		*policy.ForceDeleteAfterSeconds < 0 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("unreachableNodePolicy", "forceDeleteAfterSeconds"),
+			*policy.ForceDeleteAfterSeconds, "must be greater than or equal to 0"))
+	}
+	if maxConcurrent := set.Spec.MaxConcurrentPodOperations; maxConcurrent != nil &&
+		maxConcurrent.Type == intstr.Int && maxConcurrent.IntVal < 1 {
+		allErrs = append(allErrs, field.Invalid(specPath.Child("maxConcurrentPodOperations"), maxConcurrent.IntVal,
+			"must be greater than or equal to 1"))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: xstsappv1.GroupName, Kind: "XStatefulSet"}, set.Name, allErrs)
+}
+
+var _ webhook.CustomValidator = &XStatefulSetValidator{}
@@ -23,7 +23,10 @@ import (
 	xappsv1 "github.com/xsts-sh/xstatefulset/api/apps/v1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
 )
 
 func TestXStatefulSetDefaulter_Default(t *testing.T) {
@@ -181,4 +184,73 @@ func TestSetDefaults_XStatefulSet(t *testing.T) {
 			t.Errorf("expected whenScaled=Retain, got %v", xsts.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled)
 		}
 	}
+	if xsts.Spec.UnreachableNodePolicy == nil {
+		t.Error("expected unreachableNodePolicy to be set")
+	} else {
+		if xsts.Spec.UnreachableNodePolicy.Type != xappsv1.WaitUnreachableNodePolicyType {
+			t.Errorf("expected unreachableNodePolicy.type=Wait, got %v", xsts.Spec.UnreachableNodePolicy.Type)
+		}
+		if xsts.Spec.UnreachableNodePolicy.ForceDeleteAfterSeconds != nil {
+			t.Errorf("expected forceDeleteAfterSeconds to be unset, got %v", *xsts.Spec.UnreachableNodePolicy.ForceDeleteAfterSeconds)
+		}
+	}
+	if xsts.Spec.Suspend == nil || *xsts.Spec.Suspend {
+		t.Errorf("expected suspend=false, got %v", xsts.Spec.Suspend)
+	}
+}
+
+func TestXStatefulSetValidator(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        *xappsv1.UnreachableNodePolicy
+		maxConcurrent *intstr.IntOrString
+		wantErr       bool
+	}{
+		{
+			name: "no unreachable node policy",
+		},
+		{
+			name:   "force delete right away",
+			policy: &xappsv1.UnreachableNodePolicy{Type: xappsv1.ForceDeleteUnreachableNodePolicyType, ForceDeleteAfterSeconds: ptr.To[int32](0)},
+		},
+		{
+			name:    "negative force delete timeout",
+			policy:  &xappsv1.UnreachableNodePolicy{Type: xappsv1.ForceDeleteUnreachableNodePolicyType, ForceDeleteAfterSeconds: ptr.To[int32](-1)},
+			wantErr: true,
+		},
+		{
+			name:          "percentage of concurrent pod operations",
+			maxConcurrent: ptr.To(intstr.FromString("0%")),
+		},
+		{
+			name:          "no concurrent pod operations",
+			maxConcurrent: ptr.To(intstr.FromInt32(0)),
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xsts := &xappsv1.XStatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"},
+				Spec: xappsv1.XStatefulSetSpec{
+					UnreachableNodePolicy:      tt.policy,
+					MaxConcurrentPodOperations: tt.maxConcurrent,
+				},
+			}
+			validator := &XStatefulSetValidator{}
+
+			_, err := validator.ValidateCreate(context.Background(), xsts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !apierrors.IsInvalid(err) {
+				t.Errorf("expected an Invalid error, got %v", err)
+			}
+			_, err = validator.ValidateUpdate(context.Background(), &xappsv1.XStatefulSet{}, xsts)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
 }